@@ -2,11 +2,27 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/goinginblind/l0-task/internal/app"
 )
 
 func main() {
+	// 'service export [flags]' writes an export of the orders and exits, see app.Export
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := app.Export(os.Args[2:]); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+	// 'service consumer <command>' controls the consumer of a running service, see app.ConsumerCtl
+	if len(os.Args) > 1 && os.Args[1] == "consumer" {
+		if err := app.ConsumerCtl(os.Args[2:]); err != nil {
+			log.Fatalf("Consumer command failed: %v", err)
+		}
+		return
+	}
+
 	application, err := app.New()
 	if err != nil {
 		log.Fatalf("Failed to setup application: %v", err)
@@ -0,0 +1,177 @@
+// Package analytics serves the sales analytics of the stored orders. The reports are SQL
+// aggregates over every order, so each is cached for a short while: the dashboards polling
+// them cost the database one query per TTL rather than one per request.
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// MaxTopLimit caps how many top brands and items are reported.
+const MaxTopLimit = 100
+
+// DefaultTopLimit is the number of top brands and items reported by default.
+const DefaultTopLimit = 10
+
+// defaultMaxEntries is the fallback of the cached reports cap.
+const defaultMaxEntries = 1000
+
+// errAbandoned is what the callers waiting for a report get if its computation panicked.
+var errAbandoned = errors.New("the computation of the report was abandoned")
+
+// Source computes the reports, the *store.AnalyticsStore implements it.
+type Source interface {
+	Revenue(ctx context.Context, f store.OrderFilter) (*store.RevenueReport, error)
+	Orders(ctx context.Context, f store.OrderFilter) (*store.OrdersReport, error)
+	Items(ctx context.Context, f store.OrderFilter, limit int) (*store.ItemsReport, error)
+	Payments(ctx context.Context, f store.OrderFilter) (*store.PaymentsReport, error)
+}
+
+// cacheKey is a report for a filter (and a limit).
+type cacheKey struct {
+	report string
+	filter store.OrderFilter
+	limit  int
+}
+
+// cacheEntry is a computed report and when it's stale.
+type cacheEntry struct {
+	report  any
+	expires time.Time
+}
+
+// call is a report being computed, the concurrent misses of the same report wait for it.
+type call struct {
+	done   chan struct{}
+	report any
+	err    error
+}
+
+// Service caches the reports of the Source for ttl, at most maxEntries of them. Concurrent
+// misses of the same report share a single computation.
+type Service struct {
+	source     Source
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+	calls   map[cacheKey]*call // the reports being computed
+}
+
+// NewService creates a Service caching the reports for ttl, they aren't cached if it's 0.
+// Once maxEntries reports are cached (1000 if it's 0) the new ones aren't until some expire.
+func NewService(source Source, ttl time.Duration, maxEntries int) *Service {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	return &Service{
+		source:     source,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[cacheKey]cacheEntry),
+		calls:      make(map[cacheKey]*call),
+	}
+}
+
+// Revenue returns the revenue per day and per currency.
+func (s *Service) Revenue(ctx context.Context, f store.OrderFilter) (*store.RevenueReport, error) {
+	return cached(ctx, s, cacheKey{report: "revenue", filter: f}, func() (*store.RevenueReport, error) {
+		return s.source.Revenue(ctx, f)
+	})
+}
+
+// Orders returns the order counts by delivery service and by region.
+func (s *Service) Orders(ctx context.Context, f store.OrderFilter) (*store.OrdersReport, error) {
+	return cached(ctx, s, cacheKey{report: "orders", filter: f}, func() (*store.OrdersReport, error) {
+		return s.source.Orders(ctx, f)
+	})
+}
+
+// Items returns the limit top brands and items (DefaultTopLimit if it's 0, MaxTopLimit at most)
+// and the average sale.
+func (s *Service) Items(ctx context.Context, f store.OrderFilter, limit int) (*store.ItemsReport, error) {
+	if limit <= 0 {
+		limit = DefaultTopLimit
+	}
+	limit = min(limit, MaxTopLimit)
+	return cached(ctx, s, cacheKey{report: "items", filter: f, limit: limit}, func() (*store.ItemsReport, error) {
+		return s.source.Items(ctx, f, limit)
+	})
+}
+
+// Payments returns the payment provider mix.
+func (s *Service) Payments(ctx context.Context, f store.OrderFilter) (*store.PaymentsReport, error) {
+	return cached(ctx, s, cacheKey{report: "payments", filter: f}, func() (*store.PaymentsReport, error) {
+		return s.source.Payments(ctx, f)
+	})
+}
+
+// cached returns the report of key if it's fresh, computes and caches it otherwise.
+// A miss while the report is being computed waits for that instead. Errors aren't cached.
+func cached[T any](ctx context.Context, s *Service, key cacheKey, compute func() (T, error)) (T, error) {
+	for {
+		s.mu.Lock()
+		if e, ok := s.entries[key]; ok && s.now().Before(e.expires) {
+			s.mu.Unlock()
+			return e.report.(T), nil
+		}
+		if c, ok := s.calls[key]; ok {
+			s.mu.Unlock()
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				var zero T
+				return zero, ctx.Err()
+			}
+			if c.err == errAbandoned || isCanceled(c.err) && ctx.Err() == nil {
+				continue // its caller gave up, this one hasn't
+			}
+			report, _ := c.report.(T)
+			return report, c.err
+		}
+		c := &call{done: make(chan struct{}), err: errAbandoned}
+		s.calls[key] = c
+		s.mu.Unlock()
+
+		defer s.finish(key, c)
+		report, err := compute()
+		c.report, c.err = report, err
+		return report, err
+	}
+}
+
+// finish caches the report of a computation that's done and wakes up its waiters.
+func (s *Service) finish(key cacheKey, c *call) {
+	s.mu.Lock()
+	defer close(c.done)
+	defer s.mu.Unlock()
+
+	delete(s.calls, key)
+	if c.err != nil || s.ttl <= 0 {
+		return
+	}
+	now := s.now()
+	// the stale ones go first, there's one entry per filter asked for
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	if len(s.entries) >= s.maxEntries {
+		return
+	}
+	s.entries[key] = cacheEntry{report: c.report, expires: now.Add(s.ttl)}
+}
+
+// isCanceled reports whether err is the computing caller's context ending.
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
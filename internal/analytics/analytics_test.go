@@ -0,0 +1,175 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSource counts the reports computed, it fails while err is set.
+type countingSource struct {
+	calls  int
+	limits []int
+	err    error
+}
+
+func (c *countingSource) Revenue(ctx context.Context, f store.OrderFilter) (*store.RevenueReport, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &store.RevenueReport{ByCurrency: []store.CurrencyTotal{{Currency: f.Currency}}}, nil
+}
+
+func (c *countingSource) Orders(ctx context.Context, f store.OrderFilter) (*store.OrdersReport, error) {
+	c.calls++
+	return &store.OrdersReport{}, c.err
+}
+
+func (c *countingSource) Items(ctx context.Context, f store.OrderFilter, limit int) (*store.ItemsReport, error) {
+	c.calls++
+	c.limits = append(c.limits, limit)
+	return &store.ItemsReport{}, c.err
+}
+
+func (c *countingSource) Payments(ctx context.Context, f store.OrderFilter) (*store.PaymentsReport, error) {
+	c.calls++
+	return &store.PaymentsReport{}, c.err
+}
+
+func TestService_cache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	source := &countingSource{}
+	s := NewService(source, 30*time.Second, 0)
+	s.now = func() time.Time { return now }
+
+	usd := store.OrderFilter{Currency: "USD"}
+	report, err := s.Revenue(ctx, usd)
+	require.NoError(t, err)
+	assert.Equal(t, "USD", report.ByCurrency[0].Currency)
+
+	_, err = s.Revenue(ctx, usd)
+	require.NoError(t, err)
+	assert.Equal(t, 1, source.calls, "cached")
+
+	_, err = s.Revenue(ctx, store.OrderFilter{Currency: "RUB"})
+	require.NoError(t, err)
+	_, err = s.Orders(ctx, usd)
+	require.NoError(t, err)
+	assert.Equal(t, 3, source.calls, "cached per report and filter")
+
+	now = now.Add(30 * time.Second)
+	_, err = s.Revenue(ctx, usd)
+	require.NoError(t, err)
+	assert.Equal(t, 4, source.calls, "expired")
+	assert.Len(t, s.entries, 1, "the stale entries are dropped")
+
+	source.err = errors.New("db down")
+	now = now.Add(time.Minute)
+	_, err = s.Revenue(ctx, usd)
+	require.Error(t, err)
+	_, err = s.Revenue(ctx, usd)
+	require.Error(t, err)
+	assert.Equal(t, 6, source.calls, "errors aren't cached")
+}
+
+func TestService_Items(t *testing.T) {
+	source := &countingSource{}
+	s := NewService(source, 0, 0)
+
+	for _, limit := range []int{0, 5, 1000} {
+		_, err := s.Items(context.Background(), store.OrderFilter{}, limit)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{DefaultTopLimit, 5, MaxTopLimit}, source.limits)
+
+	_, err := s.Items(context.Background(), store.OrderFilter{}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 4, source.calls, "not cached without a ttl")
+}
+
+func TestService_cacheIsBounded(t *testing.T) {
+	source := &countingSource{}
+	s := NewService(source, time.Minute, 2)
+
+	for _, currency := range []string{"USD", "RUB", "EUR", "EUR"} {
+		_, err := s.Revenue(context.Background(), store.OrderFilter{Currency: currency})
+		require.NoError(t, err)
+	}
+	assert.Len(t, s.entries, 2)
+	assert.Equal(t, 4, source.calls, "over the cap the reports aren't cached")
+}
+
+// blockingSource computes the revenue once release is closed, or fails once ctx is done.
+type blockingSource struct {
+	countingSource
+	started  chan struct{}
+	release  chan struct{}
+	computed atomic.Int32
+}
+
+func (b *blockingSource) Revenue(ctx context.Context, f store.OrderFilter) (*store.RevenueReport, error) {
+	if b.computed.Add(1) == 1 {
+		close(b.started)
+	}
+	select {
+	case <-b.release:
+		return &store.RevenueReport{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestService_concurrentMisses(t *testing.T) {
+	t.Run("share a computation", func(t *testing.T) {
+		source := &blockingSource{started: make(chan struct{}), release: make(chan struct{})}
+		s := NewService(source, time.Minute, 0)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Revenue(context.Background(), store.OrderFilter{})
+				assert.NoError(t, err)
+			}()
+		}
+		<-source.started
+		close(source.release)
+		wg.Wait()
+		assert.EqualValues(t, 1, source.computed.Load())
+	})
+
+	t.Run("a caller giving up doesn't fail the rest", func(t *testing.T) {
+		source := &blockingSource{started: make(chan struct{}), release: make(chan struct{})}
+		s := NewService(source, time.Minute, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		first := make(chan error)
+		go func() {
+			_, err := s.Revenue(ctx, store.OrderFilter{})
+			first <- err
+		}()
+		<-source.started
+
+		second := make(chan error)
+		go func() {
+			_, err := s.Revenue(context.Background(), store.OrderFilter{})
+			second <- err
+		}()
+		cancel()
+		assert.ErrorIs(t, <-first, context.Canceled)
+
+		close(source.release)
+		assert.NoError(t, <-second)
+	})
+}
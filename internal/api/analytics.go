@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// Analytics serves the sales reports, the *analytics.Service implements it.
+type Analytics interface {
+	Revenue(ctx context.Context, f store.OrderFilter) (*store.RevenueReport, error)
+	Orders(ctx context.Context, f store.OrderFilter) (*store.OrdersReport, error)
+	Items(ctx context.Context, f store.OrderFilter, limit int) (*store.ItemsReport, error)
+	Payments(ctx context.Context, f store.OrderFilter) (*store.PaymentsReport, error)
+}
+
+// WithAnalytics enables /api/v1/analytics/. Without it the reports are a 404.
+func WithAnalytics(a Analytics) Option {
+	return func(s *Server) {
+		s.analytics = a
+	}
+}
+
+// analyticsReport answers with the report for the order filters of GET /api/v1/orders.
+func (s *Server) analyticsReport(w http.ResponseWriter, r *http.Request, report func(store.OrderFilter) (any, error)) {
+	if s.analytics == nil {
+		s.writeError(w, r, http.StatusNotFound, "the analytics are not enabled")
+		return
+	}
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if field := transport.HiddenFilter(r.Context(), s.redactor, filter); field != "" {
+		s.writeError(w, r, http.StatusForbidden, fmt.Sprintf("%s is redacted for your role, the orders can't be filtered by it", field))
+		return
+	}
+
+	v, err := report(filter)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, v)
+}
+
+// apiAnalyticsRevenue is GET /api/v1/analytics/revenue, the revenue per UTC day and
+// currency, and per currency.
+func (s *Server) apiAnalyticsRevenue(w http.ResponseWriter, r *http.Request) {
+	s.analyticsReport(w, r, func(f store.OrderFilter) (any, error) {
+		return s.analytics.Revenue(r.Context(), f)
+	})
+}
+
+// apiAnalyticsOrders is GET /api/v1/analytics/orders, the order counts by delivery service and region.
+func (s *Server) apiAnalyticsOrders(w http.ResponseWriter, r *http.Request) {
+	s.analyticsReport(w, r, func(f store.OrderFilter) (any, error) {
+		return s.analytics.Orders(r.Context(), f)
+	})
+}
+
+// apiAnalyticsItems is GET /api/v1/analytics/items, the 'limit' best selling brands and items
+// and the average sale percentage.
+func (s *Server) apiAnalyticsItems(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			s.writeError(w, r, http.StatusBadRequest, errInvalidLimit.Error())
+			return
+		}
+	}
+	s.analyticsReport(w, r, func(f store.OrderFilter) (any, error) {
+		return s.analytics.Items(r.Context(), f, limit)
+	})
+}
+
+// apiAnalyticsPayments is GET /api/v1/analytics/payments, the payment provider mix.
+func (s *Server) apiAnalyticsPayments(w http.ResponseWriter, r *http.Request) {
+	s.analyticsReport(w, r, func(f store.OrderFilter) (any, error) {
+		return s.analytics.Payments(r.Context(), f)
+	})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAnalytics records the filter and the limit asked for.
+type fakeAnalytics struct {
+	filter store.OrderFilter
+	limit  int
+}
+
+func (f *fakeAnalytics) Revenue(ctx context.Context, filter store.OrderFilter) (*store.RevenueReport, error) {
+	f.filter = filter
+	return &store.RevenueReport{
+		ByDay:      []store.DailyRevenue{{Day: "2021-11-26", Currency: "USD", Orders: 2, Amount: 3634}},
+		ByCurrency: []store.CurrencyTotal{{Currency: "USD", Orders: 2, Amount: 3634}},
+	}, nil
+}
+
+func (f *fakeAnalytics) Orders(ctx context.Context, filter store.OrderFilter) (*store.OrdersReport, error) {
+	return nil, store.ErrConnectionFailed
+}
+
+func (f *fakeAnalytics) Items(ctx context.Context, filter store.OrderFilter, limit int) (*store.ItemsReport, error) {
+	f.limit = limit
+	return &store.ItemsReport{TopBrands: []store.BrandSales{}, TopItems: []store.ItemSales{}, Items: 1, AverageSalePercent: 30}, nil
+}
+
+func (f *fakeAnalytics) Payments(ctx context.Context, filter store.OrderFilter) (*store.PaymentsReport, error) {
+	return &store.PaymentsReport{Providers: []store.ProviderShare{{Provider: "wbpay", Orders: 2, Share: 1}}}, nil
+}
+
+func TestServer_analytics(t *testing.T) {
+	a := &fakeAnalytics{}
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithAnalytics(a), WithAnonymousAdmin())
+	require.NoError(t, err)
+	serve := func(s *Server, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	rr := serve(server, "/api/v1/analytics/revenue?currency=usd&created_from=2021-11-01&created_to=2021-11-30")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{
+		"by_day": [{"day": "2021-11-26", "currency": "USD", "orders": 2, "amount": 3634}],
+		"by_currency": [{"currency": "USD", "orders": 2, "amount": 3634}]
+	}`, rr.Body.String())
+	assert.Equal(t, store.OrderFilter{
+		Currency:    "usd",
+		CreatedFrom: time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+		CreatedTo:   time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC),
+	}, a.filter)
+
+	rr = serve(server, "/api/v1/analytics/items?limit=5")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 5, a.limit)
+	assert.JSONEq(t, `{"top_brands": [], "top_items": [], "items": 1, "average_sale_percent": 30}`, rr.Body.String())
+
+	rr = serve(server, "/api/v1/analytics/payments")
+	assert.JSONEq(t, `{"providers": [{"provider": "wbpay", "orders": 2, "share": 1}]}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(server, "/api/v1/analytics/orders").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(server, "/api/v1/analytics/items?limit=x").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(server, "/api/v1/analytics/revenue?created_from=yesterday").Code)
+
+	disabled, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithAnonymousAdmin())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, serve(disabled, "/api/v1/analytics/revenue").Code)
+}
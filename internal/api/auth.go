@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+)
+
+// WithAuth turns authentication on. Without it every caller is an anonymous viewer.
+func WithAuth(authenticator *auth.Authenticator) Option {
+	return func(s *Server) {
+		s.auth = authenticator
+	}
+}
+
+// WithAnonymousAdmin makes the anonymous callers admins while auth is off, so a local
+// setup can write orders without keys. It has no effect once auth is on.
+func WithAnonymousAdmin() Option {
+	return func(s *Server) {
+		s.anonymous.Role = auth.RoleAdmin
+	}
+}
+
+// disableAdmin turns the admin features off, without auth they'd be open to anyone
+// who can reach the port. Their routes are a 404 then.
+func (s *Server) disableAdmin() {
+	if s.cacheAdmin != nil {
+		s.logger.Warnw("Auth is off, the cache admin is disabled")
+		s.cacheAdmin = nil
+	}
+	if s.consumerAdmin != nil {
+		s.logger.Warnw("Auth is off, the consumer admin is disabled")
+		s.consumerAdmin = nil
+	}
+	if s.dlq != nil {
+		s.logger.Warnw("Auth is off, the dlq browser is disabled")
+		s.dlq = nil
+	}
+}
+
+// routeRoles are the minimum roles of the routes, the first matching rule wins.
+// A rule with no method matches any of them.
+var routeRoles = []struct {
+	method string
+	prefix string
+	role   auth.Role
+}{
+	{"", "/static/", auth.RoleNone},
+	{"GET", "/livez", auth.RoleNone},
+	{"GET", "/readyz", auth.RoleNone},
+	{"", "/healthz", auth.RoleSupport},
+	{"", "/metrics", auth.RoleNone}, // prometheus scrapes it without credentials
+	{"", "/api/v1/admin/", auth.RoleAdmin},
+	{"", "/admin/", auth.RoleAdmin},                 // the admin pages
+	{"GET", "/api/v1/exports", auth.RoleSupport},    // every order at once
+	{"GET", "/api/v1/customers/", auth.RoleSupport}, // viewers don't see the customer ids
+	{"GET", "/customers/", auth.RoleSupport},
+	{"GET", "/api/v1/analytics/", auth.RoleSupport}, // any filter, the customer_id too, over every order
+	{"GET", "/api/", auth.RoleViewer},
+	{"POST", "/api/v1/orders:batchGet", auth.RoleViewer}, // a read, POST only for the body
+	{"", "/api/", auth.RoleAdmin},                        // writes
+	{"", "/", auth.RoleViewer},
+}
+
+// requiredRole is the minimum role for the request's route.
+func requiredRole(r *http.Request) auth.Role {
+	for _, rule := range routeRoles {
+		if rule.method != "" && rule.method != r.Method {
+			continue
+		}
+		if strings.HasPrefix(r.URL.Path, rule.prefix) {
+			return rule.role
+		}
+	}
+	return auth.RoleAdmin
+}
+
+// authMiddleware puts the caller's identity into the request context and turns away the
+// callers whose role is too low for the route: 401 without (valid) credentials, 403 otherwise.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := requiredRole(r)
+		if s.auth == nil {
+			if !s.anonymous.Role.Allows(required) {
+				s.deny(w, r, http.StatusForbidden, s.anonymous, required, "auth is off")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), s.anonymous)))
+			return
+		}
+
+		id, err := s.auth.Authenticate(r)
+		if err != nil && required != auth.RoleNone {
+			reason := err.Error()
+			if errors.Is(err, auth.ErrNoCredentials) {
+				reason = "no credentials"
+			}
+			s.deny(w, r, http.StatusUnauthorized, id, required, reason)
+			return
+		}
+		if !id.Role.Allows(required) {
+			s.deny(w, r, http.StatusForbidden, id, required, "insufficient role")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+	})
+}
+
+func (s *Server) deny(w http.ResponseWriter, r *http.Request, status int, id auth.Identity, required auth.Role, reason string) {
+	s.requestLogger(r).Warnw("request denied",
+		"status", status,
+		"reason", reason,
+		"caller", id.String(),
+		"required_role", required.String(),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"remote", r.RemoteAddr,
+	)
+
+	api := strings.HasPrefix(r.URL.Path, "/api/")
+	if status == http.StatusUnauthorized {
+		// a browser prompts for basic auth, where an api key goes as the password
+		if api {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Basic realm="orders"`)
+		}
+	}
+
+	if api {
+		s.writeError(w, r, status, http.StatusText(status))
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// warnRecorder keeps the warnings, the rest goes nowhere.
+type warnRecorder struct {
+	logger.MockLogger
+	mu    sync.Mutex
+	warns [][]any
+}
+
+func (l *warnRecorder) With(keysAndValues ...any) logger.Logger { return l }
+
+func (l *warnRecorder) Warnw(msg string, keysAndValues ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, append([]any{msg}, keysAndValues...))
+}
+
+// testAdminKey is the api key of the admin withTestAdmin configures.
+const testAdminKey = "test-admin-key"
+
+// withTestAdmin turns auth on with a single admin key, the admin features are off without it.
+func withTestAdmin(t *testing.T) Option {
+	authenticator, err := auth.New([]auth.APIKey{{Name: "ops", Key: testAdminKey, Role: auth.RoleAdmin}}, nil, "")
+	require.NoError(t, err)
+	return WithAuth(authenticator)
+}
+
+func TestServer_authMiddleware(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	authenticator, err := auth.New([]auth.APIKey{
+		{Name: "dashboard", Key: "viewer-key", Role: auth.RoleViewer},
+		{Name: "oncall", Key: "support-key", Role: auth.RoleSupport},
+		{Name: "ops", Key: "admin-key", Role: auth.RoleAdmin},
+	}, secret, "")
+	require.NoError(t, err)
+	adminToken, err := auth.NewToken(auth.Claims{Subject: "billing", Role: "admin", ExpiresAt: time.Now().Add(time.Hour).Unix()}, secret)
+	require.NoError(t, err)
+
+	mockService, log := new(MockOrderService), &warnRecorder{}
+	mockService.On("GetOrder", mock.Anything, "authuid").Return(&domain.Order{OrderUID: "authuid"}, nil)
+	mockService.On("GetOrders", mock.Anything, []string{"authuid"}).Return([]*domain.Order{{OrderUID: "authuid"}}, nil)
+	server, err := NewServer(mockService, log, config.HTTPServerConfig{}, WithAuth(authenticator))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		method     string
+		target     string
+		apiKey     string
+		bearer     string
+		body       string
+		wantStatus int
+	}{
+		{name: "static is public", method: "GET", target: "/static/css/main.css", wantStatus: http.StatusOK},
+		{name: "liveness is public", method: "GET", target: "/livez", wantStatus: http.StatusOK},
+		{name: "page without credentials", method: "GET", target: "/orders/authuid", wantStatus: http.StatusUnauthorized},
+		{name: "page as viewer", method: "GET", target: "/orders/authuid", apiKey: "viewer-key", wantStatus: http.StatusOK},
+		{name: "api with a bad key", method: "GET", target: "/api/v1/orders/authuid", apiKey: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "api as viewer", method: "GET", target: "/api/v1/orders/authuid", apiKey: "viewer-key", wantStatus: http.StatusOK},
+		{name: "healthz as viewer", method: "GET", target: "/healthz", apiKey: "viewer-key", wantStatus: http.StatusForbidden},
+		{name: "healthz as support", method: "GET", target: "/healthz", apiKey: "support-key", wantStatus: http.StatusOK},
+		{name: "metrics are public", method: "GET", target: "/metrics", wantStatus: http.StatusOK},
+		{name: "batchGet as viewer", method: "POST", target: "/api/v1/orders:batchGet", body: `{"order_uids": ["authuid"]}`, apiKey: "viewer-key", wantStatus: http.StatusOK},
+		{name: "export as viewer", method: "GET", target: "/api/v1/exports", apiKey: "viewer-key", wantStatus: http.StatusForbidden},
+		// gets past auth, there's no exporter configured
+		{name: "export as support", method: "GET", target: "/api/v1/exports", apiKey: "support-key", wantStatus: http.StatusNotFound},
+		{name: "analytics as viewer", method: "GET", target: "/api/v1/analytics/revenue", apiKey: "viewer-key", wantStatus: http.StatusForbidden},
+		// gets past auth, there are no analytics configured
+		{name: "analytics as support", method: "GET", target: "/api/v1/analytics/revenue", apiKey: "support-key", wantStatus: http.StatusNotFound},
+		{name: "cache admin as support", method: "GET", target: "/api/v1/admin/cache", apiKey: "support-key", wantStatus: http.StatusForbidden},
+		// gets past auth, there's no cache admin configured
+		{name: "cache admin as admin", method: "GET", target: "/api/v1/admin/cache", apiKey: "admin-key", wantStatus: http.StatusNotFound},
+		{name: "consumer pause as support", method: "POST", target: "/api/v1/admin/consumer:pause", apiKey: "support-key", wantStatus: http.StatusForbidden},
+		// gets past auth, there's no consumer admin configured
+		{name: "consumer status as admin", method: "GET", target: "/api/v1/admin/consumer", apiKey: "admin-key", wantStatus: http.StatusNotFound},
+		{name: "customer history as viewer", method: "GET", target: "/api/v1/customers/test/orders", apiKey: "viewer-key", wantStatus: http.StatusForbidden},
+		// gets past auth, there's no customer source configured
+		{name: "customer page as support", method: "GET", target: "/customers/test", apiKey: "support-key", wantStatus: http.StatusNotFound},
+		{name: "dlq replay as support", method: "POST", target: "/api/v1/admin/dlq:replay", apiKey: "support-key", wantStatus: http.StatusForbidden},
+		{name: "dlq page as support", method: "GET", target: "/admin/dlq", apiKey: "support-key", wantStatus: http.StatusForbidden},
+		// gets past auth, there's no dlq browser configured
+		{name: "dlq page as admin", method: "GET", target: "/admin/dlq", apiKey: "admin-key", wantStatus: http.StatusNotFound},
+		{name: "create as support", method: "POST", target: "/api/v1/orders", apiKey: "support-key", wantStatus: http.StatusForbidden},
+		// gets past auth, the empty body is the handler's problem
+		{name: "create as admin", method: "POST", target: "/api/v1/orders", bearer: adminToken, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-Key", tc.apiKey)
+			}
+			if tc.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.bearer)
+			}
+			rr := httptest.NewRecorder()
+
+			server.httpServer.Handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			if tc.wantStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	t.Run("browsers are asked for basic auth", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/authuid", nil))
+		assert.True(t, strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), "Basic"))
+
+		req := httptest.NewRequest("GET", "/orders/authuid", nil)
+		req.SetBasicAuth("", "viewer-key")
+		rr = httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("denials are logged with the caller", func(t *testing.T) {
+		log.mu.Lock()
+		log.warns = nil
+		log.mu.Unlock()
+
+		req := httptest.NewRequest("POST", "/api/v1/orders", nil)
+		req.Header.Set("X-API-Key", "viewer-key")
+		server.httpServer.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		require.Len(t, log.warns, 1)
+		assert.Equal(t, "request denied", log.warns[0][0])
+		assert.Contains(t, log.warns[0], "api_key:dashboard(viewer)")
+		assert.Contains(t, log.warns[0], "admin")
+	})
+}
+
+func TestServer_authDisabled(t *testing.T) {
+	serve := func(server *Server, method, target string) (int, auth.Identity) {
+		var got auth.Identity
+		handler := server.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = auth.FromContext(r.Context())
+		}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr.Code, got
+	}
+
+	t.Run("anonymous viewers", func(t *testing.T) {
+		server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{})
+		require.NoError(t, err)
+
+		code, got := serve(server, "GET", "/orders/authuid")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, auth.RoleViewer, got.Role)
+		assert.Equal(t, auth.MethodAnonymous, got.Method)
+
+		code, _ = serve(server, "GET", "/api/v1/exports")
+		assert.Equal(t, http.StatusForbidden, code)
+		code, _ = serve(server, "POST", "/api/v1/orders")
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("anonymous admins on purpose", func(t *testing.T) {
+		server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithAnonymousAdmin())
+		require.NoError(t, err)
+
+		code, got := serve(server, "POST", "/api/v1/orders")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, auth.RoleAdmin, got.Role)
+	})
+}
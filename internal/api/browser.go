@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// browserParams are the query parameters of the order browser's links and filter controls,
+// apart from the sort and the cursor.
+var browserParams = []string{"created_from", "created_to", "customer_id", "delivery_service", "currency", "limit"}
+
+// sortColumn is a sortable column header of the order browser: the link sorts by it,
+// in the other direction if it's sorted by it already.
+type sortColumn struct {
+	Link      string
+	Sorted    bool
+	Ascending bool
+}
+
+// ordersView is the /orders page, the order browser: a page of orders with the filters and
+// the sort of GET /api/v1/orders, each linking to its /orders/{uid} page.
+func (s *Server) ordersView(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sort, err := parseOrderSort(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.sortHidden(r, sort.Field) || transport.HiddenFilter(r.Context(), s.redactor, filter) != "" {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	q := store.ListQuery{OrderFilter: filter, Sort: sort, Cursor: query.Get("cursor")}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			http.Error(w, errInvalidLimit.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := s.service.ListOrders(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+
+	// the links keep the filters and the page size, the sort links start over from the first page
+	base := url.Values{}
+	filters := make(map[string]string, len(browserParams))
+	for _, name := range browserParams {
+		if v := query.Get(name); v != "" {
+			base.Set(name, v)
+			filters[name] = v
+		}
+	}
+
+	columns := make(map[string]sortColumn)
+	for _, field := range []store.SortField{store.SortDateCreated, store.SortAmount, store.SortCustomerID, store.SortDeliveryService} {
+		if s.sortHidden(r, field) {
+			continue
+		}
+		sorted := sort.Field == field || sort.Field == "" && field == store.SortDateCreated
+		// the text columns read best a to z, the dates and the amounts the biggest first
+		ascending := field == store.SortCustomerID || field == store.SortDeliveryService
+		if sorted {
+			ascending = !sort.Ascending
+		}
+		columns[string(field)] = sortColumn{
+			Link:      browserLink(base, store.OrderSort{Field: field, Ascending: ascending}, ""),
+			Sorted:    sorted,
+			Ascending: sort.Ascending,
+		}
+	}
+
+	var next, first string
+	if page.NextCursor != "" {
+		next = browserLink(base, sort, page.NextCursor)
+	}
+	if q.Cursor != "" {
+		first = browserLink(base, sort, "")
+	}
+
+	s.render(w, r, http.StatusOK, "orders.tmpl", map[string]any{
+		"Orders":  s.redactor.AllForCaller(r.Context(), page.Orders),
+		"Filters": filters,
+		"Sort":    string(sort.Field),
+		"Order":   query.Get("order"),
+		"Columns": columns,
+		"Next":    next,
+		"First":   first,
+
+		// like the sort, the filter would give the redacted customer ids away
+		"CustomerFilter": !s.redactor.HidesForCaller(r.Context(), "customer_id"),
+	})
+}
+
+// browserLink is the /orders link of the filters in base, sorted and at the cursor.
+func browserLink(base url.Values, sort store.OrderSort, cursor string) string {
+	values := url.Values{}
+	for k, v := range base {
+		values[k] = v
+	}
+	if sort.Field != "" {
+		values.Set("sort", string(sort.Field))
+	}
+	if sort.Ascending {
+		values.Set("order", "asc")
+	}
+	if cursor != "" {
+		values.Set("cursor", cursor)
+	}
+	if len(values) == 0 {
+		return "/orders"
+	}
+	return "/orders?" + values.Encode()
+}
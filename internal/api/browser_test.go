@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ordersView(t *testing.T) {
+	mockService := new(MockOrderService)
+	server, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{})
+	require.NoError(t, err)
+	serve := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	page := &store.OrderPage{
+		Orders: []*domain.Order{{
+			OrderUID:        "b563feb7b2b84b6test",
+			CustomerID:      "test",
+			DeliveryService: "meest",
+			DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+			Payment:         domain.Payment{Amount: 1817, Currency: "USD"},
+			Items:           []domain.Item{{ChrtID: 1}},
+		}},
+		NextCursor: "next",
+	}
+
+	t.Run("first page", func(t *testing.T) {
+		mockService.On("ListOrders", mock.Anything, store.ListQuery{}).Return(page, nil).Once()
+
+		rr := serve("/orders")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		body := rr.Body.String()
+		assert.Contains(t, body, `href="/orders/b563feb7b2b84b6test"`)
+		assert.Contains(t, body, "2021-11-26 06:22:19")
+		assert.Contains(t, body, "1817 USD")
+		assert.Contains(t, body, `href="/orders?cursor=next"`)
+		assert.NotContains(t, body, "First page")
+		// sorted by date, newest first: its header flips the order, the text columns start a to z
+		assert.Contains(t, body, `href="/orders?order=asc&amp;sort=date_created">Date Created &darr;`)
+		assert.Contains(t, body, `href="/orders?order=asc&amp;sort=customer_id">Customer</a>`)
+		assert.Contains(t, body, `href="/orders?sort=amount">Amount</a>`)
+	})
+
+	t.Run("filtered and sorted", func(t *testing.T) {
+		want := store.ListQuery{
+			OrderFilter: store.OrderFilter{
+				CustomerID:  "test",
+				Currency:    "usd",
+				CreatedFrom: time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Sort:   store.OrderSort{Field: store.SortAmount, Ascending: true},
+			Cursor: "abc",
+		}
+		mockService.On("ListOrders", mock.Anything, want).Return(page, nil).Once()
+
+		rr := serve("/orders?customer_id=test&currency=usd&created_from=2021-11-01&sort=amount&order=asc&cursor=abc")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		body := rr.Body.String()
+		assert.Contains(t, body, `value="test"`)
+		assert.Contains(t, body, `value="2021-11-01"`)
+		// the pagination keeps the filters and the sort, the sort links start over
+		assert.Contains(t, body, `href="/orders?created_from=2021-11-01&amp;currency=usd&amp;cursor=next&amp;customer_id=test&amp;order=asc&amp;sort=amount"`)
+		assert.Contains(t, body, `href="/orders?created_from=2021-11-01&amp;currency=usd&amp;customer_id=test&amp;order=asc&amp;sort=amount">&larr; First page`)
+		assert.Contains(t, body, `href="/orders?created_from=2021-11-01&amp;currency=usd&amp;customer_id=test&amp;sort=amount">Amount &uarr;`)
+	})
+
+	t.Run("bad input", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("/orders?sort=bank").Code)
+		assert.Equal(t, http.StatusBadRequest, serve("/orders?created_to=tomorrow").Code)
+
+		mockService.On("ListOrders", mock.Anything, store.ListQuery{Cursor: "garbage"}).Return(nil, store.ErrInvalidCursor).Once()
+		assert.Equal(t, http.StatusBadRequest, serve("/orders?cursor=garbage").Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/service"
+)
+
+// CacheAdmin is the cache as the admin endpoints see it.
+// The *service.CachingOrderService implements it.
+type CacheAdmin interface {
+	CacheStats() service.CacheStats
+	IsCached(uid string) bool
+	Evict(uid string) bool
+	Purge() int
+	Warm(ctx context.Context, limit int) (int, error)
+}
+
+// WithCacheAdmin enables the /api/v1/admin/cache endpoints, a warm-up with no
+// limit of its own loads warmLimit orders. Without it, or without auth, they are a 404.
+func WithCacheAdmin(cache CacheAdmin, warmLimit int) Option {
+	return func(s *Server) {
+		s.cacheAdmin = cache
+		s.cacheWarmLimit = warmLimit
+	}
+}
+
+// cacheWarmRequest is the (optional) body of POST /api/v1/admin/cache:warm.
+type cacheWarmRequest struct {
+	Limit int `json:"limit"`
+}
+
+// adminLog logs an admin action together with whoever took it.
+func (s *Server) adminLog(r *http.Request, msg string, keysAndValues ...any) {
+	s.requestLogger(r).Infow(msg, append([]any{"caller", auth.FromContext(r.Context()).String()}, keysAndValues...)...)
+}
+
+// decodeAdminBody decodes the JSON body into v, answering with a 400 if it's malformed.
+// An empty body leaves v as it is.
+func (s *Server) decodeAdminBody(w http.ResponseWriter, r *http.Request, v any, expected string) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("expected %s: %v", expected, err))
+		return false
+	}
+	return true
+}
+
+// cacheAdminEnabled answers with a 404 if the cache admin is off.
+func (s *Server) cacheAdminEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if s.cacheAdmin == nil {
+		s.writeError(w, r, http.StatusNotFound, "the cache admin is not enabled")
+		return false
+	}
+	return true
+}
+
+// apiCacheStats is GET /api/v1/admin/cache, a snapshot of the cache: its entries,
+// their estimated size, the hit ratio and the least and most recently used keys.
+func (s *Server) apiCacheStats(w http.ResponseWriter, r *http.Request) {
+	if !s.cacheAdminEnabled(w, r) {
+		return
+	}
+
+	stats := s.cacheAdmin.CacheStats()
+	s.adminLog(r, "Cache stats requested", "entries", stats.Entries)
+	s.writeJSON(w, r, http.StatusOK, stats)
+}
+
+// apiCacheLookup is GET /api/v1/admin/cache/{uid}, whether the order is cached.
+// The lookup doesn't count as a hit nor refreshes the entry.
+func (s *Server) apiCacheLookup(w http.ResponseWriter, r *http.Request) {
+	if !s.cacheAdminEnabled(w, r) {
+		return
+	}
+	uid := r.PathValue("uid")
+	if !transport.ValidUID(uid) {
+		s.writeError(w, r, http.StatusBadRequest, "order uid must be a non-empty alphanumeric string")
+		return
+	}
+
+	cached := s.cacheAdmin.IsCached(uid)
+	s.adminLog(r, "Cache lookup", "order_uid", uid, "cached", cached)
+	s.writeJSON(w, r, http.StatusOK, map[string]any{"order_uid": uid, "cached": cached})
+}
+
+// apiCacheEvict is DELETE /api/v1/admin/cache/{uid}: 204 once the order is out of the
+// cache, 404 if it wasn't in there.
+func (s *Server) apiCacheEvict(w http.ResponseWriter, r *http.Request) {
+	if !s.cacheAdminEnabled(w, r) {
+		return
+	}
+	uid := r.PathValue("uid")
+	if !transport.ValidUID(uid) {
+		s.writeError(w, r, http.StatusBadRequest, "order uid must be a non-empty alphanumeric string")
+		return
+	}
+
+	evicted := s.cacheAdmin.Evict(uid)
+	s.adminLog(r, "Cache entry evicted", "order_uid", uid, "evicted", evicted)
+	if !evicted {
+		s.writeError(w, r, http.StatusNotFound, "the order is not cached")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCachePurge is DELETE /api/v1/admin/cache, it empties the whole cache.
+func (s *Server) apiCachePurge(w http.ResponseWriter, r *http.Request) {
+	if !s.cacheAdminEnabled(w, r) {
+		return
+	}
+
+	n := s.cacheAdmin.Purge()
+	s.adminLog(r, "Cache purged", "evicted", n)
+	s.writeJSON(w, r, http.StatusOK, map[string]int{"evicted": n})
+}
+
+// apiCacheWarm is POST /api/v1/admin/cache:warm, it loads the latest orders into the cache
+// like the preload at startup does. The body, {"limit": n}, is optional: the preload's
+// size is used without it, the limit is capped at what the cache can hold anyway.
+func (s *Server) apiCacheWarm(w http.ResponseWriter, r *http.Request) {
+	if !s.cacheAdminEnabled(w, r) || !s.isJSONRequest(w, r) {
+		return
+	}
+
+	req := cacheWarmRequest{Limit: s.cacheWarmLimit}
+	if !s.decodeAdminBody(w, r, &req, `{"limit": n}`) {
+		return
+	}
+	if req.Limit <= 0 {
+		s.writeError(w, r, http.StatusBadRequest, "limit must be positive")
+		return
+	}
+
+	n, err := s.cacheAdmin.Warm(r.Context(), req.Limit)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+	s.adminLog(r, "Cache warmed up", "limit", req.Limit, "loaded", n)
+	s.writeJSON(w, r, http.StatusOK, map[string]int{"loaded": n})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/service"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCache is a CacheAdmin over a set of uids.
+type fakeCache struct {
+	uids      map[string]bool
+	warmLimit int
+	warmErr   error
+}
+
+func (c *fakeCache) CacheStats() service.CacheStats {
+	return service.CacheStats{Entries: len(c.uids), EntryCountCap: 10}
+}
+func (c *fakeCache) IsCached(uid string) bool { return c.uids[uid] }
+
+func (c *fakeCache) Evict(uid string) bool {
+	ok := c.uids[uid]
+	delete(c.uids, uid)
+	return ok
+}
+
+func (c *fakeCache) Purge() int {
+	n := len(c.uids)
+	clear(c.uids)
+	return n
+}
+
+func (c *fakeCache) Warm(ctx context.Context, limit int) (int, error) {
+	c.warmLimit = limit
+	return min(limit, 10), c.warmErr
+}
+
+// infoRecorder keeps the info entries, the rest goes nowhere.
+type infoRecorder struct {
+	logger.MockLogger
+	mu    sync.Mutex
+	infos [][]any
+}
+
+func (l *infoRecorder) With(keysAndValues ...any) logger.Logger { return l }
+
+func (l *infoRecorder) Infow(msg string, keysAndValues ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.infos = append(l.infos, append([]any{msg}, keysAndValues...))
+}
+
+func TestServer_cacheAdmin(t *testing.T) {
+	newServer := func(t *testing.T, cache CacheAdmin, log logger.Logger) *Server {
+		opts := []Option{withTestAdmin(t)}
+		if cache != nil {
+			opts = append(opts, WithCacheAdmin(cache, 5))
+		}
+		server, err := NewServer(new(MockOrderService), log, config.HTTPServerConfig{}, opts...)
+		require.NoError(t, err)
+		return server
+	}
+	serve := func(s *Server, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", testAdminKey)
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("stats", func(t *testing.T) {
+		rr := serve(newServer(t, &fakeCache{uids: map[string]bool{"one": true}}, logger.NewMockLogger()), "GET", "/api/v1/admin/cache", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var stats service.CacheStats
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+		assert.Equal(t, 1, stats.Entries)
+		assert.Equal(t, 10, stats.EntryCountCap)
+	})
+
+	t.Run("lookup and evict", func(t *testing.T) {
+		server := newServer(t, &fakeCache{uids: map[string]bool{"one": true}}, logger.NewMockLogger())
+
+		rr := serve(server, "GET", "/api/v1/admin/cache/one", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"order_uid": "one", "cached": true}`, rr.Body.String())
+
+		assert.Equal(t, http.StatusNoContent, serve(server, "DELETE", "/api/v1/admin/cache/one", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(server, "DELETE", "/api/v1/admin/cache/one", "").Code)
+
+		rr = serve(server, "GET", "/api/v1/admin/cache/one", "")
+		assert.JSONEq(t, `{"order_uid": "one", "cached": false}`, rr.Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, serve(server, "GET", "/api/v1/admin/cache/not-a-uid", "").Code)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		cache := &fakeCache{uids: map[string]bool{"one": true, "two": true}}
+		rr := serve(newServer(t, cache, logger.NewMockLogger()), "DELETE", "/api/v1/admin/cache", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"evicted": 2}`, rr.Body.String())
+		assert.Empty(t, cache.uids)
+	})
+
+	t.Run("warm", func(t *testing.T) {
+		cache := &fakeCache{}
+		server := newServer(t, cache, logger.NewMockLogger())
+
+		// without a body the preload size is used
+		rr := serve(server, "POST", "/api/v1/admin/cache:warm", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"loaded": 5}`, rr.Body.String())
+		assert.Equal(t, 5, cache.warmLimit)
+
+		rr = serve(server, "POST", "/api/v1/admin/cache:warm", `{"limit": 50}`)
+		assert.JSONEq(t, `{"loaded": 10}`, rr.Body.String())
+		assert.Equal(t, 50, cache.warmLimit)
+
+		assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/cache:warm", `{"limit": -1}`).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/cache:warm", `{"size": 1}`).Code)
+
+		cache.warmErr = store.ErrConnectionFailed
+		assert.Equal(t, http.StatusServiceUnavailable, serve(server, "POST", "/api/v1/admin/cache:warm", "").Code)
+	})
+
+	t.Run("actions are logged with the caller", func(t *testing.T) {
+		log := &infoRecorder{}
+		server := newServer(t, &fakeCache{uids: map[string]bool{}}, log)
+		serve(server, "DELETE", "/api/v1/admin/cache", "")
+
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		var found bool
+		for _, entry := range log.infos {
+			if entry[0] == "Cache purged" {
+				found = true
+				assert.Equal(t, []any{"caller", "api_key:ops(admin)"}, entry[1:3])
+			}
+		}
+		assert.True(t, found, "the purge was not logged")
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(newServer(t, nil, logger.NewMockLogger()), "GET", "/api/v1/admin/cache", "").Code)
+	})
+
+	t.Run("not without auth", func(t *testing.T) {
+		log := &warnRecorder{}
+		server, err := NewServer(new(MockOrderService), log, config.HTTPServerConfig{}, WithAnonymousAdmin(), WithCacheAdmin(&fakeCache{}, 5))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, serve(server, "DELETE", "/api/v1/admin/cache", "").Code)
+		assert.Len(t, log.warns, 1)
+	})
+}
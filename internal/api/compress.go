@@ -0,0 +1,258 @@
+package api
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultCompressMinSize is the fallback for the zero value of config.CompressionConfig.MinSizeBytes
+const defaultCompressMinSize = 1024
+
+// supported content codings, in order of preference on ties
+const (
+	encodingZstd = "zstd"
+	encodingGzip = "gzip"
+)
+
+var gzipPool = sync.Pool{
+	New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	},
+}
+
+var zstdPool = sync.Pool{
+	New: func() any {
+		// one goroutine per encoder, the concurrency comes from the requests
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return w
+	},
+}
+
+// compressionMiddleware compresses the responses of the clients that accept gzip or zstd.
+// Bodies shorter than minSize and content types that are compressed already go out as is.
+// It sits inside metricsMiddleware: the status code is passed down unchanged, only later.
+func compressionMiddleware(next http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		// a compressed 206 would be a range of the compressed body, which is not what was asked for
+		if encoding == "" || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		cw.Close()
+	})
+}
+
+// negotiateEncoding picks the content coding for the Accept-Encoding header,
+// "" if the response should not be compressed.
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{encodingZstd, encodingGzip} {
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response of the content type is worth compressing.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(contentType)
+	}
+
+	switch {
+	case mediaType == "text/event-stream":
+		// every event has to reach the client as soon as it's written
+		return false
+	case mediaType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "font/woff"):
+		return false
+	}
+
+	switch mediaType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+		"application/x-bzip2", "application/x-xz", "application/x-7z-compressed",
+		"application/pdf", "application/vnd.apache.parquet":
+		return false
+	}
+	return true
+}
+
+// compressWriter holds back the status and the first minSize bytes of the body, once there's
+// enough of it (or the handler flushes or finishes) it decides whether to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser // nil if the response is not compressed
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		// informational responses go out right away, the final one is yet to come
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// FlushError sends out what's been written so far, compressing it if the response is big
+// enough or streamed. http.ResponseController prefers it to Flush.
+func (w *compressWriter) FlushError() error {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := w.enc.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (deadlines, full duplex).
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close finishes the response once the handler has returned.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		// the whole body is buffered and it's short
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+
+	err := w.enc.Close()
+	switch enc := w.enc.(type) {
+	case *gzip.Writer:
+		enc.Reset(nil)
+		gzipPool.Put(enc)
+	case *zstd.Encoder:
+		enc.Reset(nil)
+		zstdPool.Put(enc)
+	}
+	w.enc = nil
+	return err
+}
+
+// decide writes the status and the buffered part of the body, through an encoder if
+// wanted is true and the response can be compressed.
+func (w *compressWriter) decide(wanted bool) error {
+	w.decided = true
+	h := w.Header()
+
+	// otherwise http would sniff the compressed bytes
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if wanted && len(w.buf) > 0 && bodyAllowed(w.status) &&
+		h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+
+		switch w.encoding {
+		case encodingZstd:
+			enc := zstdPool.Get().(*zstd.Encoder)
+			enc.Reset(w.ResponseWriter)
+			w.enc = enc
+		default:
+			enc := gzipPool.Get().(*gzip.Writer)
+			enc.Reset(w.ResponseWriter)
+			w.enc = enc
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_negotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", encodingGzip},
+		{"gzip, deflate, br", encodingGzip},
+		{"gzip, deflate, br, zstd", encodingZstd},
+		{"zstd;q=0.5, gzip", encodingGzip},
+		{"gzip;q=0", ""},
+		{"*", encodingZstd},
+		{"*;q=0.1, gzip;q=0.8", encodingGzip},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.acceptEncoding, func(t *testing.T) {
+			assert.Equal(t, tc.want, negotiateEncoding(tc.acceptEncoding))
+		})
+	}
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case encodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		r = gr
+	case encodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	default:
+		return string(body)
+	}
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func Test_compressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"order_uid":"b563feb7b2b84b6test"}`, 100)
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+		wantEncoding   string
+	}{
+		{"gzip", "gzip", "application/json", http.StatusOK, large, encodingGzip},
+		{"zstd", "gzip, zstd", "application/json", http.StatusOK, large, encodingZstd},
+		{"not accepted", "", "application/json", http.StatusOK, large, ""},
+		{"under the threshold", "gzip", "application/json", http.StatusOK, `{"order_uid":"x"}`, ""},
+		{"already compressed", "gzip", "image/jpeg", http.StatusOK, large, ""},
+		{"event stream", "gzip", "text/event-stream", http.StatusOK, large, ""},
+		{"error status", "gzip", "text/plain; charset=utf-8", http.StatusNotFound, large, encodingGzip},
+		{"sniffed type", "gzip", "", http.StatusOK, large, encodingGzip},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+				w.WriteHeader(tc.status)
+				// in pieces, the decision is taken half way through
+				io.WriteString(w, tc.body[:len(tc.body)/2])
+				io.WriteString(w, tc.body[len(tc.body)/2:])
+			}), defaultCompressMinSize)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			rec := &statusRecorder{ResponseWriter: rr, status: http.StatusOK}
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.status, rec.status, "the metrics must see the handler's status")
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.wantEncoding, rr.Header().Get("Content-Encoding"))
+			assert.Contains(t, rr.Header().Values("Vary"), "Accept-Encoding")
+			assert.NotEmpty(t, rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.body, decompress(t, tc.wantEncoding, rr.Body.Bytes()))
+		})
+	}
+
+	t.Run("not modified", func(t *testing.T) {
+		handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotModified)
+		}), defaultCompressMinSize)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Empty(t, rr.Body.String())
+	})
+
+	t.Run("flushed stream", func(t *testing.T) {
+		handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			rc := http.NewResponseController(w)
+			for range 3 {
+				io.WriteString(w, `{"status":"stored"}`+"\n")
+				require.NoError(t, rc.Flush())
+			}
+		}), defaultCompressMinSize)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.True(t, rr.Flushed)
+		assert.Equal(t, encodingGzip, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, strings.Repeat(`{"status":"stored"}`+"\n", 3), decompress(t, encodingGzip, rr.Body.Bytes()))
+	})
+}
+
+func TestServer_compressedStaticFiles(t *testing.T) {
+	cfg := config.HTTPServerConfig{Compression: config.CompressionConfig{Enabled: true}}
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), cfg)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/static/css/main.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, encodingGzip, rr.Header().Get("Content-Encoding"))
+	assert.Empty(t, rr.Header().Get("Content-Length"))
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/css")
+	assert.Contains(t, decompress(t, encodingGzip, rr.Body.Bytes()), "order-formats")
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/consumer"
+)
+
+// ConsumerAdmin is the kafka consumer as the admin endpoints see it.
+// The *consumer.KafkaConsumer implements it.
+type ConsumerAdmin interface {
+	Status(ctx context.Context) (consumer.Status, error)
+	Pause(ctx context.Context, partitions []int32) error
+	Resume(ctx context.Context, partitions []int32) error
+	Seek(ctx context.Context, partition int32, offset int64) error
+	SeekToTime(ctx context.Context, partition int32, t time.Time) (int64, error)
+	SetWorkers(ctx context.Context, n int) error
+}
+
+// WithConsumerAdmin enables the /api/v1/admin/consumer endpoints. Without it, or without
+// auth, they are a 404.
+func WithConsumerAdmin(c ConsumerAdmin) Option {
+	return func(s *Server) {
+		s.consumerAdmin = c
+	}
+}
+
+// partitionsRequest is the (optional) body of the pause and the resume,
+// no partitions means all of them.
+type partitionsRequest struct {
+	Partitions []int32 `json:"partitions"`
+}
+
+// seekRequest is the body of POST /api/v1/admin/consumer:seek, it has either an offset or a timestamp.
+type seekRequest struct {
+	Partition *int32     `json:"partition"`
+	Offset    *int64     `json:"offset"`
+	Timestamp *time.Time `json:"timestamp"`
+}
+
+// resizeRequest is the body of POST /api/v1/admin/consumer:resize.
+type resizeRequest struct {
+	Workers int `json:"workers"`
+}
+
+// consumerAdminEnabled answers with a 404 if the consumer admin is off.
+func (s *Server) consumerAdminEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if s.consumerAdmin == nil {
+		s.writeError(w, r, http.StatusNotFound, "the consumer admin is not enabled")
+		return false
+	}
+	return true
+}
+
+// consumerError maps the errors of the consumer to a response.
+func (s *Server) consumerError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, consumer.ErrNotRunning):
+		w.Header().Set("Retry-After", "5")
+		s.writeError(w, r, http.StatusServiceUnavailable, consumer.ErrNotRunning.Error())
+	case errors.Is(err, consumer.ErrNotAssigned):
+		s.writeError(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, consumer.ErrInvalidOffset), errors.Is(err, consumer.ErrInvalidWorkerCount):
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+	default:
+		s.requestLogger(r).Errorw("server error", "error", err, "request_method", r.Method, "request_uri", r.URL.RequestURI())
+		s.writeError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+}
+
+// apiConsumerStatus is GET /api/v1/admin/consumer: the worker count, what's paused
+// and the committed offset, the watermarks and the lag of every assigned partition.
+func (s *Server) apiConsumerStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.consumerAdminEnabled(w, r) {
+		return
+	}
+
+	st, err := s.consumerAdmin.Status(r.Context())
+	if err != nil {
+		s.consumerError(w, r, err)
+		return
+	}
+	s.adminLog(r, "Consumer status requested", "workers", st.Workers)
+	s.writeJSON(w, r, http.StatusOK, st)
+}
+
+// apiConsumerPause is POST /api/v1/admin/consumer:pause, it pauses the partitions
+// of {"partitions": [...]}, all of them without a body. They stay paused until resumed.
+func (s *Server) apiConsumerPause(w http.ResponseWriter, r *http.Request) {
+	if !s.consumerAdminEnabled(w, r) || !s.isJSONRequest(w, r) {
+		return
+	}
+	var req partitionsRequest
+	if !s.decodeAdminBody(w, r, &req, `{"partitions": [...]}`) {
+		return
+	}
+
+	if err := s.consumerAdmin.Pause(r.Context(), req.Partitions); err != nil {
+		s.consumerError(w, r, err)
+		return
+	}
+	s.adminLog(r, "Consumer paused", "partitions", req.Partitions)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiConsumerResume is POST /api/v1/admin/consumer:resume, the counterpart of the pause.
+func (s *Server) apiConsumerResume(w http.ResponseWriter, r *http.Request) {
+	if !s.consumerAdminEnabled(w, r) || !s.isJSONRequest(w, r) {
+		return
+	}
+	var req partitionsRequest
+	if !s.decodeAdminBody(w, r, &req, `{"partitions": [...]}`) {
+		return
+	}
+
+	if err := s.consumerAdmin.Resume(r.Context(), req.Partitions); err != nil {
+		s.consumerError(w, r, err)
+		return
+	}
+	s.adminLog(r, "Consumer resumed", "partitions", req.Partitions)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiConsumerSeek is POST /api/v1/admin/consumer:seek, it moves a partition to
+// {"partition": p, "offset": n} or to the first message at or after
+// {"partition": p, "timestamp": "RFC 3339"}, and answers with the offset.
+func (s *Server) apiConsumerSeek(w http.ResponseWriter, r *http.Request) {
+	if !s.consumerAdminEnabled(w, r) || !s.isJSONRequest(w, r) {
+		return
+	}
+	var req seekRequest
+	if !s.decodeAdminBody(w, r, &req, `{"partition": p, "offset": n} or {"partition": p, "timestamp": t}`) {
+		return
+	}
+	if req.Partition == nil || (req.Offset == nil) == (req.Timestamp == nil) {
+		s.writeError(w, r, http.StatusBadRequest, "a partition and either an offset or a timestamp are required")
+		return
+	}
+
+	var (
+		offset int64
+		err    error
+	)
+	if req.Offset != nil {
+		offset = *req.Offset
+		err = s.consumerAdmin.Seek(r.Context(), *req.Partition, offset)
+	} else {
+		offset, err = s.consumerAdmin.SeekToTime(r.Context(), *req.Partition, *req.Timestamp)
+	}
+	if err != nil {
+		s.consumerError(w, r, err)
+		return
+	}
+	s.adminLog(r, "Consumer partition sought", "partition", *req.Partition, "offset", offset, "timestamp", req.Timestamp)
+	s.writeJSON(w, r, http.StatusOK, map[string]any{"partition": *req.Partition, "offset": offset})
+}
+
+// apiConsumerResize is POST /api/v1/admin/consumer:resize, it sets the worker
+// count to {"workers": n}, up to consumer.MaxWorkers.
+func (s *Server) apiConsumerResize(w http.ResponseWriter, r *http.Request) {
+	if !s.consumerAdminEnabled(w, r) || !s.isJSONRequest(w, r) {
+		return
+	}
+	var req resizeRequest
+	if !s.decodeAdminBody(w, r, &req, `{"workers": n}`) {
+		return
+	}
+
+	if err := s.consumerAdmin.SetWorkers(r.Context(), req.Workers); err != nil {
+		s.consumerError(w, r, err)
+		return
+	}
+	s.adminLog(r, "Consumer workers resized", "workers", req.Workers)
+	s.writeJSON(w, r, http.StatusOK, map[string]int{"workers": req.Workers})
+}
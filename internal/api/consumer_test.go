@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/consumer"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsumer is a ConsumerAdmin assigned the partitions 0 and 1, it records the last call.
+type fakeConsumer struct {
+	running    bool
+	paused     []int32
+	resumed    []int32
+	seekOffset int64
+	seekTime   time.Time
+	workers    int
+}
+
+func (c *fakeConsumer) check(partitions ...int32) error {
+	if !c.running {
+		return consumer.ErrNotRunning
+	}
+	for _, p := range partitions {
+		if p > 1 {
+			return consumer.ErrNotAssigned
+		}
+	}
+	return nil
+}
+
+func (c *fakeConsumer) Status(ctx context.Context) (consumer.Status, error) {
+	return consumer.Status{Workers: c.workers, Partitions: []consumer.PartitionStatus{{Partition: 0, Lag: 3}}}, c.check()
+}
+
+func (c *fakeConsumer) Pause(ctx context.Context, partitions []int32) error {
+	c.paused = partitions
+	return c.check(partitions...)
+}
+
+func (c *fakeConsumer) Resume(ctx context.Context, partitions []int32) error {
+	c.resumed = partitions
+	return c.check(partitions...)
+}
+
+func (c *fakeConsumer) Seek(ctx context.Context, partition int32, offset int64) error {
+	c.seekOffset = offset
+	return c.check(partition)
+}
+
+func (c *fakeConsumer) SeekToTime(ctx context.Context, partition int32, t time.Time) (int64, error) {
+	c.seekTime = t
+	return 42, c.check(partition)
+}
+
+func (c *fakeConsumer) SetWorkers(ctx context.Context, n int) error {
+	if n < 1 || n > consumer.MaxWorkers {
+		return consumer.ErrInvalidWorkerCount
+	}
+	c.workers = n
+	return c.check()
+}
+
+func TestServer_consumerAdmin(t *testing.T) {
+	newServer := func(t *testing.T, c ConsumerAdmin) *Server {
+		opts := []Option{withTestAdmin(t)}
+		if c != nil {
+			opts = append(opts, WithConsumerAdmin(c))
+		}
+		server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, opts...)
+		require.NoError(t, err)
+		return server
+	}
+	serve := func(s *Server, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", testAdminKey)
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("status", func(t *testing.T) {
+		rr := serve(newServer(t, &fakeConsumer{running: true, workers: 4}), "GET", "/api/v1/admin/consumer", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var st consumer.Status
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &st))
+		assert.Equal(t, 4, st.Workers)
+		require.Len(t, st.Partitions, 1)
+		assert.EqualValues(t, 3, st.Partitions[0].Lag)
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		c := &fakeConsumer{running: true}
+		server := newServer(t, c)
+
+		assert.Equal(t, http.StatusNoContent, serve(server, "POST", "/api/v1/admin/consumer:pause", "").Code)
+		assert.Nil(t, c.paused)
+		assert.Equal(t, http.StatusNoContent, serve(server, "POST", "/api/v1/admin/consumer:pause", `{"partitions": [1]}`).Code)
+		assert.Equal(t, []int32{1}, c.paused)
+		assert.Equal(t, http.StatusConflict, serve(server, "POST", "/api/v1/admin/consumer:pause", `{"partitions": [5]}`).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/consumer:pause", `{"partition": 1}`).Code)
+
+		assert.Equal(t, http.StatusNoContent, serve(server, "POST", "/api/v1/admin/consumer:resume", `{"partitions": [0, 1]}`).Code)
+		assert.Equal(t, []int32{0, 1}, c.resumed)
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		c := &fakeConsumer{running: true}
+		server := newServer(t, c)
+
+		rr := serve(server, "POST", "/api/v1/admin/consumer:seek", `{"partition": 0, "offset": 100}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"partition": 0, "offset": 100}`, rr.Body.String())
+		assert.EqualValues(t, 100, c.seekOffset)
+
+		rr = serve(server, "POST", "/api/v1/admin/consumer:seek", `{"partition": 1, "timestamp": "2021-11-26T06:22:19Z"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"partition": 1, "offset": 42}`, rr.Body.String())
+		assert.True(t, c.seekTime.Equal(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)))
+
+		for _, body := range []string{
+			`{"offset": 100}`,
+			`{"partition": 0}`,
+			`{"partition": 0, "offset": 1, "timestamp": "2021-11-26T06:22:19Z"}`,
+			`{"partition": 0, "timestamp": "yesterday"}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/consumer:seek", body).Code, body)
+		}
+	})
+
+	t.Run("resize", func(t *testing.T) {
+		c := &fakeConsumer{running: true}
+		server := newServer(t, c)
+
+		rr := serve(server, "POST", "/api/v1/admin/consumer:resize", `{"workers": 8}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 8, c.workers)
+
+		assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/consumer:resize", "").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/consumer:resize", `{"workers": 1000}`).Code)
+	})
+
+	t.Run("not running", func(t *testing.T) {
+		rr := serve(newServer(t, &fakeConsumer{}), "POST", "/api/v1/admin/consumer:pause", "")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "5", rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(newServer(t, nil), "GET", "/api/v1/admin/consumer", "").Code)
+	})
+
+	t.Run("not without auth", func(t *testing.T) {
+		c := &fakeConsumer{running: true}
+		server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithAnonymousAdmin(), WithConsumerAdmin(c))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, serve(server, "POST", "/api/v1/admin/consumer:pause", "").Code)
+		assert.Nil(t, c.paused)
+	})
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/export"
+)
+
+// writeItemsCSV writes the items of the order as a CSV attachment.
+func (s *Server) writeItemsCSV(w http.ResponseWriter, r *http.Request, order *domain.Order) {
+	buf := new(bytes.Buffer)
+	cw := csv.NewWriter(buf)
+	cw.Write(export.ItemsCSVHeader)
+	for _, it := range order.Items {
+		cw.Write(export.ItemCSVRecord(order.OrderUID, it))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="order_%s_items.csv"`, order.OrderUID))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
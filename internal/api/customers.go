@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// CustomerSource sums up the orders of a customer, the *store.DBStore implements it.
+type CustomerSource interface {
+	CustomerSummary(ctx context.Context, customerID string) (*store.CustomerSummary, error)
+}
+
+// WithCustomers enables the customer history: /api/v1/customers/{id}/orders and the
+// /customers/{id} page. Without it they are a 404.
+func WithCustomers(src CustomerSource) Option {
+	return func(s *Server) {
+		s.customers = src
+	}
+}
+
+// customerOrders is the response of GET /api/v1/customers/{id}/orders.
+type customerOrders struct {
+	Customer   *store.CustomerSummary `json:"customer"`
+	Orders     []*domain.Order        `json:"orders"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// customerHistory reads the summary and a page of the orders of the {id} customer,
+// the cursor and the page size are taken from the query parameters.
+func (s *Server) customerHistory(r *http.Request) (*customerOrders, error) {
+	customerID := r.PathValue("id")
+	summary, err := s.customers.CustomerSummary(r.Context(), customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	q := store.ListQuery{OrderFilter: store.OrderFilter{CustomerID: customerID}, Cursor: query.Get("cursor")}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			return nil, errInvalidLimit
+		}
+	}
+	page, err := s.service.ListOrders(r.Context(), q)
+	if err != nil {
+		return nil, err
+	}
+
+	return &customerOrders{
+		Customer:   summary,
+		Orders:     s.redactor.AllForCaller(r.Context(), page.Orders),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// errInvalidLimit is the 'limit' query parameter that's not a page size.
+var errInvalidLimit = errors.New("limit must be a positive integer")
+
+// apiCustomerOrders is GET /api/v1/customers/{id}/orders, the customer's summary (the
+// order count, the first and the last order, the totals per currency and the delivery
+// services used) and a page of their orders, newest first, paginated like GET /api/v1/orders.
+func (s *Server) apiCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	if s.customers == nil {
+		s.writeError(w, r, http.StatusNotFound, "the customer history is not enabled")
+		return
+	}
+
+	history, err := s.customerHistory(r)
+	if err != nil {
+		if errors.Is(err, errInvalidLimit) {
+			s.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.storeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, history)
+}
+
+// customerView is the /customers/{id} page, what apiCustomerOrders returns as a table.
+func (s *Server) customerView(w http.ResponseWriter, r *http.Request) {
+	if s.customers == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	history, err := s.customerHistory(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidLimit), errors.Is(err, store.ErrInvalidCursor):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, store.ErrNotFound):
+			s.render(w, r, http.StatusNotFound, "customer.tmpl", map[string]any{"CustomerID": r.PathValue("id")})
+		default:
+			s.serverError(w, r, err)
+		}
+		return
+	}
+
+	var next string
+	if history.NextCursor != "" {
+		next = "/customers/" + url.PathEscape(r.PathValue("id")) + "?" + url.Values{"cursor": {history.NextCursor}}.Encode()
+	}
+	s.render(w, r, http.StatusOK, "customer.tmpl", map[string]any{
+		"CustomerID": r.PathValue("id"),
+		"Found":      true,
+		"Customer":   history.Customer,
+		"Orders":     history.Orders,
+		"Next":       next,
+	})
+}
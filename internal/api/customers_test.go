@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCustomers knows the summary of the customer 'test' only.
+type fakeCustomers struct{}
+
+func (fakeCustomers) CustomerSummary(ctx context.Context, customerID string) (*store.CustomerSummary, error) {
+	if customerID != "test" {
+		return nil, store.ErrNotFound
+	}
+	at := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	return &store.CustomerSummary{
+		CustomerID:       "test",
+		Orders:           2,
+		FirstOrderAt:     at,
+		LastOrderAt:      at.AddDate(0, 1, 0),
+		Totals:           []store.CurrencyTotal{{Currency: "USD", Orders: 2, Amount: 3634}},
+		DeliveryServices: []store.DeliveryServiceUsage{{DeliveryService: "meest", Orders: 2}},
+	}, nil
+}
+
+func TestServer_customers(t *testing.T) {
+	mockService := new(MockOrderService)
+	page := &store.OrderPage{
+		Orders: []*domain.Order{
+			{OrderUID: "two", CustomerID: "test", DeliveryService: "meest", Payment: domain.Payment{Amount: 1817, Currency: "USD"}},
+			{OrderUID: "one", CustomerID: "test", DeliveryService: "meest", Payment: domain.Payment{Amount: 1817, Currency: "USD"}},
+		},
+		NextCursor: "next",
+	}
+	mockService.On("ListOrders", mock.Anything, store.ListQuery{OrderFilter: store.OrderFilter{CustomerID: "test"}, Limit: 2}).Return(page, nil)
+	mockService.On("ListOrders", mock.Anything, store.ListQuery{OrderFilter: store.OrderFilter{CustomerID: "test"}}).Return(page, nil)
+	mockService.On("GetOrder", mock.Anything, "two").Return(page.Orders[0], nil)
+
+	server, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{}, WithCustomers(fakeCustomers{}), WithAnonymousAdmin())
+	require.NoError(t, err)
+	serve := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	t.Run("api", func(t *testing.T) {
+		rr := serve("/api/v1/customers/test/orders?limit=2")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var body customerOrders
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		require.NotNil(t, body.Customer)
+		assert.Equal(t, 2, body.Customer.Orders)
+		assert.Equal(t, []store.CurrencyTotal{{Currency: "USD", Orders: 2, Amount: 3634}}, body.Customer.Totals)
+		require.Len(t, body.Orders, 2)
+		assert.Equal(t, "two", body.Orders[0].OrderUID)
+		assert.Equal(t, "next", body.NextCursor)
+
+		assert.Equal(t, http.StatusNotFound, serve("/api/v1/customers/nobody/orders").Code)
+		assert.Equal(t, http.StatusBadRequest, serve("/api/v1/customers/test/orders?limit=0").Code)
+	})
+
+	t.Run("page", func(t *testing.T) {
+		rr := serve("/customers/test")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "3634 USD (2)")
+		assert.Contains(t, rr.Body.String(), `href="/orders/two"`)
+		assert.Contains(t, rr.Body.String(), `href="/customers/test?cursor=next"`)
+
+		rr = serve("/customers/nobody")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Contains(t, rr.Body.String(), "has no orders")
+	})
+
+	t.Run("order page links support to the customer", func(t *testing.T) {
+		linked := serve("/orders/two")
+		assert.Contains(t, linked.Body.String(), `href="/customers/test"`)
+
+		viewers, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{}, WithCustomers(fakeCustomers{}))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		viewers.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/two", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "test")
+		assert.NotContains(t, rr.Body.String(), `href="/customers/`)
+		assert.NotEqual(t, linked.Header().Get("ETag"), rr.Header().Get("ETag"), "the pages differ, so must their tags")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		server, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{}, WithAnonymousAdmin())
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/customers/test/orders", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/goinginblind/l0-task/internal/dlq"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// maxDLQBatch caps the ids of a single replay or discard.
+const maxDLQBatch = store.MaxListLimit
+
+// DLQBrowser is the dead-letter queue as the admin endpoints and pages see it.
+// The *dlq.Service implements it.
+type DLQBrowser interface {
+	List(ctx context.Context, q store.DLQQuery) (*store.DLQPage, error)
+	Get(ctx context.Context, id int64) (*store.DLQMessage, []store.DLQAuditEntry, error)
+	Replay(ctx context.Context, id int64, actor, note string) (*store.DLQMessage, error)
+	Discard(ctx context.Context, id int64, actor, note string) (*store.DLQMessage, error)
+}
+
+// WithDLQ enables the dlq browser: /api/v1/admin/dlq and the /admin/dlq pages.
+// Without it, or without auth, they are a 404.
+func WithDLQ(b DLQBrowser) Option {
+	return func(s *Server) {
+		s.dlq = b
+	}
+}
+
+// dlqResolveRequest is the body of POST /api/v1/admin/dlq:replay and :discard.
+type dlqResolveRequest struct {
+	IDs  []int64 `json:"ids"`
+	Note string  `json:"note"`
+}
+
+// dlqResult is the outcome for one of the ids of a replay or discard.
+type dlqResult struct {
+	ID     int64           `json:"id"`
+	Status store.DLQStatus `json:"status,omitempty"` // the new one
+	Error  string          `json:"error,omitempty"`
+}
+
+// dlqEnabled answers with a 404 if the dlq browser is off.
+func (s *Server) dlqEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if s.dlq == nil {
+		s.writeError(w, r, http.StatusNotFound, "the dlq browser is not enabled")
+		return false
+	}
+	return true
+}
+
+// parseDLQQuery reads the dlq filter, the cursor and the page size from the query parameters.
+func parseDLQQuery(query url.Values) (store.DLQQuery, error) {
+	q := store.DLQQuery{
+		DLQFilter: store.DLQFilter{Key: query.Get("key"), Reason: query.Get("reason")},
+		Cursor:    query.Get("cursor"),
+	}
+	if v := query.Get("status"); v != "" {
+		status, err := store.ParseDLQStatus(v)
+		if err != nil {
+			return q, err
+		}
+		q.Status = status
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return q, errors.New("limit must be a positive integer")
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
+
+// parseDLQID reads the {id} path value, it's false if it's not an id.
+func parseDLQID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// apiListDLQ is GET /api/v1/admin/dlq, a page of the dlq messages, newest first. It takes
+// the 'status', 'key' (exact) and 'reason' (a substring) filters, 'cursor' and 'limit'.
+func (s *Server) apiListDLQ(w http.ResponseWriter, r *http.Request) {
+	if !s.dlqEnabled(w, r) {
+		return
+	}
+	q, err := parseDLQQuery(r.URL.Query())
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.dlq.List(r.Context(), q)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, page)
+}
+
+// apiGetDLQ is GET /api/v1/admin/dlq/{id}, the message with its payload, headers and audit trail.
+func (s *Server) apiGetDLQ(w http.ResponseWriter, r *http.Request) {
+	if !s.dlqEnabled(w, r) {
+		return
+	}
+	id, ok := parseDLQID(r)
+	if !ok {
+		s.writeError(w, r, http.StatusBadRequest, "the id must be a positive integer")
+		return
+	}
+
+	m, trail, err := s.dlq.Get(r.Context(), id)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, map[string]any{"message": m, "audit": trail})
+}
+
+// apiReplayDLQ is POST /api/v1/admin/dlq:replay, it sends the pending messages of
+// {"ids": [...], "note": "..."} back to the orders topic.
+func (s *Server) apiReplayDLQ(w http.ResponseWriter, r *http.Request) {
+	s.resolveDLQ(w, r, store.DLQReplayed)
+}
+
+// apiDiscardDLQ is POST /api/v1/admin/dlq:discard, the same as the replay, only the
+// messages are given up on.
+func (s *Server) apiDiscardDLQ(w http.ResponseWriter, r *http.Request) {
+	s.resolveDLQ(w, r, store.DLQDiscarded)
+}
+
+// resolveDLQ resolves the messages one by one, a failure doesn't stop the rest: every id
+// gets its result, {"results": [{"id": 1, "status": "replayed"}, {"id": 2, "error": "..."}]}.
+func (s *Server) resolveDLQ(w http.ResponseWriter, r *http.Request, action store.DLQStatus) {
+	if !s.dlqEnabled(w, r) || !s.isJSONRequest(w, r) {
+		return
+	}
+	resolve := s.dlq.Discard
+	if action == store.DLQReplayed {
+		resolve = s.dlq.Replay
+	}
+
+	var req dlqResolveRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(`expected {"ids": [...], "note": "..."}: %v`, err))
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxDLQBatch {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("expected 1 to %d ids", maxDLQBatch))
+		return
+	}
+
+	actor := auth.FromContext(r.Context()).String()
+	results := make([]dlqResult, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		m, err := resolve(r.Context(), id, actor, req.Note)
+		if err != nil {
+			if errors.Is(err, store.ErrConnectionFailed) || errors.Is(err, context.Canceled) {
+				// the rest would fail the same way
+				s.storeError(w, r, err)
+				return
+			}
+			results = append(results, dlqResult{ID: id, Error: s.dlqErrorMessage(r, err)})
+			continue
+		}
+		results = append(results, dlqResult{ID: id, Status: m.Status})
+	}
+
+	s.adminLog(r, "DLQ messages resolved", "action", action, "ids", req.IDs, "note", req.Note)
+	s.writeJSON(w, r, http.StatusOK, map[string]any{"results": results})
+}
+
+// dlqErrorMessage is what a caller is told about a message that couldn't be resolved,
+// the unexpected errors are logged and not passed on.
+func (s *Server) dlqErrorMessage(r *http.Request, err error) string {
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrDLQResolved), errors.Is(err, dlq.ErrReplayFailed):
+		return err.Error()
+	default:
+		s.requestLogger(r).Errorw("server error", "error", err, "request_method", r.Method, "request_uri", r.URL.RequestURI())
+		return http.StatusText(http.StatusInternalServerError)
+	}
+}
+
+// dlqPage is the /admin/dlq page, the dlq messages with the filters of apiListDLQ.
+func (s *Server) dlqPage(w http.ResponseWriter, r *http.Request) {
+	if s.dlq == nil {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	q, err := parseDLQQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !query.Has("status") {
+		q.Status = store.DLQPending // what needs looking into
+	}
+
+	page, err := s.dlq.List(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+
+	var next string
+	if page.NextCursor != "" {
+		nextQuery := url.Values{"status": {string(q.Status)}, "key": {q.Key}, "reason": {q.Reason}, "cursor": {page.NextCursor}}
+		next = "/admin/dlq?" + nextQuery.Encode()
+	}
+	s.render(w, r, http.StatusOK, "dlq.tmpl", map[string]any{
+		"Messages": page.Messages,
+		"Status":   string(q.Status),
+		"Key":      q.Key,
+		"Reason":   q.Reason,
+		"Next":     next,
+	})
+}
+
+// dlqMessagePage is the /admin/dlq/{id} page: the message, its audit trail and,
+// while it's pending, the replay and discard buttons.
+func (s *Server) dlqMessagePage(w http.ResponseWriter, r *http.Request) {
+	if s.dlq == nil {
+		http.NotFound(w, r)
+		return
+	}
+	id, ok := parseDLQID(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	m, trail, err := s.dlq.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+
+	s.render(w, r, http.StatusOK, "dlq_message.tmpl", map[string]any{
+		"Message": m,
+		"Payload": string(m.Payload),
+		"Audit":   trail,
+		"Pending": m.Status == store.DLQPending,
+		// a replay that died halfway, it can only be discarded
+		"Replaying": m.Status == store.DLQReplaying,
+	})
+}
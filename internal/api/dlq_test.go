@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/dlq"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDLQ is a DLQBrowser over the messages, a replay of failReplay isn't delivered.
+type fakeDLQ struct {
+	messages   map[int64]*store.DLQMessage
+	failReplay int64
+	lastQuery  store.DLQQuery
+	actors     []string
+}
+
+func (f *fakeDLQ) List(ctx context.Context, q store.DLQQuery) (*store.DLQPage, error) {
+	f.lastQuery = q
+	if q.Cursor == "bogus" {
+		return nil, store.ErrInvalidCursor
+	}
+	page := &store.DLQPage{Messages: []*store.DLQMessage{}}
+	for _, m := range f.messages {
+		if q.Status == "" || m.Status == q.Status {
+			page.Messages = append(page.Messages, m)
+		}
+	}
+	return page, nil
+}
+
+func (f *fakeDLQ) Get(ctx context.Context, id int64) (*store.DLQMessage, []store.DLQAuditEntry, error) {
+	m, ok := f.messages[id]
+	if !ok {
+		return nil, nil, store.ErrNotFound
+	}
+	return m, []store.DLQAuditEntry{}, nil
+}
+
+func (f *fakeDLQ) resolve(id int64, actor string, status store.DLQStatus) (*store.DLQMessage, error) {
+	m, ok := f.messages[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if m.Status != store.DLQPending {
+		return nil, store.ErrDLQResolved
+	}
+	if status == store.DLQReplayed && id == f.failReplay {
+		return nil, dlq.ErrReplayFailed
+	}
+	f.actors = append(f.actors, actor)
+	m.Status = status
+	return m, nil
+}
+
+func (f *fakeDLQ) Replay(ctx context.Context, id int64, actor, note string) (*store.DLQMessage, error) {
+	return f.resolve(id, actor, store.DLQReplayed)
+}
+
+func (f *fakeDLQ) Discard(ctx context.Context, id int64, actor, note string) (*store.DLQMessage, error) {
+	return f.resolve(id, actor, store.DLQDiscarded)
+}
+
+func newFakeDLQ() *fakeDLQ {
+	deadAt := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	return &fakeDLQ{
+		messages: map[int64]*store.DLQMessage{
+			1: {ID: 1, Key: "b563feb7b2b84b6test", Payload: []byte(`{"order_uid": "b563feb7b2b84b6test"}`), Reason: "invalid order", Status: store.DLQPending, DeadAt: deadAt},
+			2: {ID: 2, Key: "a", Payload: []byte(`{}`), Reason: "record already exists", Status: store.DLQPending, DeadAt: deadAt},
+			3: {ID: 3, Key: "b", Payload: []byte(`{}`), Reason: "invalid order", Status: store.DLQDiscarded, DeadAt: deadAt},
+		},
+		failReplay: 2,
+	}
+}
+
+func TestServer_dlq(t *testing.T) {
+	newServer := func(t *testing.T, b DLQBrowser) *Server {
+		opts := []Option{withTestAdmin(t)}
+		if b != nil {
+			opts = append(opts, WithDLQ(b))
+		}
+		server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, opts...)
+		require.NoError(t, err)
+		return server
+	}
+	serve := func(s *Server, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", testAdminKey)
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		server := newServer(t, nil)
+		assert.Equal(t, http.StatusNotFound, serve(server, "GET", "/api/v1/admin/dlq", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(server, "POST", "/api/v1/admin/dlq:replay", `{"ids": [1]}`).Code)
+		assert.Equal(t, http.StatusNotFound, serve(server, "GET", "/admin/dlq", "").Code)
+	})
+
+	t.Run("not without auth", func(t *testing.T) {
+		b := newFakeDLQ()
+		server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithAnonymousAdmin(), WithDLQ(b))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, serve(server, "POST", "/api/v1/admin/dlq:replay", `{"ids": [1]}`).Code)
+		assert.Equal(t, http.StatusNotFound, serve(server, "GET", "/admin/dlq", "").Code)
+		assert.Empty(t, b.actors)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		b := newFakeDLQ()
+		server := newServer(t, b)
+
+		rr := serve(server, "GET", "/api/v1/admin/dlq?status=pending&key=a&reason=exists&limit=10", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, store.DLQQuery{
+			DLQFilter: store.DLQFilter{Status: store.DLQPending, Key: "a", Reason: "exists"},
+			Limit:     10,
+		}, b.lastQuery)
+
+		var page struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		assert.Len(t, page.Messages, 2)
+
+		assert.Equal(t, http.StatusBadRequest, serve(server, "GET", "/api/v1/admin/dlq?status=lost", "").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "GET", "/api/v1/admin/dlq?limit=-1", "").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "GET", "/api/v1/admin/dlq?cursor=bogus", "").Code)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		server := newServer(t, newFakeDLQ())
+
+		rr := serve(server, "GET", "/api/v1/admin/dlq/1", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var body struct {
+			Message map[string]any `json:"message"`
+			Audit   []any          `json:"audit"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, `{"order_uid": "b563feb7b2b84b6test"}`, body.Message["payload"])
+		assert.NotNil(t, body.Audit)
+
+		assert.Equal(t, http.StatusNotFound, serve(server, "GET", "/api/v1/admin/dlq/9", "").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "GET", "/api/v1/admin/dlq/x", "").Code)
+	})
+
+	t.Run("replay and discard", func(t *testing.T) {
+		b := newFakeDLQ()
+		server := newServer(t, b)
+
+		rr := serve(server, "POST", "/api/v1/admin/dlq:replay", `{"ids": [1, 2, 3, 9], "note": "fixed"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"results": [
+			{"id": 1, "status": "replayed"},
+			{"id": 2, "error": "`+dlq.ErrReplayFailed.Error()+`"},
+			{"id": 3, "error": "`+store.ErrDLQResolved.Error()+`"},
+			{"id": 9, "error": "`+store.ErrNotFound.Error()+`"}
+		]}`, rr.Body.String())
+		assert.Equal(t, []string{"api_key:ops(admin)"}, b.actors)
+
+		rr = serve(server, "POST", "/api/v1/admin/dlq:discard", `{"ids": [2]}`)
+		assert.JSONEq(t, `{"results": [{"id": 2, "status": "discarded"}]}`, rr.Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/dlq:discard", `{"ids": []}`).Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "POST", "/api/v1/admin/dlq:discard", `{"id": 1}`).Code)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		server := newServer(t, newFakeDLQ())
+
+		rr := serve(server, "GET", "/admin/dlq", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `href="/admin/dlq/1"`)
+		assert.NotContains(t, rr.Body.String(), `href="/admin/dlq/3"`, "pending only by default")
+
+		rr = serve(server, "GET", "/admin/dlq/1", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid order")
+		assert.Contains(t, rr.Body.String(), `data-action="replay"`)
+
+		rr = serve(server, "GET", "/admin/dlq/3", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotContains(t, rr.Body.String(), `data-action="replay"`, "resolved already")
+
+		assert.Equal(t, http.StatusNotFound, serve(server, "GET", "/admin/dlq/9", "").Code)
+	})
+}
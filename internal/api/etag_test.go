@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_etagMatch(t *testing.T) {
+	const etag = `W/"abc"`
+
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"no header", "", false},
+		{"same tag", `W/"abc"`, true},
+		{"strong form of the tag", `"abc"`, true},
+		{"one of the list", `"xyz", W/"abc"`, true},
+		{"any", "*", true},
+		{"other tag", `W/"xyz"`, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, etagMatch(tc.ifNoneMatch, etag))
+		})
+	}
+}
+
+func TestServer_conditionalGet(t *testing.T) {
+	mockService, mockLogger := new(MockOrderService), logger.NewMockLogger()
+	server, err := NewServer(mockService, mockLogger, config.HTTPServerConfig{OrderCacheControl: "public, max-age=300"})
+	require.NoError(t, err)
+
+	order := &domain.Order{OrderUID: "etaguid", Items: []domain.Item{{ChrtID: 1, Name: "Mascaras"}}}
+	mockService.On("GetOrder", mock.Anything, "etaguid").Return(order, nil)
+
+	handlers := map[string]struct {
+		handler http.HandlerFunc
+		target  string
+		accept  string
+	}{
+		"html page": {server.orderView, "/orders/etaguid", ""},
+		"json page": {server.orderView, "/orders/etaguid", "application/json"},
+		"csv page":  {server.orderView, "/orders/etaguid?format=csv", ""},
+		"json api":  {server.apiGetOrder, "/api/v1/orders/etaguid", ""},
+	}
+
+	get := func(name, ifNoneMatch string) *httptest.ResponseRecorder {
+		h := handlers[name]
+		req := httptest.NewRequest("GET", h.target, nil)
+		req.SetPathValue("uid", "etaguid")
+		if h.accept != "" {
+			req.Header.Set("Accept", h.accept)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		h.handler(rr, req)
+		return rr
+	}
+
+	etags := map[string]string{}
+	for name := range handlers {
+		t.Run(name, func(t *testing.T) {
+			rr := get(name, "")
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "public, max-age=300", rr.Header().Get("Cache-Control"))
+
+			etag := rr.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+			assert.Equal(t, etag, get(name, "").Header().Get("ETag"), "etag must be stable")
+			etags[name] = etag
+
+			rr = get(name, etag)
+			assert.Equal(t, http.StatusNotModified, rr.Code)
+			assert.Empty(t, rr.Body.String())
+			assert.Equal(t, etag, rr.Header().Get("ETag"))
+			assert.Equal(t, "public, max-age=300", rr.Header().Get("Cache-Control"))
+
+			rr = get(name, `W/"stale"`)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.NotEmpty(t, rr.Body.String())
+		})
+	}
+
+	t.Run("representations have their own tags", func(t *testing.T) {
+		assert.NotEqual(t, etags["html page"], etags["json page"])
+		assert.NotEqual(t, etags["html page"], etags["csv page"])
+		// the api and the negotiated json are the same representation
+		assert.Equal(t, etags["json page"], etags["json api"])
+	})
+
+	t.Run("content changes the tag", func(t *testing.T) {
+		before, err := orderETag(order, formatJSON)
+		require.NoError(t, err)
+		after, err := orderETag(&domain.Order{OrderUID: "etaguid"}, formatJSON)
+		require.NoError(t, err)
+		assert.NotEqual(t, before, after)
+	})
+
+	t.Run("page data changes the tag", func(t *testing.T) {
+		base, err := orderETag(order, formatHTML, "v1", "false")
+		require.NoError(t, err)
+		linked, err := orderETag(order, formatHTML, "v1", "true")
+		require.NoError(t, err)
+		redeployed, err := orderETag(order, formatHTML, "v2", "false")
+		require.NoError(t, err)
+
+		assert.NotEqual(t, base, linked)
+		assert.NotEqual(t, base, redeployed)
+	})
+
+	t.Run("no cache-control when unset", func(t *testing.T) {
+		plain, err := NewServer(mockService, mockLogger, config.HTTPServerConfig{})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/orders/etaguid", nil)
+		rr := httptest.NewRecorder()
+		plain.orderView(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("Cache-Control"))
+		assert.NotEmpty(t, rr.Header().Get("ETag"))
+	})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/export"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+)
+
+// Fallbacks for the zero values of config.ExportConfig
+const (
+	defaultExportWriteTimeout = 30 * time.Second
+	defaultExportConcurrency  = 2
+)
+
+// WithExporter sets the source /api/v1/exports streams the orders from.
+// Without it the export is a 404.
+func WithExporter(src export.Source, cfg config.ExportConfig) Option {
+	return func(s *Server) {
+		if cfg.WriteTimeout <= 0 {
+			cfg.WriteTimeout = defaultExportWriteTimeout
+		}
+		if cfg.MaxConcurrent <= 0 {
+			cfg.MaxConcurrent = defaultExportConcurrency
+		}
+		s.exporter = src
+		s.exportRowGroupSize = cfg.ParquetRowGroupSize
+		s.exportWriteTimeout = cfg.WriteTimeout
+		s.exportSlots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+}
+
+// apiExportOrders is GET /api/v1/exports, every order matching the filters of
+// GET /api/v1/orders as a file download, in the 'format' asked for (csv by default,
+// see export.Formats). The orders are streamed from a database cursor straight into the
+// response, so an export of any size takes the same memory.
+//
+// Errors before the first byte are answered as usual. Once the body has started the
+// status can't change anymore, so a failure halfway aborts the connection: the client
+// sees a truncated download rather than a complete looking file.
+//
+// Every export holds a transaction and a database connection until it's done, so there are
+// only so many at once (export.max_concurrent), the one over that gets a 503.
+func (s *Server) apiExportOrders(w http.ResponseWriter, r *http.Request) {
+	if s.exporter == nil {
+		s.writeError(w, r, http.StatusNotFound, "the export is not enabled")
+		return
+	}
+	select {
+	case s.exportSlots <- struct{}{}:
+		defer func() { <-s.exportSlots }()
+	default:
+		metrics.HTTPRequestsRejectedTotal.WithLabelValues(rejectOverloaded).Inc()
+		w.Header().Set("Retry-After", "10")
+		s.writeError(w, r, http.StatusServiceUnavailable, "too many exports are running, retry later")
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := export.ParseFormat(cmp.Or(query.Get("format"), string(export.FormatCSV)))
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if field := transport.HiddenFilter(r.Context(), s.redactor, filter); field != "" {
+		s.writeError(w, r, http.StatusForbidden, fmt.Sprintf("%s is redacted for your role, the orders can't be filtered by it", field))
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders_%s%s"`, time.Now().UTC().Format("20060102T150405Z"), format.Extension()))
+
+	ew := &exportResponse{ResponseWriter: w, rc: http.NewResponseController(w), timeout: s.exportWriteTimeout}
+	n, err := export.Run(r.Context(), s.exporter, filter, format, ew, export.Options{
+		Transform:           func(o *domain.Order) *domain.Order { return s.redactor.ForCaller(r.Context(), o) },
+		ParquetRowGroupSize: s.exportRowGroupSize,
+	})
+	metrics.OrdersExportedTotal.WithLabelValues(string(format)).Add(float64(n))
+	if err != nil {
+		if !ew.started {
+			w.Header().Del("Content-Disposition")
+			s.storeError(w, r, err)
+			return
+		}
+		s.requestLogger(r).Errorw("Order export aborted", "error", err, "format", format, "exported", n)
+		panic(http.ErrAbortHandler)
+	}
+
+	s.requestLogger(r).Infow("Order export complete", "format", format, "exported", n)
+}
+
+// exportResponse is the response an export is written to. It remembers whether the body
+// has started and pushes the write deadline on with every write: a long export is fine
+// as long as it keeps moving.
+type exportResponse struct {
+	http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+	started bool
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	e.started = true
+	if e.timeout > 0 {
+		_ = e.rc.SetWriteDeadline(time.Now().Add(e.timeout))
+	}
+	return e.ResponseWriter.Write(p)
+}
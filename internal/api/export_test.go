@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/export"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExporter hands out its orders, then fails with err if it's set.
+type fakeExporter struct {
+	orders []*domain.Order
+	err    error
+	filter store.OrderFilter
+}
+
+func (f *fakeExporter) ExportOrders(ctx context.Context, filter store.OrderFilter, fn func(*domain.Order) error) error {
+	f.filter = filter
+	for _, o := range f.orders {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return f.err
+}
+
+func TestServer_apiExportOrders(t *testing.T) {
+	orders := []*domain.Order{
+		{OrderUID: "one", CustomerID: "test", Items: []domain.Item{{ChrtID: 1}, {ChrtID: 2}}},
+		{OrderUID: "two", CustomerID: "test", Items: []domain.Item{{ChrtID: 3}}},
+	}
+	newServer := func(t *testing.T, src export.Source) *Server {
+		opts := []Option{WithAnonymousAdmin()} // the routes are above a viewer
+		if src != nil {
+			opts = append(opts, WithExporter(src, config.ExportConfig{}))
+		}
+		server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, opts...)
+		require.NoError(t, err)
+		return server
+	}
+
+	t.Run("csv items with filters", func(t *testing.T) {
+		src := &fakeExporter{orders: orders}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exports?format=csv_items&customer_id=test&created_to=2021-11-26", nil)
+		rr := httptest.NewRecorder()
+		newServer(t, src).httpServer.Handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Regexp(t, `^attachment; filename="orders_\d{8}T\d{6}Z\.csv"$`, rr.Header().Get("Content-Disposition"))
+		assert.Equal(t, "test", src.filter.CustomerID)
+		assert.Equal(t, time.Date(2021, 11, 27, 0, 0, 0, 0, time.UTC), src.filter.CreatedTo)
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.NoError(t, err)
+		assert.Len(t, records, 4)
+		assert.Equal(t, export.ItemsCSVHeader, records[0])
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exports?format=ndjson", nil)
+		rr := httptest.NewRecorder()
+		newServer(t, &fakeExporter{orders: orders}).httpServer.Handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), `"order_uid":"two"`)
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		for _, query := range []string{"format=xlsx", "created_from=yesterday"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/exports?"+query, nil)
+			rr := httptest.NewRecorder()
+			newServer(t, &fakeExporter{}).httpServer.Handler.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+
+	t.Run("failure before the body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exports", nil)
+		rr := httptest.NewRecorder()
+		newServer(t, &fakeExporter{err: store.ErrConnectionFailed}).httpServer.Handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		assert.Empty(t, rr.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("failure halfway aborts", func(t *testing.T) {
+		// the csv writer buffers, the orders have to outgrow its buffer to reach the client
+		many := make([]*domain.Order, 500)
+		for i := range many {
+			many[i] = &domain.Order{OrderUID: "order", CustomerID: "test"}
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exports", nil)
+		rr := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			newServer(t, &fakeExporter{orders: many, err: store.ErrConnectionFailed}).httpServer.Handler.ServeHTTP(rr, req)
+		})
+	})
+
+	t.Run("too many exports", func(t *testing.T) {
+		server := newServer(t, &fakeExporter{orders: orders})
+		for range cap(server.exportSlots) {
+			server.exportSlots <- struct{}{}
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exports", nil)
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+		assert.Equal(t, defaultExportConcurrency, cap(server.exportSlots))
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exports", nil)
+		rr := httptest.NewRecorder()
+		newServer(t, nil).httpServer.Handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/pkg/health"
+)
+
+// WithHealth sets the registry the health endpoints report from.
+func WithHealth(registry *health.Registry) Option {
+	return func(s *Server) {
+		s.health = registry
+	}
+}
+
+// componentReport is the /healthz view of a single component.
+type componentReport struct {
+	Healthy     bool      `json:"healthy"`
+	Critical    bool      `json:"critical"`
+	Detail      string    `json:"detail,omitempty"`
+	Since       time.Time `json:"since"`
+	For         string    `json:"for"` // how long it's been in the current state
+	LastChecked time.Time `json:"last_checked"`
+}
+
+// livez is the liveness probe: if the process can answer, it's alive.
+// Dependencies are deliberately left out, restarting the service won't bring the db back.
+func (s *Server) livez(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz is the readiness probe: 503 while any critical component is unhealthy.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	report := s.healthReport()
+	if !report.Ready() {
+		failing := []string{}
+		for _, c := range report.Components {
+			if c.Critical && !c.State.Healthy {
+				failing = append(failing, c.Name)
+			}
+		}
+		s.writeJSON(w, r, http.StatusServiceUnavailable, map[string]any{"status": "not_ready", "failing": failing})
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, map[string]any{"status": "ready"})
+}
+
+// healthz is the detailed view of every component. The overall status is
+//   - "ok" if everything is healthy
+//   - "degraded" if only non-critical components are unhealthy
+//   - "down" if a critical one is (and then the status code is 503 too)
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	report := s.healthReport()
+
+	components := make(map[string]componentReport, len(report.Components))
+	for _, c := range report.Components {
+		components[c.Name] = componentReport{
+			Healthy:     c.State.Healthy,
+			Critical:    c.Critical,
+			Detail:      c.State.Detail,
+			Since:       c.State.Since,
+			For:         now.Sub(c.State.Since).Round(time.Second).String(),
+			LastChecked: c.LastChecked,
+		}
+	}
+
+	code := http.StatusOK
+	if !report.Ready() {
+		code = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, r, code, map[string]any{"status": report.Status, "components": components})
+}
+
+// healthReport returns the registry report, a server without a registry has nothing to report on.
+func (s *Server) healthReport() health.Report {
+	if s.health == nil {
+		return health.Report{Status: health.StatusOK}
+	}
+	return s.health.Report()
+}
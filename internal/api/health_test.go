@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/health"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toggleChecker is a health.Checker that passes while ok is set.
+type toggleChecker struct {
+	name string
+	ok   atomic.Bool
+}
+
+func (c *toggleChecker) Name() string { return c.name }
+
+func (c *toggleChecker) Check(context.Context) error {
+	if !c.ok.Load() {
+		return errors.New(c.name + " is down")
+	}
+	return nil
+}
+
+func TestServer_healthEndpoints(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, cache, kafka := &toggleChecker{name: "postgres"}, &toggleChecker{name: "cache"}, &toggleChecker{name: "kafka_consumer"}
+	db.ok.Store(true)
+	kafka.ok.Store(true)
+
+	registry := health.NewRegistry(logger.NewMockLogger())
+	registry.Register(db, health.CheckOptions{Interval: 5 * time.Millisecond, Critical: true})
+	registry.Register(cache, health.CheckOptions{Interval: 5 * time.Millisecond, Critical: true})
+	registry.Register(kafka, health.CheckOptions{Interval: 5 * time.Millisecond})
+	registry.Start(ctx)
+
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithHealth(registry), WithAnonymousAdmin())
+	require.NoError(t, err)
+
+	get := func(path string) (int, map[string]any) {
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		return rr.Code, body
+	}
+	eventually := func(path string, code int) {
+		assert.Eventually(t, func() bool {
+			got, _ := get(path)
+			return got == code
+		}, time.Second, time.Millisecond)
+	}
+
+	t.Run("alive regardless of components", func(t *testing.T) {
+		code, body := get("/livez")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", body["status"])
+	})
+
+	t.Run("not ready until preload is done", func(t *testing.T) {
+		eventually("/readyz", http.StatusServiceUnavailable)
+		_, body := get("/readyz")
+		assert.Equal(t, []any{"cache"}, body["failing"])
+
+		cache.ok.Store(true)
+		eventually("/readyz", http.StatusOK)
+	})
+
+	t.Run("non-critical failure degrades", func(t *testing.T) {
+		kafka.ok.Store(false)
+		assert.Eventually(t, func() bool {
+			_, body := get("/healthz")
+			return body["status"] == "degraded"
+		}, time.Second, time.Millisecond)
+
+		code, body := get("/readyz")
+		assert.Equal(t, http.StatusOK, code)
+
+		code, body = get("/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		k := body["components"].(map[string]any)["kafka_consumer"].(map[string]any)
+		assert.Equal(t, false, k["healthy"])
+		assert.Equal(t, false, k["critical"])
+		assert.Equal(t, "kafka_consumer is down", k["detail"])
+		assert.NotEmpty(t, k["for"])
+	})
+
+	t.Run("critical failure is down", func(t *testing.T) {
+		db.ok.Store(false)
+		eventually("/healthz", http.StatusServiceUnavailable)
+
+		_, body := get("/healthz")
+		assert.Equal(t, "down", body["status"])
+	})
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyCache remembers the responses given to requests carrying an 'Idempotency-Key'
+// header, so a retried request gets back the first response instead of being executed twice.
+//
+// It's in-memory, so the guarantee holds for a single instance and until a restart, which is
+// enough to absorb client retries after timeouts and dropped connections. It holds at most
+// maxKeys keys, the callers can't grow it at will.
+type idempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	maxKeys   int
+	entries   map[string]*idempotentEntry
+	lastSweep time.Time
+}
+
+// idempotentEntry is a response that's either recorded or still being produced (done is open).
+type idempotentEntry struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	response    *responseCapture
+	expires     time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration, maxKeys int) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:       ttl,
+		maxKeys:   maxKeys,
+		entries:   make(map[string]*idempotentEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// begin claims the key for the request body. If the key is new, the returned entry is nil and
+// the caller has to either finish or abandon the key. Otherwise the entry recorded first is returned:
+// conflict is true if it was made for a different body. A new key isn't claimed while the cache
+// is full, full is true then.
+func (c *idempotencyCache) begin(key string, body []byte) (entry *idempotentEntry, conflict, full bool) {
+	fp := sha256.Sum256(body)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(now, false)
+
+	if e, ok := c.entries[key]; ok && (e.response == nil || now.Before(e.expires)) {
+		return e, e.fingerprint != fp, false
+	}
+
+	if len(c.entries) >= c.maxKeys {
+		c.sweep(now, true)
+		if len(c.entries) >= c.maxKeys {
+			return nil, false, true
+		}
+	}
+	c.entries[key] = &idempotentEntry{fingerprint: fp, done: make(chan struct{})}
+	return nil, false, false
+}
+
+// finish records the response for the key and releases the requests waiting for it.
+func (c *idempotencyCache) finish(key string, resp *responseCapture) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	e.response = resp
+	e.expires = time.Now().Add(c.ttl)
+	close(e.done)
+}
+
+// abandon forgets the key without recording a response (for transient failures),
+// so the next retry is executed for real. A key that's finished is kept, so it can be deferred.
+func (c *idempotencyCache) abandon(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok && e.response == nil {
+		delete(c.entries, key)
+		close(e.done)
+	}
+}
+
+// wait blocks until the entry is finished or abandoned, the recorded response is
+// returned, or nil if it was abandoned or ctx is done first.
+func (e *idempotentEntry) wait(ctx context.Context) *responseCapture {
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return nil
+	}
+	return e.response
+}
+
+// sweep drops the expired entries, at most once a minute unless forced. Must be called with mu held.
+func (c *idempotencyCache) sweep(now time.Time, force bool) {
+	if !force && now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+
+	for key, e := range c.entries {
+		if e.response != nil && now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// responseCapture is a http.ResponseWriter that keeps the response in memory,
+// so it can be both sent and remembered.
+type responseCapture struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseCapture() *responseCapture {
+	return &responseCapture{header: make(http.Header), status: http.StatusOK}
+}
+
+func (c *responseCapture) Header() http.Header { return c.header }
+
+func (c *responseCapture) WriteHeader(code int) { c.status = code }
+
+func (c *responseCapture) Write(b []byte) (int, error) { return c.body.Write(b) }
+
+// writeTo sends the captured response.
+func (c *responseCapture) writeTo(w http.ResponseWriter) {
+	for k, v := range c.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(c.status)
+	w.Write(c.body.Bytes())
+}
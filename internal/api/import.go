@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// The statuses an imported line can end up with.
+const (
+	importStored    = "stored"
+	importDuplicate = "duplicate"
+	importInvalid   = "invalid"
+	importFailed    = "failed"
+)
+
+// importResult is the report line written back for every imported line.
+type importResult struct {
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// importSummary is the last line of the report.
+type importSummary struct {
+	Summary map[string]int `json:"summary"`
+	Aborted string         `json:"aborted,omitempty"`
+}
+
+// apiImportOrders is POST /api/v1/orders:import, a bulk import for backfills.
+//
+// The body is either NDJSON (an order per line) or a JSON array of orders, it's read one
+// order at a time and inserted in batches of 'import_batch_size', each batch in its own
+// transaction. The response is NDJSON too: a result per line, written as soon as the line's
+// batch is done, followed by a summary. Neither the body nor the report is ever held in memory
+// as a whole. A lost database connection aborts the import, the lines that have no result
+// line were not stored.
+func (s *Server) apiImportOrders(w http.ResponseWriter, r *http.Request) {
+	src, err := newImportSource(r.Body, int(s.maxBodyBytes))
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the report is written while the body is still being read
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	summary := importSummary{Summary: map[string]int{
+		importStored: 0, importDuplicate: 0, importInvalid: 0, importFailed: 0,
+	}}
+	report := func(res importResult) {
+		summary.Summary[res.Status]++
+		metrics.OrdersImportedTotal.WithLabelValues(res.Status).Inc()
+		enc.Encode(res)
+	}
+
+	var (
+		pending []importResult // results of the batch, in line order; the unresolved ones have no status yet
+		orders  []*domain.Order
+		indexes []int // orders[i] belongs to pending[indexes[i]]
+	)
+	flush := func() bool {
+		aborted := false
+		if len(orders) > 0 {
+			for i, err := range s.service.ProcessNewOrders(r.Context(), orders) {
+				res := &pending[indexes[i]]
+				res.Status, res.Reason = importStatus(err)
+				if errors.Is(err, store.ErrConnectionFailed) {
+					aborted = true
+				}
+			}
+		}
+		for _, res := range pending {
+			report(res)
+		}
+		pending, orders, indexes = pending[:0], orders[:0], indexes[:0]
+
+		rc.Flush()
+		// a long import is fine as long as it keeps moving
+		if s.importBatchTimeout > 0 {
+			deadline := time.Now().Add(s.importBatchTimeout)
+			_ = rc.SetReadDeadline(deadline)
+			_ = rc.SetWriteDeadline(deadline)
+		}
+		return !aborted
+	}
+
+	for {
+		raw, line, err := src.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// the body can't be read any further, report what's done
+			flush()
+			summary.Aborted = err.Error()
+			break
+		}
+
+		var order domain.Order
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&order); err != nil {
+			pending = append(pending, importResult{Line: line, Status: importInvalid, Reason: fmt.Sprintf("malformed order json: %v", err)})
+		} else {
+			pending = append(pending, importResult{Line: line, OrderUID: order.OrderUID})
+			orders = append(orders, &order)
+			indexes = append(indexes, len(pending)-1)
+		}
+
+		// the malformed lines count too, a body of nothing else is still streamed back batch by batch
+		if len(pending) >= s.importBatchSize && !flush() {
+			summary.Aborted = store.ErrConnectionFailed.Error()
+			break
+		}
+	}
+	if summary.Aborted == "" && !flush() {
+		summary.Aborted = store.ErrConnectionFailed.Error()
+	}
+
+	if summary.Aborted != "" {
+		s.requestLogger(r).Warnw("Order import aborted", "reason", summary.Aborted, "summary", summary.Summary)
+	} else {
+		s.requestLogger(r).Infow("Order import complete", "summary", summary.Summary)
+	}
+	enc.Encode(summary)
+}
+
+// importStatus translates the ProcessNewOrders error of an order into its report status.
+func importStatus(err error) (status, reason string) {
+	switch {
+	case err == nil:
+		return importStored, ""
+	case errors.Is(err, store.ErrAlreadyExists):
+		return importDuplicate, "order already exists"
+	case errors.Is(err, domain.ErrInvalidOrder):
+		return importInvalid, domain.ErrInvalidOrder.Error()
+	case errors.Is(err, store.ErrConnectionFailed):
+		return importFailed, store.ErrConnectionFailed.Error()
+	default:
+		return importFailed, err.Error()
+	}
+}
+
+// importSource yields the raw orders of an import body one by one.
+type importSource interface {
+	// next returns the next raw order and its 1-based line (or array element) number,
+	// io.EOF when the body is over.
+	next() (json.RawMessage, int, error)
+}
+
+// newImportSource sniffs the body: a leading '[' means a JSON array, anything else is NDJSON.
+// maxLine caps a single NDJSON line.
+func newImportSource(body io.Reader, maxLine int) (importSource, error) {
+	br := bufio.NewReader(body)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("request body is empty")
+			}
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		br.UnreadByte()
+
+		if b == '[' {
+			dec := json.NewDecoder(br)
+			dec.Token() // the opening '[', already seen
+			return &arraySource{dec: dec}, nil
+		}
+
+		sc := bufio.NewScanner(br)
+		sc.Buffer(make([]byte, 0, 64*1024), maxLine)
+		return &ndjsonSource{sc: sc}, nil
+	}
+}
+
+// ndjsonSource reads an order per line, blank lines are skipped.
+type ndjsonSource struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func (s *ndjsonSource) next() (json.RawMessage, int, error) {
+	for s.sc.Scan() {
+		s.line++
+		if raw := bytes.TrimSpace(s.sc.Bytes()); len(raw) > 0 {
+			return raw, s.line, nil
+		}
+	}
+	if err := s.sc.Err(); err != nil {
+		return nil, s.line + 1, fmt.Errorf("line %d: %w", s.line+1, err)
+	}
+	return nil, s.line, io.EOF
+}
+
+// arraySource reads the elements of a top level JSON array, the same way
+// the producer's streamJSONObjects does.
+type arraySource struct {
+	dec  *json.Decoder
+	elem int
+}
+
+func (s *arraySource) next() (json.RawMessage, int, error) {
+	if !s.dec.More() {
+		if _, err := s.dec.Token(); err != nil { // the closing ']'
+			return nil, s.elem, fmt.Errorf("element %d: %w", s.elem+1, err)
+		}
+		return nil, s.elem, io.EOF
+	}
+
+	s.elem++
+	var raw json.RawMessage
+	if err := s.dec.Decode(&raw); err != nil {
+		return nil, s.elem, fmt.Errorf("element %d: %w", s.elem, err)
+	}
+	return raw, s.elem, nil
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// readImportReport splits the NDJSON report into the per-line results and the summary.
+func readImportReport(t *testing.T, body string) ([]importResult, importSummary) {
+	t.Helper()
+
+	var (
+		results []importResult
+		summary importSummary
+	)
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		if strings.HasPrefix(sc.Text(), `{"summary"`) {
+			require.NoError(t, json.Unmarshal(sc.Bytes(), &summary))
+			continue
+		}
+		var res importResult
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &res))
+		results = append(results, res)
+	}
+	return results, summary
+}
+
+// uidsAre matches a ProcessNewOrders batch by the order uids.
+func uidsAre(uids ...string) any {
+	return mock.MatchedBy(func(orders []*domain.Order) bool {
+		if len(orders) != len(uids) {
+			return false
+		}
+		for i, o := range orders {
+			if o.OrderUID != uids[i] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestServer_apiImportOrders(t *testing.T) {
+	newServer := func(t *testing.T) (*Server, *MockOrderService) {
+		mockService := new(MockOrderService)
+		server, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{ImportBatchSize: 2}, WithAnonymousAdmin())
+		require.NoError(t, err)
+		return server, mockService
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		server, mockService := newServer(t)
+		// the malformed line fills the first batch
+		mockService.On("ProcessNewOrders", mock.Anything, uidsAre("a")).
+			Return([]error{nil}).Once()
+		mockService.On("ProcessNewOrders", mock.Anything, uidsAre("b", "c")).
+			Return([]error{store.ErrAlreadyExists, domain.ErrInvalidOrder}).Once()
+
+		body := `{"order_uid":"a"}
+{"order_uid":"x","unknown":true}
+
+{"order_uid":"b"}
+{"order_uid":"c"}
+`
+		req := httptest.NewRequest("POST", "/api/v1/orders:import", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		server.httpServer.Handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+		results, summary := readImportReport(t, rr.Body.String())
+		require.Len(t, results, 4)
+		assert.Equal(t, importResult{Line: 1, OrderUID: "a", Status: importStored}, results[0])
+		assert.Equal(t, 2, results[1].Line)
+		assert.Equal(t, importInvalid, results[1].Status)
+		assert.Equal(t, importResult{Line: 4, OrderUID: "b", Status: importDuplicate, Reason: "order already exists"}, results[2])
+		assert.Equal(t, importResult{Line: 5, OrderUID: "c", Status: importInvalid, Reason: domain.ErrInvalidOrder.Error()}, results[3])
+		assert.Equal(t, map[string]int{importStored: 1, importDuplicate: 1, importInvalid: 2, importFailed: 0}, summary.Summary)
+		assert.Empty(t, summary.Aborted)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("json array", func(t *testing.T) {
+		server, mockService := newServer(t)
+		mockService.On("ProcessNewOrders", mock.Anything, uidsAre("a", "b")).Return([]error{nil, nil}).Once()
+
+		req := httptest.NewRequest("POST", "/api/v1/orders:import", strings.NewReader(` [{"order_uid":"a"}, {"order_uid":"b"}]`))
+		rr := httptest.NewRecorder()
+
+		server.apiImportOrders(rr, req)
+
+		results, summary := readImportReport(t, rr.Body.String())
+		require.Len(t, results, 2)
+		assert.Equal(t, 2, results[1].Line)
+		assert.Equal(t, 2, summary.Summary[importStored])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("db down aborts", func(t *testing.T) {
+		server, mockService := newServer(t)
+		mockService.On("ProcessNewOrders", mock.Anything, uidsAre("a", "b")).
+			Return([]error{store.ErrConnectionFailed, store.ErrConnectionFailed}).Once()
+
+		body := "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n{\"order_uid\":\"c\"}\n"
+		req := httptest.NewRequest("POST", "/api/v1/orders:import", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		server.apiImportOrders(rr, req)
+
+		results, summary := readImportReport(t, rr.Body.String())
+		require.Len(t, results, 2)
+		assert.Equal(t, importFailed, results[0].Status)
+		assert.Equal(t, store.ErrConnectionFailed.Error(), summary.Aborted)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("broken array", func(t *testing.T) {
+		server, mockService := newServer(t)
+		mockService.On("ProcessNewOrders", mock.Anything, uidsAre("a")).Return([]error{nil}).Once()
+
+		req := httptest.NewRequest("POST", "/api/v1/orders:import", strings.NewReader(`[{"order_uid":"a"}, {"order_`))
+		rr := httptest.NewRecorder()
+
+		server.apiImportOrders(rr, req)
+
+		results, summary := readImportReport(t, rr.Body.String())
+		require.Len(t, results, 1)
+		assert.NotEmpty(t, summary.Aborted)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("malformed lines only", func(t *testing.T) {
+		server, mockService := newServer(t)
+
+		body := strings.Repeat("{\"order_uid\":\n", 5)
+		req := httptest.NewRequest("POST", "/api/v1/orders:import", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		server.apiImportOrders(rr, req)
+
+		results, summary := readImportReport(t, rr.Body.String())
+		require.Len(t, results, 5)
+		assert.Equal(t, 5, summary.Summary[importInvalid])
+		assert.Empty(t, summary.Aborted)
+		mockService.AssertNotCalled(t, "ProcessNewOrders", mock.Anything, mock.Anything)
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		server, _ := newServer(t)
+
+		req := httptest.NewRequest("POST", "/api/v1/orders:import", strings.NewReader("  \n"))
+		rr := httptest.NewRecorder()
+
+		server.apiImportOrders(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"net/http"
 	"runtime/debug"
 	"strings"
@@ -9,11 +10,13 @@ import (
 
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
 	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
 )
 
 type statusRecorder struct {
 	http.ResponseWriter
 	status int
+	bytes  int64 // of the body as sent, the compressed size if it was compressed
 }
 
 func (r *statusRecorder) WriteHeader(code int) {
@@ -21,12 +24,44 @@ func (r *statusRecorder) WriteHeader(code int) {
 	r.ResponseWriter.WriteHeader(code)
 }
 
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (flushes, deadlines).
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // normalizePath converts numeric ids into :id;
 // It's crude, but it works for now (and im tired)
 func normalizePath(path string) string {
 	if strings.HasPrefix(path, "/orders/") {
 		return "/orders/:id"
 	}
+	if path == "/api/v1/orders/stream" {
+		return path
+	}
+	if strings.HasPrefix(path, "/api/v1/orders/") {
+		return "/api/v1/orders/:id"
+	}
+	if strings.HasPrefix(path, "/api/v1/admin/cache/") {
+		return "/api/v1/admin/cache/:id"
+	}
+	if strings.HasPrefix(path, "/api/v1/admin/dlq/") {
+		return "/api/v1/admin/dlq/:id"
+	}
+	if strings.HasPrefix(path, "/api/v1/customers/") {
+		return "/api/v1/customers/:id/orders"
+	}
+	if strings.HasPrefix(path, "/customers/") {
+		return "/customers/:id"
+	}
+	if strings.HasPrefix(path, "/admin/dlq/") {
+		return "/admin/dlq/:id"
+	}
 	return path
 }
 
@@ -47,11 +82,51 @@ func metricsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func recoveryMiddleware(next http.Handler, logger logger.Logger) http.Handler {
+// accessLogMiddleware logs the requests, a sample of them: the errors and the slow ones are
+// always logged, the rest with the probability of sampleRate (1 logs all, 0 only those).
+func accessLogMiddleware(next http.Handler, log logger.Logger, sampleRate float64, slow time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		latency := time.Since(start)
+		isSlow := slow > 0 && latency >= slow
+		if rw.status < http.StatusBadRequest && !isSlow && rand.Float64() >= sampleRate {
+			return
+		}
+
+		reqLog := logger.FromContext(r.Context(), log)
+		fields := []any{
+			"method", r.Method,
+			"route", normalizePath(r.URL.Path),
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"latency_ms", float64(latency.Microseconds()) / 1000,
+			"remote", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		}
+		switch {
+		case rw.status >= http.StatusInternalServerError:
+			reqLog.Errorw("http request", fields...)
+		case rw.status >= http.StatusBadRequest || isSlow:
+			reqLog.Warnw("http request", append(fields, "slow", isSlow)...)
+		default:
+			reqLog.Infow("http request", fields...)
+		}
+	})
+}
+
+func recoveryMiddleware(next http.Handler, log logger.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rec := recover(); rec != nil {
-				logger.Errorw("server encountered panic",
+				// a deliberate abort of a response that's already started, net/http handles it
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				logger.FromContext(r.Context(), log).Errorw("server encountered panic",
 					"panic", rec,
 					"stack", string(debug.Stack()),
 					"method", r.Method,
@@ -65,3 +140,27 @@ func recoveryMiddleware(next http.Handler, logger logger.Logger) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requestIDHeader carries the id of a request, it's logged as the correlation id
+// of everything done for the request.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware puts the request id into the context and echoes it back. An id sent
+// by the client (or a proxy in front) is kept so the logs can be tied to theirs, a missing
+// or malformed one is replaced with a new id.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !transport.ValidCorrelationID(id) {
+			id = logger.NewCorrelationID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithCorrelationID(r.Context(), id)))
+	})
+}
+
+// requestLogger is the server's logger tagged with the request's correlation id.
+func (s *Server) requestLogger(r *http.Request) logger.Logger {
+	return logger.FromContext(r.Context(), s.logger)
+}
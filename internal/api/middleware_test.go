@@ -2,14 +2,20 @@ package api
 
 import (
 	"bytes"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
 	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_normalizePath(t *testing.T) {
@@ -28,6 +34,21 @@ func Test_normalizePath(t *testing.T) {
 			path: "/orders/",
 			want: "/orders/:id",
 		},
+		{
+			name: "api order path with ID",
+			path: "/api/v1/orders/12345",
+			want: "/api/v1/orders/:id",
+		},
+		{
+			name: "customer orders",
+			path: "/api/v1/customers/test/orders",
+			want: "/api/v1/customers/:id/orders",
+		},
+		{
+			name: "dlq message page",
+			path: "/admin/dlq/42",
+			want: "/admin/dlq/:id",
+		},
 		{
 			name: "home path",
 			path: "/home",
@@ -101,4 +122,133 @@ func Test_statusRecorder(t *testing.T) {
 	recorder.WriteHeader(http.StatusTeapot)
 	assert.Equal(t, http.StatusTeapot, recorder.status)
 	assert.Equal(t, http.StatusTeapot, rr.Code)
+
+	io.WriteString(recorder, "short and ")
+	io.WriteString(recorder, "stout")
+	assert.Equal(t, int64(len("short and stout")), recorder.bytes)
+}
+
+func Test_requestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.CorrelationID(r.Context())
+	}))
+
+	testCases := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{name: "kept", header: "5f2b7c1e-9a0d-4d4e-8f7a-3c2b1a0f9e8d", wantKept: true},
+		{name: "generated when missing"},
+		{name: "replaced when malformed", header: "bad id\nwith a newline"},
+		{name: "replaced when too long", header: strings.Repeat("a", transport.MaxCorrelationIDLen+1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set(requestIDHeader, tc.header)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			require.NotEmpty(t, seen)
+			assert.Equal(t, seen, rr.Header().Get(requestIDHeader))
+			if tc.wantKept {
+				assert.Equal(t, tc.header, seen)
+			} else {
+				assert.NotEqual(t, tc.header, seen)
+			}
+		})
+	}
+}
+
+// entryRecorder keeps every entry with its level and the fields it was created With.
+type entryRecorder struct {
+	logger.MockLogger
+	fields  []any
+	entries *[]logEntry
+}
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+func newEntryRecorder() *entryRecorder {
+	return &entryRecorder{entries: new([]logEntry)}
+}
+
+func (l *entryRecorder) With(keysAndValues ...any) logger.Logger {
+	return &entryRecorder{fields: append(append([]any{}, l.fields...), keysAndValues...), entries: l.entries}
+}
+
+func (l *entryRecorder) record(level, msg string, keysAndValues []any) {
+	fields := map[string]any{}
+	kvs := append(append([]any{}, l.fields...), keysAndValues...)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		fields[kvs[i].(string)] = kvs[i+1]
+	}
+	*l.entries = append(*l.entries, logEntry{level: level, msg: msg, fields: fields})
+}
+
+func (l *entryRecorder) Infow(msg string, kv ...any)  { l.record("info", msg, kv) }
+func (l *entryRecorder) Warnw(msg string, kv ...any)  { l.record("warn", msg, kv) }
+func (l *entryRecorder) Errorw(msg string, kv ...any) { l.record("error", msg, kv) }
+
+func Test_accessLogMiddleware(t *testing.T) {
+	respond := func(status int, body string, delay time.Duration) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+		})
+	}
+
+	testCases := []struct {
+		name       string
+		handler    http.Handler
+		sampleRate float64
+		wantLevel  string // "" if nothing's logged
+	}{
+		{name: "sampled in", handler: respond(http.StatusOK, "hello", 0), sampleRate: 1, wantLevel: "info"},
+		{name: "sampled out", handler: respond(http.StatusOK, "hello", 0), sampleRate: 0},
+		{name: "client errors are always logged", handler: respond(http.StatusNotFound, "nope", 0), wantLevel: "warn"},
+		{name: "server errors are always logged", handler: respond(http.StatusInternalServerError, "boom", 0), wantLevel: "error"},
+		{name: "slow requests are always logged", handler: respond(http.StatusOK, "zzz", 30*time.Millisecond), wantLevel: "warn"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			log := newEntryRecorder()
+			handler := requestIDMiddleware(accessLogMiddleware(tc.handler, log, tc.sampleRate, 20*time.Millisecond))
+
+			req := httptest.NewRequest("GET", "/orders/b563feb7b2b84b6test", nil)
+			req.Header.Set("User-Agent", "locust")
+			req.Header.Set(requestIDHeader, "req-1")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if tc.wantLevel == "" {
+				assert.Empty(t, *log.entries)
+				return
+			}
+			require.Len(t, *log.entries, 1)
+			entry := (*log.entries)[0]
+			assert.Equal(t, tc.wantLevel, entry.level)
+			assert.Equal(t, "http request", entry.msg)
+			assert.Equal(t, "GET", entry.fields["method"])
+			assert.Equal(t, "/orders/:id", entry.fields["route"])
+			assert.Equal(t, rr.Code, entry.fields["status"])
+			assert.Equal(t, int64(rr.Body.Len()), entry.fields["bytes"])
+			assert.Equal(t, "locust", entry.fields["user_agent"])
+			assert.Equal(t, "req-1", entry.fields[logger.CorrelationIDKey])
+			assert.Contains(t, entry.fields, "latency_ms")
+			assert.Contains(t, entry.fields, "remote")
+		})
+	}
 }
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The representations an order can be served in.
+const (
+	formatHTML  = "html"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatPrint = "print"
+)
+
+// mediaFormats maps the negotiable media types onto formats, in order of preference on ties.
+// The print view is a variant of html, so it's only reachable with the 'format' parameter.
+var mediaFormats = []struct {
+	mediaType string
+	format    string
+}{
+	{"text/html", formatHTML},
+	{"application/json", formatJSON},
+	{"text/csv", formatCSV},
+}
+
+// negotiateFormat picks the representation of the response: the 'format' query parameter
+// wins, otherwise it's the best match for the Accept header. A request that accepts none of
+// the formats still gets html, that's what a browser following a link wants anyway.
+// ok is false only for an unknown 'format' parameter.
+func negotiateFormat(r *http.Request) (format string, ok bool) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		switch f {
+		case formatHTML, formatJSON, formatCSV, formatPrint:
+			return f, true
+		}
+		return "", false
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formatHTML, true
+	}
+
+	best, bestQ := formatHTML, 0.0
+	for _, mf := range mediaFormats {
+		if q := acceptQuality(accept, mf.mediaType); q > bestQ {
+			best, bestQ = mf.format, q
+		}
+	}
+	return best, true
+}
+
+// acceptQuality returns the quality the Accept header gives the media type, using the most
+// specific matching range ('type/subtype' beats 'type/*' beats '*/*'), 0 if none matches.
+func acceptQuality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mr, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch {
+		case mr == mediaType:
+			s = 2
+		case mr == typ+"/*":
+			s = 1
+		case mr == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+
+		rangeQ := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				rangeQ = parsed
+			}
+		}
+		q, specificity = rangeQ, s
+	}
+	return q
+}
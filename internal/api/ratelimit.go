@@ -0,0 +1,217 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+// Reasons of a rejected request, the 'reason' label of metrics.HTTPRequestsRejectedTotal
+const (
+	rejectRateLimited = "rate_limited"
+	rejectOverloaded  = "overloaded"
+)
+
+// idleClientTTL is how long the bucket of a client that went quiet is kept. A client that
+// comes back later starts with a full bucket, which is what it would have by then anyway.
+const idleClientTTL = 10 * time.Minute
+
+// loadShedder protects the service from a single noisy client (a token bucket per client,
+// keyed by identity or IP) and from overload as a whole (a cap on the requests in flight).
+// Either limit is off if its config value is zero.
+type loadShedder struct {
+	limit             rate.Limit
+	burst             int
+	trustForwardedFor bool
+
+	mu        sync.Mutex
+	clients   map[string]*clientBucket
+	lastSweep time.Time
+
+	inFlight chan struct{} // a semaphore, nil if there's no concurrency limit
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newLoadShedder(cfg config.RateLimitConfig) *loadShedder {
+	ls := &loadShedder{
+		limit:             rate.Limit(cfg.RequestsPerSecond),
+		burst:             cfg.Burst,
+		trustForwardedFor: cfg.TrustForwardedFor,
+		clients:           make(map[string]*clientBucket),
+		lastSweep:         time.Now(),
+	}
+	if ls.burst <= 0 {
+		ls.burst = max(1, int(math.Ceil(cfg.RequestsPerSecond)))
+	}
+	if cfg.MaxConcurrent > 0 {
+		ls.inFlight = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return ls
+}
+
+// middleware rejects the requests over the client's rate with a 429 and the ones over
+// the concurrency cap with a 503, both with a 'Retry-After'.
+func (ls *loadShedder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ls.limit > 0 {
+			if wait := ls.reserve(ls.clientKey(r), time.Now()); wait > 0 {
+				reject(w, http.StatusTooManyRequests, wait, rejectRateLimited)
+				return
+			}
+		}
+
+		// a stream or an export would hold its slot for as long as it's open, they're
+		// only rate limited (the exports are capped on their own)
+		if ls.inFlight != nil && !isLongLived(r) {
+			select {
+			case ls.inFlight <- struct{}{}:
+				defer func() { <-ls.inFlight }()
+			default:
+				reject(w, http.StatusServiceUnavailable, time.Second, rejectOverloaded)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authGuard goes in front of authentication, where the rate limit can't reach: a request
+// that fails it (a 401 or a 403) takes a token from its IP's bucket of failed attempts, and
+// an IP with none left gets a 429 for anything carrying credentials, before they're checked.
+// Guessing an API key, a token or a password is as slow as the rate limit makes it, while
+// requests without credentials (the probes, anonymous readers) are never held up by it.
+func (ls *loadShedder) authGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "auth:" + clientIP(r, ls.trustForwardedFor)
+		if hasCredentials(r) {
+			if wait := ls.peek(key, time.Now()); wait > 0 {
+				reject(w, http.StatusTooManyRequests, wait, rejectRateLimited)
+				return
+			}
+		}
+
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		if rw.status == http.StatusUnauthorized || rw.status == http.StatusForbidden {
+			ls.reserve(key, time.Now())
+		}
+	})
+}
+
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("X-API-Key") != "" || r.Header.Get("Authorization") != ""
+}
+
+// isLongLived reports whether the request is for a long-lived stream or an export.
+func isLongLived(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "/stream") || r.URL.Path == "/api/v1/exports"
+}
+
+// reserve takes a token from the client's bucket, if there's none it returns how long
+// until there is one.
+func (ls *loadShedder) reserve(key string, now time.Time) time.Duration {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.sweep(now)
+
+	b, ok := ls.clients[key]
+	if !ok {
+		b = &clientBucket{limiter: rate.NewLimiter(ls.limit, ls.burst)}
+		ls.clients[key] = b
+	}
+	b.lastSeen = now
+
+	res := b.limiter.ReserveN(now, 1)
+	if wait := res.DelayFrom(now); wait > 0 {
+		// the token is not ours to take yet, give it back
+		res.CancelAt(now)
+		return wait
+	}
+	return 0
+}
+
+// peek returns how long until the client's bucket has a token, without taking it.
+func (ls *loadShedder) peek(key string, now time.Time) time.Duration {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	b, ok := ls.clients[key]
+	if !ok {
+		return 0
+	}
+	res := b.limiter.ReserveN(now, 1)
+	defer res.CancelAt(now)
+	return res.DelayFrom(now)
+}
+
+// sweep drops the buckets of idle clients, at most once a minute, holding ls.mu.
+func (ls *loadShedder) sweep(now time.Time) {
+	if now.Sub(ls.lastSweep) < time.Minute {
+		return
+	}
+	ls.lastSweep = now
+
+	for key, b := range ls.clients {
+		if now.Sub(b.lastSeen) > idleClientTTL {
+			delete(ls.clients, key)
+		}
+	}
+}
+
+// clientKey identifies the client: by who it authenticated as, by IP otherwise. An unverified
+// 'X-API-Key' doesn't count, a client could rotate it for a fresh bucket every time.
+func (ls *loadShedder) clientKey(r *http.Request) string {
+	if id := auth.FromContext(r.Context()); id.Method != auth.MethodAnonymous {
+		return "id:" + id.Subject
+	}
+	return "ip:" + clientIP(r, ls.trustForwardedFor)
+}
+
+// clientIP is the address the request came from. X-Forwarded-For is only trusted
+// behind a proxy that sets it, anyone else can put anything in there. Even then only its
+// last entry is: the proxy appends the address it got the request from, whatever comes
+// before is what the client sent.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			last := xff[len(xff)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if last = strings.TrimSpace(last); last != "" {
+				return last
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func reject(w http.ResponseWriter, status int, retryAfter time.Duration, reason string) {
+	metrics.HTTPRequestsRejectedTotal.WithLabelValues(reason).Inc()
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, seconds)))
+	http.Error(w, http.StatusText(status), status)
+}
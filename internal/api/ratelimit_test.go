@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadShedder_rateLimit(t *testing.T) {
+	ls := newLoadShedder(config.RateLimitConfig{RequestsPerSecond: 1, Burst: 2})
+	handler := ls.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	get := func(remoteAddr, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/orders/abc", nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		if apiKey == "valid" {
+			req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Subject: "ops", Role: auth.RoleViewer, Method: auth.MethodAPIKey}))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// the burst goes through, then the bucket is empty
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1234", "").Code)
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1235", "").Code)
+	rr := get("10.0.0.1:1236", "")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	// other clients have buckets of their own
+	assert.Equal(t, http.StatusOK, get("10.0.0.2:1234", "").Code)
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1237", "valid").Code)
+
+	// a key that didn't authenticate doesn't get one
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1238", "made-up").Code)
+}
+
+func Test_loadShedder_reserve(t *testing.T) {
+	ls := newLoadShedder(config.RateLimitConfig{RequestsPerSecond: 2})
+	now := time.Now()
+
+	assert.Zero(t, ls.reserve("ip:a", now))
+	assert.Zero(t, ls.reserve("ip:a", now))
+	assert.Equal(t, 500*time.Millisecond, ls.reserve("ip:a", now))
+	// rejected requests don't take tokens, so the client isn't pushed further back
+	assert.Equal(t, 500*time.Millisecond, ls.reserve("ip:a", now))
+	assert.Zero(t, ls.reserve("ip:a", now.Add(500*time.Millisecond)))
+
+	// idle clients are forgotten
+	ls.reserve("ip:b", now.Add(idleClientTTL+2*time.Minute))
+	assert.NotContains(t, ls.clients, "ip:a")
+	assert.Contains(t, ls.clients, "ip:b")
+}
+
+func Test_loadShedder_concurrency(t *testing.T) {
+	ls := newLoadShedder(config.RateLimitConfig{MaxConcurrent: 2})
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	handler := ls.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			return
+		}
+		started.Done()
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	started.Add(2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+	}
+	started.Wait()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	// streams and exports have their own limits
+	for _, target := range []string{"/api/v1/orders/stream", "/api/v1/exports"} {
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, rr.Code, target)
+	}
+
+	close(release)
+	wg.Wait()
+
+	started.Add(1)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func Test_clientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+
+	assert.Equal(t, "10.0.0.1", clientIP(req, false))
+	// the proxy appended the last entry, the client made up the rest
+	assert.Equal(t, "203.0.113.7", clientIP(req, true))
+
+	req.Header.Add("X-Forwarded-For", "192.0.2.9")
+	assert.Equal(t, "192.0.2.9", clientIP(req, true))
+
+	req.Header.Set("X-Forwarded-For", "")
+	assert.Equal(t, "10.0.0.1", clientIP(req, true))
+}
+
+func TestServer_probesAreNotRateLimited(t *testing.T) {
+	cfg := config.HTTPServerConfig{RateLimit: config.RateLimitConfig{RequestsPerSecond: 1, Burst: 1}}
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), cfg)
+	require.NoError(t, err)
+
+	get := func(target string) int {
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get("/static/css/main.css"))
+	assert.Equal(t, http.StatusTooManyRequests, get("/static/css/main.css"))
+	assert.Equal(t, http.StatusOK, get("/livez"))
+	assert.Equal(t, http.StatusOK, get("/livez"))
+}
+
+func TestServer_failedAuthIsRateLimited(t *testing.T) {
+	cfg := config.HTTPServerConfig{RateLimit: config.RateLimitConfig{RequestsPerSecond: 1, Burst: 2}}
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), cfg, withTestAdmin(t))
+	require.NoError(t, err)
+
+	get := func(remoteAddr, target, apiKey string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// every guess takes a token, the server doesn't even look at the ones over the limit
+	assert.Equal(t, http.StatusUnauthorized, get("10.0.0.1:1234", "/api/v1/admin/dlq", "guess-1"))
+	assert.Equal(t, http.StatusUnauthorized, get("10.0.0.1:1235", "/api/v1/admin/dlq", "guess-2"))
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1236", "/api/v1/admin/dlq", "guess-3"))
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1237", "/api/v1/admin/dlq", testAdminKey))
+
+	// requests without credentials aren't guesses, other IPs have budgets of their own
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1238", "/livez", ""))
+	assert.Equal(t, http.StatusUnauthorized, get("10.0.0.2:1234", "/api/v1/admin/dlq", "guess-1"))
+}
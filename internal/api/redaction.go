@@ -0,0 +1,11 @@
+package api
+
+import "github.com/goinginblind/l0-task/internal/pkg/redact"
+
+// WithRedaction sets the redactor applied to every order the server sends out,
+// according to the caller's role. Without it the orders go out as they are.
+func WithRedaction(redactor *redact.Redactor) Option {
+	return func(s *Server) {
+		s.redactor = redactor
+	}
+}
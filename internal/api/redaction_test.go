@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_redaction(t *testing.T) {
+	redactor, err := redact.New(redact.DefaultRules, []byte("a-test-hash-key-of-32-bytes-long"))
+	require.NoError(t, err)
+
+	mockService := new(MockOrderService)
+	server, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{}, WithRedaction(redactor))
+	require.NoError(t, err)
+
+	// stands in for the instance held by the cache
+	cached := &domain.Order{
+		OrderUID: "piiuid",
+		Delivery: domain.Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com"},
+		Payment:  domain.Payment{Transaction: "piiuid", Bank: "alpha", Amount: 1817},
+		Items:    []domain.Item{{ChrtID: 1}},
+	}
+	snapshot := *cached
+	mockService.On("GetOrder", mock.Anything, "piiuid").Return(cached, nil)
+	mockService.On("ListOrders", mock.Anything, mock.Anything).Return(&store.OrderPage{Orders: []*domain.Order{cached}}, nil)
+
+	as := func(req *http.Request, role auth.Role) *http.Request {
+		req.SetPathValue("uid", "piiuid")
+		return req.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: "t", Role: role}))
+	}
+
+	t.Run("json api", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.apiGetOrder(rr, as(httptest.NewRequest("GET", "/api/v1/orders/piiuid", nil), auth.RoleSupport))
+
+		var got domain.Order
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, "*******0000", got.Delivery.Phone)
+		assert.Equal(t, "t***@gmail.com", got.Delivery.Email)
+		assert.Empty(t, got.Payment.Bank)
+		assert.Equal(t, 1817, got.Payment.Amount)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.apiListOrders(rr, as(httptest.NewRequest("GET", "/api/v1/orders", nil), auth.RoleViewer))
+
+		var got store.OrderPage
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		require.Len(t, got.Orders, 1)
+		assert.Equal(t, "*******0000", got.Orders[0].Delivery.Phone)
+	})
+
+	t.Run("no sorting by what's hidden", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.apiListOrders(rr, as(httptest.NewRequest("GET", "/api/v1/orders?sort=customer_id", nil), auth.RoleViewer))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		rr = httptest.NewRecorder()
+		server.ordersView(rr, as(httptest.NewRequest("GET", "/orders?sort=customer_id", nil), auth.RoleViewer))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		rr = httptest.NewRecorder()
+		server.ordersView(rr, as(httptest.NewRequest("GET", "/orders", nil), auth.RoleViewer))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "sort=customer_id")
+		assert.Contains(t, rr.Body.String(), "sort=amount")
+
+		rr = httptest.NewRecorder()
+		server.apiListOrders(rr, as(httptest.NewRequest("GET", "/api/v1/orders?sort=customer_id", nil), auth.RoleSupport))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("no filtering by what's hidden", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.apiListOrders(rr, as(httptest.NewRequest("GET", "/api/v1/orders?customer_id=test", nil), auth.RoleViewer))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Contains(t, rr.Body.String(), "customer_id")
+
+		rr = httptest.NewRecorder()
+		server.ordersView(rr, as(httptest.NewRequest("GET", "/orders?customer_id=test", nil), auth.RoleViewer))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		rr = httptest.NewRecorder()
+		server.ordersView(rr, as(httptest.NewRequest("GET", "/orders", nil), auth.RoleViewer))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotContains(t, rr.Body.String(), `name="customer_id"`)
+		assert.Contains(t, rr.Body.String(), `name="delivery_service"`)
+
+		rr = httptest.NewRecorder()
+		server.apiListOrders(rr, as(httptest.NewRequest("GET", "/api/v1/orders?customer_id=test", nil), auth.RoleSupport))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("html and print pages", func(t *testing.T) {
+		for _, target := range []string{"/orders/piiuid", "/orders/piiuid?format=print"} {
+			rr := httptest.NewRecorder()
+			server.orderView(rr, as(httptest.NewRequest("GET", target, nil), auth.RoleSupport))
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.NotContains(t, rr.Body.String(), "+9720000000", target)
+			assert.NotContains(t, rr.Body.String(), "test@gmail.com", target)
+		}
+	})
+
+	t.Run("admins see it all", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.apiGetOrder(rr, as(httptest.NewRequest("GET", "/api/v1/orders/piiuid", nil), auth.RoleAdmin))
+		assert.Contains(t, rr.Body.String(), "+9720000000")
+	})
+
+	t.Run("roles get their own etags", func(t *testing.T) {
+		etag := func(role auth.Role) string {
+			rr := httptest.NewRecorder()
+			server.apiGetOrder(rr, as(httptest.NewRequest("GET", "/api/v1/orders/piiuid", nil), role))
+			return rr.Header().Get("ETag")
+		}
+		assert.NotEqual(t, etag(auth.RoleSupport), etag(auth.RoleAdmin))
+	})
+
+	assert.Equal(t, snapshot, *cached, "the cached order must not be touched")
+}
@@ -0,0 +1,362 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// maxIdempotencyKeyLen caps the 'Idempotency-Key' header, keys are kept in memory.
+const maxIdempotencyKeyLen = 255
+
+// maxBatchGet caps the uids of a single batchGet.
+const maxBatchGet = 100
+
+// apiError is the body of every non-2xx JSON API response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiGetOrder is the JSON counterpart of orderView: GET /api/v1/orders/{uid}
+func (s *Server) apiGetOrder(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	if !transport.ValidUID(uid) {
+		s.writeError(w, r, http.StatusBadRequest, "order uid must be a non-empty alphanumeric string")
+		return
+	}
+
+	order, err := s.service.GetOrder(r.Context(), uid)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+	if order == nil {
+		s.writeError(w, r, http.StatusNotFound, store.ErrNotFound.Error())
+		return
+	}
+
+	order = s.redactor.ForCaller(r.Context(), order)
+	if s.notModified(w, r, order, formatJSON) {
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, order)
+}
+
+// batchGetRequest is the body of a batchGet.
+type batchGetRequest struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+// batchGetResult is the lookup of a single uid of a batchGet.
+type batchGetResult struct {
+	OrderUID string        `json:"order_uid"`
+	Found    bool          `json:"found"`
+	Order    *domain.Order `json:"order,omitempty"`
+}
+
+// apiBatchGetOrders is POST /api/v1/orders:batchGet, GET /api/v1/orders/{uid} for up to
+// maxBatchGet uids at once. The results line up with the requested uids, the orders that
+// don't exist are there too, as not found.
+func (s *Server) apiBatchGetOrders(w http.ResponseWriter, r *http.Request) {
+	if !s.isJSONRequest(w, r) {
+		return
+	}
+
+	var req batchGetRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf(`expected {"order_uids": [...]}: %v`, err))
+		return
+	}
+	if len(req.OrderUIDs) == 0 || len(req.OrderUIDs) > maxBatchGet {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("order_uids must hold 1 to %d uids", maxBatchGet))
+		return
+	}
+	for _, uid := range req.OrderUIDs {
+		if !transport.ValidUID(uid) {
+			s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("order uid %q must be a non-empty alphanumeric string", uid))
+			return
+		}
+	}
+
+	orders, err := s.service.GetOrders(r.Context(), req.OrderUIDs)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+
+	results := make([]batchGetResult, len(orders))
+	for i, order := range orders {
+		results[i] = batchGetResult{OrderUID: req.OrderUIDs[i], Found: order != nil}
+		if order != nil {
+			results[i].Order = s.redactor.ForCaller(r.Context(), order)
+		}
+	}
+	s.writeJSON(w, r, http.StatusOK, map[string]any{"results": results})
+}
+
+// apiListOrders is GET /api/v1/orders, a filtered page of orders, newest first unless
+// sorted otherwise. The next page is requested by passing back the 'next_cursor' of the current one.
+func (s *Server) apiListOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	sort, err := parseOrderSort(query)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.sortHidden(r, sort.Field) {
+		s.writeError(w, r, http.StatusForbidden, fmt.Sprintf("%s is redacted for your role, the orders can't be sorted by it", sort.Field))
+		return
+	}
+	if field := transport.HiddenFilter(r.Context(), s.redactor, filter); field != "" {
+		s.writeError(w, r, http.StatusForbidden, fmt.Sprintf("%s is redacted for your role, the orders can't be filtered by it", field))
+		return
+	}
+
+	q := store.ListQuery{OrderFilter: filter, Sort: sort, Cursor: query.Get("cursor")}
+	if v := query.Get("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit <= 0 {
+			s.writeError(w, r, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+	}
+
+	page, err := s.service.ListOrders(r.Context(), q)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, &store.OrderPage{
+		Orders:     s.redactor.AllForCaller(r.Context(), page.Orders),
+		NextCursor: page.NextCursor,
+	})
+}
+
+// parseOrderFilter reads the order filter from the query parameters. Dates are accepted
+// either as RFC 3339 timestamps or as plain 2006-01-02 dates, a plain 'created_to' date
+// includes that whole day.
+func parseOrderFilter(query url.Values) (store.OrderFilter, error) {
+	filter := store.OrderFilter{
+		CustomerID:      query.Get("customer_id"),
+		DeliveryService: query.Get("delivery_service"),
+		Locale:          query.Get("locale"),
+		Currency:        query.Get("currency"),
+		Provider:        query.Get("provider"),
+	}
+
+	var err error
+	if v := query.Get("created_from"); v != "" {
+		if filter.CreatedFrom, _, err = parseDate(v); err != nil {
+			return filter, fmt.Errorf("created_from: %w", err)
+		}
+	}
+	if v := query.Get("created_to"); v != "" {
+		var dateOnly bool
+		if filter.CreatedTo, dateOnly, err = parseDate(v); err != nil {
+			return filter, fmt.Errorf("created_to: %w", err)
+		}
+		if dateOnly {
+			filter.CreatedTo = filter.CreatedTo.AddDate(0, 0, 1)
+		}
+	}
+
+	return filter, nil
+}
+
+// sortHidden reports whether the field is redacted for the caller: the order of a page sorted
+// by it, and the cursors carrying its values, would give them away.
+func (s *Server) sortHidden(r *http.Request, field store.SortField) bool {
+	// the sort fields are named after the json fields of the order
+	return s.redactor.HidesForCaller(r.Context(), string(field))
+}
+
+// parseOrderSort reads the sort from the 'sort' (the field, date_created by default)
+// and 'order' (asc or desc, the default) query parameters.
+func parseOrderSort(query url.Values) (store.OrderSort, error) {
+	var sort store.OrderSort
+	if v := query.Get("sort"); v != "" {
+		field, err := store.ParseSortField(v)
+		if err != nil {
+			return sort, err
+		}
+		sort.Field = field
+	}
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		sort.Ascending = true
+	default:
+		return sort, fmt.Errorf("unknown order %q, expected asc or desc", query.Get("order"))
+	}
+	return sort, nil
+}
+
+// parseDate parses either an RFC 3339 timestamp or a plain date, reporting which one it was.
+func parseDate(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, errors.New("expected an RFC 3339 timestamp or a YYYY-MM-DD date")
+	}
+	return t, false, nil
+}
+
+// apiCreateOrder is POST /api/v1/orders, the HTTP alternative to publishing the order to Kafka.
+// The order goes through the same ProcessNewOrder as the consumed ones. A request with an
+// 'Idempotency-Key' header that's already been answered gets back the first answer.
+func (s *Server) apiCreateOrder(w http.ResponseWriter, r *http.Request) {
+	if !s.isJSONRequest(w, r) {
+		return
+	}
+
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLen {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			s.writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit))
+			return
+		}
+		s.writeError(w, r, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	if key == "" {
+		s.createOrder(w, r, body)
+		return
+	}
+
+	entry, conflict, full := s.idempotency.begin(key, body)
+	if full {
+		s.writeError(w, r, http.StatusServiceUnavailable, "too many Idempotency-Keys are remembered, retry later")
+		return
+	}
+	if entry != nil {
+		if conflict {
+			s.writeError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body")
+			return
+		}
+		// the first request might still be running, so wait for its response
+		if resp := entry.wait(r.Context()); resp != nil {
+			w.Header().Set("Idempotent-Replayed", "true")
+			resp.writeTo(w)
+			return
+		}
+		s.writeError(w, r, http.StatusConflict, "a request with the same Idempotency-Key is being processed, retry later")
+		return
+	}
+
+	// deferred, so a panic doesn't leave the key in flight for good
+	defer s.idempotency.abandon(key)
+
+	resp := newResponseCapture()
+	s.createOrder(resp, r, body)
+	// server side failures are not remembered, the retry should get a real second chance
+	if resp.status < http.StatusInternalServerError {
+		s.idempotency.finish(key, resp)
+	}
+	resp.writeTo(w)
+}
+
+// createOrder decodes the order as strictly as the consumer workers do and processes it.
+func (s *Server) createOrder(w http.ResponseWriter, r *http.Request, body []byte) {
+	var order domain.Order
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&order); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("malformed order json: %v", err))
+		return
+	}
+	if dec.More() {
+		s.writeError(w, r, http.StatusBadRequest, "request body must contain a single order")
+		return
+	}
+
+	err := s.service.ProcessNewOrder(r.Context(), &order)
+	switch {
+	case err == nil:
+		w.Header().Set("Location", "/api/v1/orders/"+order.OrderUID)
+		s.writeJSON(w, r, http.StatusCreated, map[string]string{"order_uid": order.OrderUID})
+	case errors.Is(err, domain.ErrInvalidOrder):
+		s.writeError(w, r, http.StatusUnprocessableEntity, domain.ErrInvalidOrder.Error())
+	case errors.Is(err, store.ErrAlreadyExists):
+		s.writeError(w, r, http.StatusConflict, fmt.Sprintf("order %s already exists", order.OrderUID))
+	default:
+		s.storeError(w, r, err)
+	}
+}
+
+// isJSONRequest turns away the request with a 415 unless it's JSON, or doesn't say what it is.
+func (s *Server) isJSONRequest(w http.ResponseWriter, r *http.Request) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+			s.writeError(w, r, http.StatusUnsupportedMediaType, "content type must be application/json")
+			return false
+		}
+	}
+	return true
+}
+
+// storeError maps the store sentinel errors onto JSON error responses,
+// anything unknown is logged and reported as a 500.
+func (s *Server) storeError(w http.ResponseWriter, r *http.Request, err error) {
+	st, ok := transport.StoreError(err)
+	if !ok {
+		s.requestLogger(r).Errorw("server error", "error", err, "request_method", r.Method, "request_uri", r.URL.RequestURI())
+		s.writeError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	if st.HTTP == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "5")
+	}
+	s.writeError(w, r, st.HTTP, st.Message)
+}
+
+// writeJSON encodes v as the response body. The encoding is done
+// into memory first, so a failure can still be reported as a 500.
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		s.requestLogger(r).Errorw("failed to encode json response", "error", err, "request_uri", r.URL.RequestURI())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	s.writeJSON(w, r, status, apiError{Error: msg})
+}
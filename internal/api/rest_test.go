@@ -0,0 +1,389 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_apiGetOrder(t *testing.T) {
+	mockService, mockLogger := new(MockOrderService), logger.NewMockLogger()
+	server, err := NewServer(mockService, mockLogger, config.HTTPServerConfig{})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		uid        string
+		setupMocks func()
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name: "success",
+			uid:  "testuid",
+			setupMocks: func() {
+				mockService.On("GetOrder", mock.Anything, "testuid").Return(&domain.Order{OrderUID: "testuid"}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "malformed uid",
+			uid:        "bad-uid",
+			setupMocks: func() {},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "order uid must be a non-empty alphanumeric string",
+		},
+		{
+			name: "not found",
+			uid:  "missing",
+			setupMocks: func() {
+				mockService.On("GetOrder", mock.Anything, "missing").
+					Return(nil, fmt.Errorf("wrapped: %w", store.ErrNotFound)).Once()
+			},
+			wantStatus: http.StatusNotFound,
+			wantError:  store.ErrNotFound.Error(),
+		},
+		{
+			name: "db down",
+			uid:  "dbdown",
+			setupMocks: func() {
+				mockService.On("GetOrder", mock.Anything, "dbdown").
+					Return(nil, fmt.Errorf("wrapped: %w", store.ErrConnectionFailed)).Once()
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantError:  store.ErrConnectionFailed.Error(),
+		},
+		{
+			name: "unexpected error",
+			uid:  "boom",
+			setupMocks: func() {
+				mockService.On("GetOrder", mock.Anything, "boom").Return(nil, errors.New("boom")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantError:  http.StatusText(http.StatusInternalServerError),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMocks()
+
+			req := httptest.NewRequest("GET", "/api/v1/orders/"+tc.uid, nil)
+			req.SetPathValue("uid", tc.uid)
+			rr := httptest.NewRecorder()
+
+			server.apiGetOrder(rr, req)
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			if tc.wantError != "" {
+				var body apiError
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, tc.wantError, body.Error)
+			} else {
+				var order domain.Order
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &order))
+				assert.Equal(t, tc.uid, order.OrderUID)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_apiListOrders(t *testing.T) {
+	mockService, mockLogger := new(MockOrderService), logger.NewMockLogger()
+	server, err := NewServer(mockService, mockLogger, config.HTTPServerConfig{})
+	require.NoError(t, err)
+
+	t.Run("filters and cursor are passed down", func(t *testing.T) {
+		want := store.ListQuery{
+			OrderFilter: store.OrderFilter{
+				CustomerID:      "cust",
+				DeliveryService: "meest",
+				Locale:          "en",
+				CreatedFrom:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:       time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				Currency:        "USD",
+				Provider:        "wbpay",
+			},
+			Sort:   store.OrderSort{Field: store.SortAmount, Ascending: true},
+			Cursor: "abc",
+			Limit:  5,
+		}
+		page := &store.OrderPage{Orders: []*domain.Order{{OrderUID: "uid1"}}, NextCursor: "next"}
+		mockService.On("ListOrders", mock.Anything, want).Return(page, nil).Once()
+
+		req := httptest.NewRequest("GET", "/api/v1/orders?customer_id=cust&delivery_service=meest&locale=en"+
+			"&created_from=2024-01-01&created_to=2024-01-31&currency=USD&provider=wbpay&sort=amount&order=asc&cursor=abc&limit=5", nil)
+		rr := httptest.NewRecorder()
+
+		server.apiListOrders(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got store.OrderPage
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, "next", got.NextCursor)
+		require.Len(t, got.Orders, 1)
+		assert.Equal(t, "uid1", got.Orders[0].OrderUID)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("bad input", func(t *testing.T) {
+		for _, rawQuery := range []string{"limit=-1", "limit=abc", "created_from=yesterday", "created_to=2024-13-01", "sort=bank", "order=up"} {
+			req := httptest.NewRequest("GET", "/api/v1/orders?"+rawQuery, nil)
+			rr := httptest.NewRecorder()
+
+			server.apiListOrders(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, rawQuery)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		mockService.On("ListOrders", mock.Anything, store.ListQuery{Cursor: "garbage"}).
+			Return(nil, fmt.Errorf("wrapped: %w", store.ErrInvalidCursor)).Once()
+
+		req := httptest.NewRequest("GET", "/api/v1/orders?cursor=garbage", nil)
+		rr := httptest.NewRecorder()
+
+		server.apiListOrders(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestServer_apiCreateOrder(t *testing.T) {
+	validJSON := `{"order_uid":"newuid","track_number":"track"}`
+
+	newServer := func(t *testing.T) (*Server, *MockOrderService) {
+		mockService := new(MockOrderService)
+		server, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{MaxBodyBytes: 256})
+		require.NoError(t, err)
+		return server, mockService
+	}
+	post := func(server *Server, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		server.apiCreateOrder(rr, req)
+		return rr
+	}
+
+	testCases := []struct {
+		name       string
+		body       string
+		processErr error
+		wantStatus int
+	}{
+		{name: "created", body: validJSON, wantStatus: http.StatusCreated},
+		{name: "invalid order", body: validJSON, processErr: fmt.Errorf("validation failed: %w", domain.ErrInvalidOrder), wantStatus: http.StatusUnprocessableEntity},
+		{name: "duplicate", body: validJSON, processErr: fmt.Errorf("failed to save order: %w", store.ErrAlreadyExists), wantStatus: http.StatusConflict},
+		{name: "db down", body: validJSON, processErr: store.ErrConnectionFailed, wantStatus: http.StatusServiceUnavailable},
+		{name: "malformed json", body: `{"order_uid":`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", body: `{"order_uid":"newuid","surprise":1}`, wantStatus: http.StatusBadRequest},
+		{name: "two orders", body: validJSON + validJSON, wantStatus: http.StatusBadRequest},
+		{name: "too large", body: `{"order_uid":"` + strings.Repeat("a", 300) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, mockService := newServer(t)
+			if tc.wantStatus != http.StatusBadRequest && tc.wantStatus != http.StatusRequestEntityTooLarge {
+				mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Return(tc.processErr).Once()
+			}
+
+			rr := post(server, tc.body, "")
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			if tc.wantStatus == http.StatusCreated {
+				assert.Equal(t, "/api/v1/orders/newuid", rr.Header().Get("Location"))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+
+	t.Run("wrong content type", func(t *testing.T) {
+		server, _ := newServer(t)
+		req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(validJSON))
+		req.Header.Set("Content-Type", "text/plain")
+		rr := httptest.NewRecorder()
+
+		server.apiCreateOrder(rr, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+
+	t.Run("idempotent retry gets the first response", func(t *testing.T) {
+		server, mockService := newServer(t)
+		mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Return(nil).Once()
+
+		first := post(server, validJSON, "key-1")
+		second := post(server, validJSON, "key-1")
+
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+		mockService.AssertNumberOfCalls(t, "ProcessNewOrder", 1)
+	})
+
+	t.Run("key reused with another body", func(t *testing.T) {
+		server, mockService := newServer(t)
+		mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Return(nil).Once()
+
+		post(server, validJSON, "key-2")
+		rr := post(server, `{"order_uid":"otheruid"}`, "key-2")
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		mockService.AssertNumberOfCalls(t, "ProcessNewOrder", 1)
+	})
+
+	t.Run("server errors are not remembered", func(t *testing.T) {
+		server, mockService := newServer(t)
+		mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Return(store.ErrConnectionFailed).Once()
+		mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Return(nil).Once()
+
+		first := post(server, validJSON, "key-3")
+		second := post(server, validJSON, "key-3")
+
+		assert.Equal(t, http.StatusServiceUnavailable, first.Code)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("a panic releases the key", func(t *testing.T) {
+		server, mockService := newServer(t)
+		mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Run(func(mock.Arguments) { panic("boom") }).Once()
+		mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Return(nil).Once()
+
+		assert.Panics(t, func() { post(server, validJSON, "key-4") })
+		rr := post(server, validJSON, "key-4")
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("too many keys", func(t *testing.T) {
+		server, mockService := newServer(t)
+		server.idempotency = newIdempotencyCache(time.Hour, 1)
+		mockService.On("ProcessNewOrder", mock.Anything, mock.Anything).Return(nil).Once()
+
+		assert.Equal(t, http.StatusCreated, post(server, validJSON, "key-5").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, post(server, validJSON, "key-6").Code)
+		assert.Equal(t, http.StatusCreated, post(server, validJSON, "key-5").Code, "a remembered key still answers")
+		mockService.AssertNumberOfCalls(t, "ProcessNewOrder", 1)
+	})
+}
+
+func TestServer_apiBatchGetOrders(t *testing.T) {
+	mockService, mockLogger := new(MockOrderService), logger.NewMockLogger()
+	server, err := NewServer(mockService, mockLogger, config.HTTPServerConfig{})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		body        string
+		contentType string
+		setupMocks  func()
+		wantStatus  int
+	}{
+		{
+			name: "found and not found",
+			body: `{"order_uids": ["a", "nope"]}`,
+			setupMocks: func() {
+				mockService.On("GetOrders", mock.Anything, []string{"a", "nope"}).
+					Return([]*domain.Order{{OrderUID: "a"}, nil}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "no uids",
+			body:       `{"order_uids": []}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too many uids",
+			body:       `{"order_uids": ["a"` + strings.Repeat(`, "a"`, maxBatchGet) + `]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed uid",
+			body:       `{"order_uids": ["a", "../b"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown field",
+			body:       `{"uids": ["a"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "not json",
+			body:        `a,b`,
+			contentType: "text/csv",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "db down",
+			body: `{"order_uids": ["a"]}`,
+			setupMocks: func() {
+				mockService.On("GetOrders", mock.Anything, []string{"a"}).Return(nil, store.ErrConnectionFailed).Once()
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.setupMocks != nil {
+				tc.setupMocks()
+			}
+			req := httptest.NewRequest("POST", "/api/v1/orders:batchGet", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			server.httpServer.Handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+
+	t.Run("results line up with the uids", func(t *testing.T) {
+		mockService.On("GetOrders", mock.Anything, []string{"a", "nope"}).
+			Return([]*domain.Order{{OrderUID: "a"}, nil}, nil).Once()
+		req := httptest.NewRequest("POST", "/api/v1/orders:batchGet", strings.NewReader(`{"order_uids": ["a", "nope"]}`))
+		rr := httptest.NewRecorder()
+
+		server.httpServer.Handler.ServeHTTP(rr, req)
+
+		var got struct {
+			Results []batchGetResult `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		require.Len(t, got.Results, 2)
+		assert.Equal(t, "a", got.Results[0].OrderUID)
+		assert.True(t, got.Results[0].Found)
+		assert.Equal(t, "a", got.Results[0].Order.OrderUID)
+		assert.Equal(t, batchGetResult{OrderUID: "nope"}, got.Results[1])
+	})
+}
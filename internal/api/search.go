@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// OrderLookup resolves the identifiers the customers have at hand to order uids,
+// the *store.DBStore implements it.
+type OrderLookup interface {
+	LookupOrders(ctx context.Context, value string, kinds ...store.LookupKind) ([]store.LookupMatch, error)
+}
+
+// WithLookup enables the search by track number, transaction and item rid: /api/v1/search and
+// the /search page. Without it both only find the orders by their uid.
+func WithLookup(l OrderLookup) Option {
+	return func(s *Server) {
+		s.lookup = l
+	}
+}
+
+// lookupFields are the fields of the order the lookup kinds match, by their json paths.
+var lookupFields = map[store.LookupKind]string{
+	store.LookupOrderUID:    "order_uid",
+	store.LookupTrackNumber: "track_number",
+	store.LookupTransaction: "payment.transaction",
+	store.LookupRID:         "items.rid",
+}
+
+// lookupHidden reports whether the kind matches a field that's redacted for the caller:
+// the order a raw value finds would give the value away.
+func (s *Server) lookupHidden(ctx context.Context, kind store.LookupKind) bool {
+	return s.redactor.HidesForCaller(ctx, lookupFields[kind])
+}
+
+// searchOrders resolves q to the matching orders, it's tried as the kind, as any of
+// them if it's empty (but those hidden from the caller, see lookupHidden). Without
+// a lookup only an order uid is found, through the service.
+func (s *Server) searchOrders(ctx context.Context, q string, kind store.LookupKind) ([]store.LookupMatch, error) {
+	matches := []store.LookupMatch{}
+	if s.lookup != nil {
+		kinds := []store.LookupKind{kind}
+		if kind == "" {
+			kinds = slices.DeleteFunc(slices.Clone(store.LookupKinds), func(k store.LookupKind) bool { return s.lookupHidden(ctx, k) })
+		}
+		if len(kinds) == 0 {
+			return matches, nil
+		}
+		return s.lookup.LookupOrders(ctx, q, kinds...)
+	}
+
+	if kind != "" && kind != store.LookupOrderUID || !transport.ValidUID(q) {
+		return matches, nil
+	}
+	if _, err := s.service.GetOrder(ctx, q); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return matches, nil
+		}
+		return nil, err
+	}
+	return append(matches, store.LookupMatch{OrderUID: q, MatchedBy: []store.LookupKind{store.LookupOrderUID}}), nil
+}
+
+// apiSearch is GET /api/v1/search?q=...[&type=...], the orders the identifier q belongs to:
+// an order uid, a track number (of the order or an item), a payment transaction or an item rid.
+// Without 'type' it's tried as each of them the caller may see, a 'type' they may not is a 403.
+// Returns {"query": "...", "matches": [...]}, at most 100 of them, newest first.
+func (s *Server) apiSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		s.writeError(w, r, http.StatusBadRequest, "q is required")
+		return
+	}
+	var kind store.LookupKind
+	if v := query.Get("type"); v != "" {
+		var err error
+		if kind, err = store.ParseLookupKind(v); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if s.lookupHidden(r.Context(), kind) {
+			s.writeError(w, r, http.StatusForbidden, fmt.Sprintf("%s is redacted for your role, the orders can't be looked up by it", kind))
+			return
+		}
+	}
+
+	matches, err := s.searchOrders(r.Context(), q, kind)
+	if err != nil {
+		s.storeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, map[string]any{"query": q, "matches": matches})
+}
+
+// searchView is the /search?q=... page the home page's search box submits to. A single
+// match redirects to its order, several are listed, none is the home page's not found.
+func (s *Server) searchView(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Redirect(w, r, "/home", http.StatusFound)
+		return
+	}
+
+	matches, err := s.searchOrders(r.Context(), q, "")
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	switch len(matches) {
+	case 0:
+		http.Redirect(w, r, fmt.Sprintf("/home?error=not_found&uid=%s", url.QueryEscape(q)), http.StatusFound)
+	case 1:
+		http.Redirect(w, r, "/orders/"+url.PathEscape(matches[0].OrderUID), http.StatusFound)
+	default:
+		s.render(w, r, http.StatusOK, "search.tmpl", map[string]any{"Query": q, "Matches": matches})
+	}
+}
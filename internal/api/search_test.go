@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLookup matches the track number 'WBILMTESTTRACK' to two orders and the rid 'rid1' to one,
+// it records the kinds of the last lookup.
+type fakeLookup struct {
+	kinds []store.LookupKind
+}
+
+func (f *fakeLookup) LookupOrders(ctx context.Context, value string, kinds ...store.LookupKind) ([]store.LookupMatch, error) {
+	f.kinds = kinds
+	switch value {
+	case "WBILMTESTTRACK":
+		return []store.LookupMatch{
+			{OrderUID: "two", MatchedBy: []store.LookupKind{store.LookupTrackNumber}},
+			{OrderUID: "one", MatchedBy: []store.LookupKind{store.LookupTrackNumber}},
+		}, nil
+	case "rid1":
+		return []store.LookupMatch{{OrderUID: "one", MatchedBy: []store.LookupKind{store.LookupRID}}}, nil
+	}
+	return []store.LookupMatch{}, nil
+}
+
+func TestServer_search(t *testing.T) {
+	serve := func(s *Server, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	lookup := &fakeLookup{}
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithLookup(lookup))
+	require.NoError(t, err)
+
+	t.Run("api", func(t *testing.T) {
+		rr := serve(server, "/api/v1/search?q=WBILMTESTTRACK")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, store.LookupKinds, lookup.kinds, "every kind is tried")
+
+		var body struct {
+			Query   string              `json:"query"`
+			Matches []store.LookupMatch `json:"matches"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, "WBILMTESTTRACK", body.Query)
+		require.Len(t, body.Matches, 2)
+		assert.Equal(t, "two", body.Matches[0].OrderUID)
+
+		rr = serve(server, "/api/v1/search?q=rid1&type=rid")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []store.LookupKind{store.LookupRID}, lookup.kinds)
+
+		rr = serve(server, "/api/v1/search?q=nothing")
+		assert.JSONEq(t, `{"query": "nothing", "matches": []}`, rr.Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, serve(server, "/api/v1/search").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(server, "/api/v1/search?q=x&type=phone").Code)
+	})
+
+	t.Run("page", func(t *testing.T) {
+		rr := serve(server, "/search?q=rid1")
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "/orders/one", rr.Header().Get("Location"))
+
+		rr = serve(server, "/search?q=WBILMTESTTRACK")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `href="/orders/two"`)
+		assert.Contains(t, rr.Body.String(), `href="/orders/one"`)
+
+		rr = serve(server, "/search?q=nothing")
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "/home?error=not_found&uid=nothing", rr.Header().Get("Location"))
+	})
+
+	t.Run("not by what's redacted", func(t *testing.T) {
+		redactor, err := redact.New(redact.DefaultRules, []byte("a-test-hash-key-of-32-bytes-long"))
+		require.NoError(t, err)
+		// anonymous callers are viewers, they only see hashed transactions
+		viewers, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithLookup(lookup), WithRedaction(redactor))
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, serve(viewers, "/api/v1/search?q=x&type=transaction").Code)
+
+		assert.Equal(t, http.StatusOK, serve(viewers, "/api/v1/search?q=x").Code)
+		assert.NotContains(t, lookup.kinds, store.LookupTransaction)
+		assert.Contains(t, lookup.kinds, store.LookupTrackNumber)
+
+		serve(viewers, "/search?q=x")
+		assert.NotContains(t, lookup.kinds, store.LookupTransaction)
+	})
+
+	t.Run("without lookup", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		mockService.On("GetOrder", mock.Anything, "one").Return(&domain.Order{OrderUID: "one"}, nil)
+		mockService.On("GetOrder", mock.Anything, "WBILMTESTTRACK").Return(nil, store.ErrNotFound)
+		server, err := NewServer(mockService, logger.NewMockLogger(), config.HTTPServerConfig{})
+		require.NoError(t, err)
+
+		rr := serve(server, "/api/v1/search?q=one")
+		assert.JSONEq(t, `{"query": "one", "matches": [{"order_uid": "one", "matched_by": ["order_uid"]}]}`, rr.Body.String())
+		rr = serve(server, "/api/v1/search?q=WBILMTESTTRACK")
+		assert.JSONEq(t, `{"query": "WBILMTESTTRACK", "matches": []}`, rr.Body.String())
+		rr = serve(server, "/api/v1/search?q=one&type=rid")
+		assert.JSONEq(t, `{"query": "one", "matches": []}`, rr.Body.String())
+	})
+}
@@ -7,38 +7,128 @@ import (
 	"html/template"
 	"io/fs"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"errors"
 
 	"github.com/goinginblind/l0-task/internal/api/ui"
 	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/export"
+	"github.com/goinginblind/l0-task/internal/feed"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/health"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
 	"github.com/goinginblind/l0-task/internal/service"
 	"github.com/goinginblind/l0-task/internal/store"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Fallbacks for the zero values of config.HTTPServerConfig
+const (
+	defaultMaxBodyBytes   = 1 << 20 // 1Mb
+	defaultIdempotencyTTL = 24 * time.Hour
+	defaultIdempotencyMax = 100_000
+	defaultImportBatch    = 500
+)
+
 // Server is the HTTP server.
 type Server struct {
 	service       service.OrderService
 	logger        logger.Logger
 	httpServer    *http.Server
 	templateCache map[string]*template.Template
+	templateVer   string // of the template sources, goes into the etags of the pages
+	idempotency   *idempotencyCache
+	maxBodyBytes  int64
+	cacheControl  string // of the order responses, none if empty
+
+	importBatchSize    int
+	importBatchTimeout time.Duration // read/write deadline extension per imported batch
+
+	health    *health.Registry
+	auth      *auth.Authenticator // nil if auth is off
+	anonymous auth.Identity       // of every caller while auth is off
+	redactor  *redact.Redactor    // nil if orders go out unredacted
+
+	feed            *feed.Broadcaster
+	streamHeartbeat time.Duration
+	streamsDone     chan struct{} // closed on shutdown, the streams would hold it up otherwise
+
+	exporter           export.Source // nil if the export is off
+	exportRowGroupSize int
+	exportWriteTimeout time.Duration
+	exportSlots        chan struct{} // a semaphore of the exports running
+
+	cacheAdmin     CacheAdmin // nil if the cache admin is off
+	cacheWarmLimit int
+
+	consumerAdmin ConsumerAdmin // nil if the consumer admin is off
+
+	dlq DLQBrowser // nil if the dlq browser is off
+
+	customers CustomerSource // nil if the customer history is off
+	lookup    OrderLookup    // nil if the orders are only searched by uid
+	analytics Analytics      // nil if the analytics are off
 }
 
+// Option configures the optional parts of the Server.
+type Option func(*Server)
+
 // NewServer creates a new Server.
-func NewServer(service service.OrderService, logger logger.Logger, cfg config.HTTPServerConfig) (*Server, error) {
+func NewServer(service service.OrderService, logger logger.Logger, cfg config.HTTPServerConfig, opts ...Option) (*Server, error) {
 	templateCache, err := newTemplateCache()
 	if err != nil {
 		return nil, err
 	}
+	templateVer, err := templateVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		cfg.IdempotencyTTL = defaultIdempotencyTTL
+	}
+	if cfg.IdempotencyMaxKeys <= 0 {
+		cfg.IdempotencyMaxKeys = defaultIdempotencyMax
+	}
+	if cfg.ImportBatchSize <= 0 {
+		cfg.ImportBatchSize = defaultImportBatch
+	}
 
 	srv := &Server{
 		service:       service,
 		logger:        logger,
 		templateCache: templateCache,
+		templateVer:   templateVer,
+		idempotency:   newIdempotencyCache(cfg.IdempotencyTTL, cfg.IdempotencyMaxKeys),
+		maxBodyBytes:  cfg.MaxBodyBytes,
+		cacheControl:  cfg.OrderCacheControl,
+
+		importBatchSize:    cfg.ImportBatchSize,
+		importBatchTimeout: max(cfg.ReadTimeout, cfg.WriteTimeout),
+
+		anonymous: transport.Unauthenticated,
+
+		streamHeartbeat: cfg.Stream.Heartbeat,
+		streamsDone:     make(chan struct{}),
+	}
+	if srv.streamHeartbeat <= 0 {
+		srv.streamHeartbeat = defaultStreamHeartbeat
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	if srv.auth == nil {
+		srv.disableAdmin()
 	}
 
 	mux := http.NewServeMux()
@@ -46,18 +136,85 @@ func NewServer(service service.OrderService, logger logger.Logger, cfg config.HT
 
 	mux.HandleFunc("/", srv.home)
 	mux.HandleFunc("/home", srv.home)
+	mux.HandleFunc("GET /orders", srv.ordersView)
 	mux.HandleFunc("/orders/", srv.orderView)
+	mux.HandleFunc("GET /search", srv.searchView)
+	mux.HandleFunc("GET /customers/{id}", srv.customerView)
+	mux.HandleFunc("GET /admin/dlq", srv.dlqPage)
+	mux.HandleFunc("GET /admin/dlq/{id}", srv.dlqMessagePage)
+
+	// JSON API
+	mux.HandleFunc("GET /api/v1/orders", srv.apiListOrders)
+	mux.HandleFunc("POST /api/v1/orders", srv.apiCreateOrder)
+	mux.HandleFunc("POST /api/v1/orders:import", srv.apiImportOrders)
+	mux.HandleFunc("POST /api/v1/orders:batchGet", srv.apiBatchGetOrders)
+	mux.HandleFunc("GET /api/v1/orders/stream", srv.apiOrderStream)
+	mux.HandleFunc("GET /api/v1/orders/{uid}", srv.apiGetOrder)
+	mux.HandleFunc("GET /api/v1/customers/{id}/orders", srv.apiCustomerOrders)
+	mux.HandleFunc("GET /api/v1/search", srv.apiSearch)
+	mux.HandleFunc("GET /api/v1/analytics/revenue", srv.apiAnalyticsRevenue)
+	mux.HandleFunc("GET /api/v1/analytics/orders", srv.apiAnalyticsOrders)
+	mux.HandleFunc("GET /api/v1/analytics/items", srv.apiAnalyticsItems)
+	mux.HandleFunc("GET /api/v1/analytics/payments", srv.apiAnalyticsPayments)
+	mux.HandleFunc("GET /api/v1/exports", srv.apiExportOrders)
+
+	// admin
+	mux.HandleFunc("GET /api/v1/admin/cache", srv.apiCacheStats)
+	mux.HandleFunc("DELETE /api/v1/admin/cache", srv.apiCachePurge)
+	mux.HandleFunc("POST /api/v1/admin/cache:warm", srv.apiCacheWarm)
+	mux.HandleFunc("GET /api/v1/admin/cache/{uid}", srv.apiCacheLookup)
+	mux.HandleFunc("DELETE /api/v1/admin/cache/{uid}", srv.apiCacheEvict)
+	mux.HandleFunc("GET /api/v1/admin/consumer", srv.apiConsumerStatus)
+	mux.HandleFunc("POST /api/v1/admin/consumer:pause", srv.apiConsumerPause)
+	mux.HandleFunc("POST /api/v1/admin/consumer:resume", srv.apiConsumerResume)
+	mux.HandleFunc("POST /api/v1/admin/consumer:seek", srv.apiConsumerSeek)
+	mux.HandleFunc("POST /api/v1/admin/consumer:resize", srv.apiConsumerResize)
+	mux.HandleFunc("GET /api/v1/admin/dlq", srv.apiListDLQ)
+	mux.HandleFunc("POST /api/v1/admin/dlq:replay", srv.apiReplayDLQ)
+	mux.HandleFunc("POST /api/v1/admin/dlq:discard", srv.apiDiscardDLQ)
+	mux.HandleFunc("GET /api/v1/admin/dlq/{id}", srv.apiGetDLQ)
+
+	var handler http.Handler = mux
+	if cfg.Compression.Enabled {
+		if cfg.Compression.MinSizeBytes <= 0 {
+			cfg.Compression.MinSizeBytes = defaultCompressMinSize
+		}
+		handler = compressionMiddleware(mux, cfg.Compression.MinSizeBytes)
+	}
+	var shedder *loadShedder
+	if cfg.RateLimit.RequestsPerSecond > 0 || cfg.RateLimit.MaxConcurrent > 0 {
+		shedder = newLoadShedder(cfg.RateLimit)
+		handler = shedder.middleware(handler)
+	}
 
 	mainMux := http.NewServeMux()
 	mainMux.Handle("/metrics", promhttp.Handler())
-	mainMux.Handle("/", metricsMiddleware(mux))
+	// probes are hit every few seconds, they'd only add noise to the http metrics,
+	// and they are not rate limited: a shedding server is alive
+	mainMux.HandleFunc("GET /livez", srv.livez)
+	mainMux.HandleFunc("GET /readyz", srv.readyz)
+	mainMux.HandleFunc("GET /healthz", srv.healthz)
+	mainMux.Handle("/", metricsMiddleware(handler))
+
+	authed := srv.authMiddleware(mainMux)
+	if shedder != nil && shedder.limit > 0 && srv.auth != nil {
+		// the rate limit only sees the requests that got past auth, the failed ones need a limit of their own
+		authed = shedder.authGuard(authed)
+	}
+
+	// the access log goes around auth and recovery, denials and panics are what it's for
+	root := recoveryMiddleware(authed, logger)
+	if cfg.AccessLog.Enabled {
+		root = accessLogMiddleware(root, logger, cfg.AccessLog.SampleRate, cfg.AccessLog.SlowThreshold)
+	}
 
 	srv.httpServer = &http.Server{
-		Handler:      recoveryMiddleware(mainMux, logger),
+		Handler:      requestIDMiddleware(root),
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 		IdleTimeout:  cfg.IdleTimeout,
 	}
+	srv.httpServer.RegisterOnShutdown(func() { close(srv.streamsDone) })
 
 	return srv, nil
 }
@@ -99,13 +256,14 @@ func (s *Server) home(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if errMsg == "not_found" {
-		data["Error"] = fmt.Sprintf("Order with UID '%s' not found", uid)
+		data["Error"] = fmt.Sprintf("No order matches '%s'", uid)
 	}
 
 	s.render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
-// order page handler
+// order page handler. The same url serves several representations of the order
+// (see negotiateFormat): the html page, JSON, a CSV of the items and a printable invoice.
 func (s *Server) orderView(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
@@ -118,21 +276,63 @@ func (s *Server) orderView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format, ok := negotiateFormat(r)
+	if !ok {
+		http.Error(w, "unknown format, expected one of: html, json, csv, print", http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
 	order, err := s.service.GetOrder(r.Context(), uid)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			redirectURL := fmt.Sprintf("/home?error=not_found&uid=%s", uid)
+		switch {
+		case format == formatJSON:
+			s.storeError(w, r, err)
+		case errors.Is(err, store.ErrNotFound) && format == formatCSV:
+			http.NotFound(w, r)
+		case errors.Is(err, store.ErrNotFound):
+			redirectURL := fmt.Sprintf("/home?error=not_found&uid=%s", url.QueryEscape(uid))
 			http.Redirect(w, r, redirectURL, http.StatusFound)
+		default:
+			s.serverError(w, r, err)
+		}
+		return
+	}
+
+	order = s.redactor.ForCaller(r.Context(), order)
+	// the customer pages need support, viewers only see a hashed id anyway
+	customerLink := s.customers != nil && auth.FromContext(r.Context()).Role.Allows(auth.RoleSupport)
+
+	var pageParts []string
+	if format == formatHTML || format == formatPrint {
+		pageParts = []string{s.templateVer, strconv.FormatBool(customerLink)}
+	}
+	if order != nil && s.notModified(w, r, order, format, pageParts...) {
+		return
+	}
+
+	switch format {
+	case formatJSON:
+		if order == nil {
+			s.writeError(w, r, http.StatusNotFound, store.ErrNotFound.Error())
 			return
 		}
-		s.serverError(w, r, err)
+		s.writeJSON(w, r, http.StatusOK, order)
+		return
+	case formatCSV:
+		if order == nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.writeItemsCSV(w, r, order)
 		return
 	}
 
 	data := map[string]any{
-		"OrderUID":   uid,
-		"OrderFound": order != nil,
-		"Order":      order,
+		"OrderUID":     uid,
+		"OrderFound":   order != nil,
+		"Order":        order,
+		"CustomerLink": customerLink,
 	}
 
 	status := http.StatusOK
@@ -140,7 +340,11 @@ func (s *Server) orderView(w http.ResponseWriter, r *http.Request) {
 		status = http.StatusNotFound
 	}
 
-	s.render(w, r, status, "order.tmpl", data)
+	page := "order.tmpl"
+	if format == formatPrint {
+		page = "order_print.tmpl"
+	}
+	s.render(w, r, status, page, data)
 }
 
 // render the template: its usueless actually and couldve been doen w/o templating
@@ -160,12 +364,13 @@ func (s *Server) render(w http.ResponseWriter, r *http.Request, status int, page
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
 
 func (s *Server) serverError(w http.ResponseWriter, r *http.Request, err error) {
-	s.logger.Errorw("server error", "error", err, "request_method", r.Method, "request_uri", r.URL.RequestURI())
+	s.requestLogger(r).Errorw("server error", "error", err, "request_method", r.Method, "request_uri", r.URL.RequestURI())
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
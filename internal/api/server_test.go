@@ -2,17 +2,24 @@ package api
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/goinginblind/l0-task/internal/config"
 	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/export"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
 	"github.com/goinginblind/l0-task/internal/service"
+	"github.com/goinginblind/l0-task/internal/store"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockOrderService is a mock implementation of the OrderService.
@@ -27,6 +34,14 @@ func (m *MockOrderService) ProcessNewOrder(ctx context.Context, order *domain.Or
 	return args.Error(0)
 }
 
+func (m *MockOrderService) ProcessNewOrders(ctx context.Context, orders []*domain.Order) []error {
+	args := m.Called(ctx, orders)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).([]error)
+}
+
 func (m *MockOrderService) GetOrder(ctx context.Context, uid string) (*domain.Order, error) {
 	args := m.Called(ctx, uid)
 	if args.Get(0) == nil {
@@ -35,6 +50,22 @@ func (m *MockOrderService) GetOrder(ctx context.Context, uid string) (*domain.Or
 	return args.Get(0).(*domain.Order), args.Error(1)
 }
 
+func (m *MockOrderService) GetOrders(ctx context.Context, uids []string) ([]*domain.Order, error) {
+	args := m.Called(ctx, uids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Order), args.Error(1)
+}
+
+func (m *MockOrderService) ListOrders(ctx context.Context, q store.ListQuery) (*store.OrderPage, error) {
+	args := m.Called(ctx, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.OrderPage), args.Error(1)
+}
+
 func TestServer_orderHandler(t *testing.T) {
 	mockService, mockLogger := new(MockOrderService), logger.NewMockLogger()
 	server, _ := NewServer(mockService, mockLogger, config.HTTPServerConfig{})
@@ -65,3 +96,150 @@ func TestServer_orderHandler(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 }
+
+func TestServer_orderView_formats(t *testing.T) {
+	mockService, mockLogger := new(MockOrderService), logger.NewMockLogger()
+	server, err := NewServer(mockService, mockLogger, config.HTTPServerConfig{})
+	require.NoError(t, err)
+
+	order := &domain.Order{
+		OrderUID:    "fmtuid",
+		DateCreated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Payment:     domain.Payment{Amount: 1817, Currency: "USD"},
+		Items: []domain.Item{
+			{ChrtID: 1, Name: "Mascaras", Brand: "Vivienne Sabo", Price: 453, TotalPrice: 317},
+			{ChrtID: 2, Name: "Lipstick, red", Brand: "Vivienne Sabo", Price: 200, TotalPrice: 200},
+		},
+	}
+	mockService.On("GetOrder", mock.Anything, "fmtuid").Return(order, nil)
+
+	testCases := []struct {
+		name            string
+		target          string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		check           func(t *testing.T, body string)
+	}{
+		{
+			name:            "html by default",
+			target:          "/orders/fmtuid",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html",
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, "Order: fmtuid")
+			},
+		},
+		{
+			name:            "html for a browser",
+			target:          "/orders/fmtuid",
+			accept:          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html",
+		},
+		{
+			name:            "json by accept",
+			target:          "/orders/fmtuid",
+			accept:          "application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			check: func(t *testing.T, body string) {
+				var got domain.Order
+				require.NoError(t, json.Unmarshal([]byte(body), &got))
+				assert.Equal(t, "fmtuid", got.OrderUID)
+				assert.Len(t, got.Items, 2)
+			},
+		},
+		{
+			name:            "json preferred by quality",
+			target:          "/orders/fmtuid",
+			accept:          "text/html;q=0.5, application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+		},
+		{
+			name:            "csv by accept",
+			target:          "/orders/fmtuid",
+			accept:          "text/csv",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			check: func(t *testing.T, body string) {
+				records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+				require.NoError(t, err)
+				require.Len(t, records, 3)
+				assert.Equal(t, export.ItemsCSVHeader, records[0])
+				assert.Equal(t, "fmtuid", records[1][0])
+				assert.Equal(t, "Lipstick, red", records[2][5])
+			},
+		},
+		{
+			name:            "query param overrides accept",
+			target:          "/orders/fmtuid?format=csv",
+			accept:          "application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+		},
+		{
+			name:            "printable invoice",
+			target:          "/orders/fmtuid?format=print",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html",
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, "Invoice")
+				assert.Contains(t, body, "/static/css/print.css")
+				assert.Contains(t, body, "2024-05-01 12:00")
+				assert.Contains(t, body, "1817 USD")
+			},
+		},
+		{
+			name:       "unknown format",
+			target:     "/orders/fmtuid?format=xml",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.target, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			server.orderView(rr, req)
+
+			assert.Equal(t, tc.wantStatus, rr.Code)
+			if tc.wantContentType != "" {
+				assert.Contains(t, rr.Header().Get("Content-Type"), tc.wantContentType)
+				assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+			}
+			if tc.check != nil {
+				tc.check(t, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("not found as json", func(t *testing.T) {
+		mockService.On("GetOrder", mock.Anything, "missing").Return(nil, store.ErrNotFound).Once()
+
+		req := httptest.NewRequest("GET", "/orders/missing", nil)
+		req.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+
+		server.orderView(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	})
+
+	t.Run("not found as csv", func(t *testing.T) {
+		mockService.On("GetOrder", mock.Anything, "missing").Return(nil, store.ErrNotFound).Once()
+
+		req := httptest.NewRequest("GET", "/orders/missing?format=csv", nil)
+		rr := httptest.NewRecorder()
+
+		server.orderView(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
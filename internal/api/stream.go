@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/feed"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// defaultStreamHeartbeat is the fallback for the zero value of config.StreamConfig.Heartbeat
+const defaultStreamHeartbeat = 15 * time.Second
+
+// WithOrderFeed sets the broadcaster /api/v1/orders/stream subscribes to.
+// Without it the stream is a 404.
+func WithOrderFeed(b *feed.Broadcaster) Option {
+	return func(s *Server) {
+		s.feed = b
+	}
+}
+
+// orderSummary is what the stream tells about an order, enough for a dashboard.
+type orderSummary struct {
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	CustomerID      string    `json:"customer_id"`
+	DeliveryService string    `json:"delivery_service"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	Items           int       `json:"items"`
+	DateCreated     time.Time `json:"date_created"`
+}
+
+func newOrderSummary(o *domain.Order) orderSummary {
+	return orderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		CustomerID:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		Items:           len(o.Items),
+		DateCreated:     o.DateCreated,
+	}
+}
+
+// apiOrderStream is GET /api/v1/orders/stream, the orders stored by the consumer as
+// Server-Sent Events, optionally filtered by 'customer_id' and 'delivery_service'.
+// A client that reconnects with 'Last-Event-ID' gets what it missed, as long as it's
+// still in the broadcaster's ring; one that falls behind is disconnected.
+func (s *Server) apiOrderStream(w http.ResponseWriter, r *http.Request) {
+	if s.feed == nil {
+		s.writeError(w, r, http.StatusNotFound, "the order stream is not enabled")
+		return
+	}
+
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "Last-Event-ID must be an event id")
+			return
+		}
+	}
+
+	customerID, deliveryService := r.URL.Query().Get("customer_id"), r.URL.Query().Get("delivery_service")
+	if field := transport.HiddenFilter(r.Context(), s.redactor, store.OrderFilter{CustomerID: customerID, DeliveryService: deliveryService}); field != "" {
+		s.writeError(w, r, http.StatusForbidden, fmt.Sprintf("%s is redacted for your role, the orders can't be filtered by it", field))
+		return
+	}
+	var filter feed.Filter
+	if customerID != "" || deliveryService != "" {
+		filter = func(o *domain.Order) bool {
+			return (customerID == "" || o.CustomerID == customerID) &&
+				(deliveryService == "" || o.DeliveryService == deliveryService)
+		}
+	}
+
+	// the stream outlives the server's write timeout by design
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		s.serverError(w, r, err)
+		return
+	}
+
+	sub, replay := s.feed.Subscribe(lastID, filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // or nginx holds the events back
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range replay {
+		if err := s.writeEvent(w, r, ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(s.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-heartbeat.C:
+			// a comment, it keeps proxies from closing an idle connection
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-sub.C:
+			if !ok {
+				// dropped for falling behind, the client reconnects with its Last-Event-ID
+				return
+			}
+			if err := s.writeEvent(w, r, ev); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes the order as an 'order' event, redacted for the caller.
+func (s *Server) writeEvent(w http.ResponseWriter, r *http.Request, ev feed.Event) error {
+	data, err := json.Marshal(newOrderSummary(s.redactor.ForCaller(r.Context(), ev.Order)))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", ev.ID, data)
+	return err
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/feed"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is an event as read off the wire.
+type sseEvent struct {
+	id, event, data string
+}
+
+// readEvent reads the next event, skipping the comments (heartbeats).
+func readEvent(t *testing.T, sc *bufio.Scanner) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if ev.event != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	t.Fatalf("stream ended: %v", sc.Err())
+	return ev
+}
+
+func TestServer_apiOrderStream(t *testing.T) {
+	b := feed.NewBroadcaster(16, 16)
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{}, WithOrderFeed(b))
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.httpServer.Handler)
+	defer ts.Close()
+
+	open := func(t *testing.T, query, lastID string) (*http.Response, *bufio.Scanner) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/v1/orders/stream"+query, nil)
+		require.NoError(t, err)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp, bufio.NewScanner(resp.Body)
+	}
+	// the handler only unsubscribes once it notices the client is gone
+	waitSubscribers := func(n int) {
+		require.Eventually(t, func() bool { return b.Subscribers() == n }, time.Second, 5*time.Millisecond)
+	}
+
+	t.Run("filtered events", func(t *testing.T) {
+		resp, sc := open(t, "?delivery_service=meest", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+		waitSubscribers(1)
+
+		b.Publish(&domain.Order{OrderUID: "skipped", DeliveryService: "dhl"})
+		b.Publish(&domain.Order{OrderUID: "streamed", DeliveryService: "meest", Items: []domain.Item{{}, {}}})
+
+		ev := readEvent(t, sc)
+		assert.Equal(t, "order", ev.event)
+		var got orderSummary
+		require.NoError(t, json.Unmarshal([]byte(ev.data), &got))
+		assert.Equal(t, "streamed", got.OrderUID)
+		assert.Equal(t, 2, got.Items)
+	})
+	waitSubscribers(0)
+
+	t.Run("resumed with Last-Event-ID", func(t *testing.T) {
+		first, sc := open(t, "", "")
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		waitSubscribers(1)
+		b.Publish(&domain.Order{OrderUID: "one"})
+		b.Publish(&domain.Order{OrderUID: "two"})
+		seen := readEvent(t, sc)
+		first.Body.Close()
+		waitSubscribers(0)
+
+		_, sc = open(t, "", seen.id)
+		ev := readEvent(t, sc)
+		assert.Contains(t, ev.data, `"order_uid":"two"`)
+	})
+
+	t.Run("bad Last-Event-ID", func(t *testing.T) {
+		resp, _ := open(t, "", "yesterday")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("ends on shutdown", func(t *testing.T) {
+		_, sc := open(t, "", "")
+		waitSubscribers(1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, server.Shutdown(ctx))
+		for sc.Scan() {
+		}
+		waitSubscribers(0)
+	})
+}
+
+func TestServer_apiOrderStream_disabled(t *testing.T) {
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{})
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/orders/stream", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestServer_apiOrderStream_redactedFilter(t *testing.T) {
+	redactor, err := redact.New(redact.DefaultRules, []byte("a-test-hash-key-of-32-bytes-long"))
+	require.NoError(t, err)
+	server, err := NewServer(new(MockOrderService), logger.NewMockLogger(), config.HTTPServerConfig{},
+		WithOrderFeed(feed.NewBroadcaster(16, 16)), WithRedaction(redactor))
+	require.NoError(t, err)
+
+	// anonymous callers are viewers, they only see hashed customer ids
+	rr := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/orders/stream?customer_id=test", nil))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "customer_id")
+}
@@ -3,9 +3,10 @@ document.addEventListener('DOMContentLoaded', function() {
     if (form) {
         form.addEventListener('submit', function(event) {
             event.preventDefault();
-            const orderUid = document.getElementById('order-uid').value;
-            if (orderUid) {
-                window.location.href = '/orders/' + orderUid;
+            // the server tells what it is: an order uid, a track number, a transaction or an item rid
+            const query = document.getElementById('order-uid').value.trim();
+            if (query) {
+                window.location.href = '/search?q=' + encodeURIComponent(query);
             }
         });
     }
@@ -20,8 +21,73 @@ document.addEventListener('DOMContentLoaded', function() {
             }
         });
     });
+
+    const payload = document.querySelector('.dlq-payload');
+    if (payload) {
+        try {
+            const pretty = JSON.stringify(JSON.parse(payload.textContent), null, 2);
+            payload.innerHTML = syntaxHighlight(pretty);
+        } catch (e) {
+            // not json, it's shown as it is
+        }
+    }
+
+    const selectAll = document.getElementById('dlq-select-all');
+    if (selectAll) {
+        selectAll.addEventListener('change', function() {
+            document.querySelectorAll('.dlq-select').forEach(function(box) {
+                box.checked = selectAll.checked;
+            });
+        });
+    }
+
+    document.querySelectorAll('.dlq-action').forEach(function(button) {
+        button.addEventListener('click', function() {
+            let ids;
+            if (this.dataset.id) {
+                ids = [Number(this.dataset.id)];
+            } else {
+                ids = Array.from(document.querySelectorAll('.dlq-select:checked')).map(function(box) {
+                    return Number(box.value);
+                });
+            }
+            if (ids.length === 0) {
+                return;
+            }
+            resolveDeadLetters(this.dataset.action, ids, document.getElementById('dlq-note').value);
+        });
+    });
 });
 
+// resolveDeadLetters replays or discards the dead letters, reloading the page if it all went well.
+function resolveDeadLetters(action, ids, note) {
+    const result = document.getElementById('dlq-result');
+    fetch('/api/v1/admin/dlq:' + action, {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({ids: ids, note: note}),
+    })
+        .then(function(response) {
+            return response.json().then(function(body) {
+                if (!response.ok) {
+                    throw new Error(body.error || response.statusText);
+                }
+                return body;
+            });
+        })
+        .then(function(body) {
+            const failed = body.results.filter(function(r) { return r.error; });
+            if (failed.length === 0) {
+                window.location.reload();
+                return;
+            }
+            result.textContent = failed.map(function(r) { return '#' + r.id + ': ' + r.error; }).join('; ');
+        })
+        .catch(function(err) {
+            result.textContent = err.message;
+        });
+}
+
 function syntaxHighlight(json) {
     json = json.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
     return json.replace(/("(\u[a-zA-Z0-9]{4}|\\[^u]|[^\"])*"(\s*:)?|\b(true|false|null)\b|-?\d+(?:\.\d+)?(?:[eE][+\-]?\d+)?)/g, function (match) {
@@ -13,11 +13,17 @@ import (
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 
+	"github.com/goinginblind/l0-task/internal/analytics"
 	"github.com/goinginblind/l0-task/internal/api"
 	"github.com/goinginblind/l0-task/internal/config"
 	"github.com/goinginblind/l0-task/internal/consumer"
+	"github.com/goinginblind/l0-task/internal/dlq"
+	"github.com/goinginblind/l0-task/internal/feed"
+	"github.com/goinginblind/l0-task/internal/grpcapi"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
 	"github.com/goinginblind/l0-task/internal/pkg/health"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
 	"github.com/goinginblind/l0-task/internal/service"
 	"github.com/goinginblind/l0-task/internal/store"
 )
@@ -28,8 +34,12 @@ type App struct {
 	logger   logger.Logger
 	db       *sql.DB
 	server   *api.Server
+	grpc     *grpcapi.Server // nil if disabled
 	consumer *consumer.KafkaConsumer
-	hc       *health.DBHealthChecker
+	health   *health.Registry
+	cache    *service.CachingOrderService
+	dlq      *dlq.Service  // nil if disabled
+	ingester *dlq.Ingester // nil if disabled
 }
 
 // New returns a new App instance
@@ -47,36 +57,108 @@ func New() (*App, error) {
 	}
 
 	// Connect to database
-	db, err := sql.Open("pgx", cfg.Database.DSN())
+	db, err := openDB(cfg.Database)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
-	db.SetMaxOpenConns(cfg.Database.MaxIdlingConnections)
-	db.SetMaxIdleConns(cfg.Database.MaxConnections)
-	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
-	db.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
 
 	// Create store, service, and server
 	dbStore := store.NewDBStore(db, appLogger)
 	orderService := service.New(dbStore, appLogger)
 
-	// Decorate the service with cache and try to preload it
+	// Decorate the service with cache, it's preloaded once the app runs
 	cachingService := service.NewCachingOrderService(orderService, dbStore, appLogger, cfg.Cache.EntryAmountCap, cfg.Cache.EntrySizeCap)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := cachingService.Preload(ctx, cfg.Cache.PreloadSize); err != nil {
-		appLogger.Warnw(err.Error())
+
+	// Health checks: the consumer pauses on the db component, the http probes read all of them
+	registry := health.NewRegistry(appLogger)
+	dbHealth := registry.Register(health.NewDBChecker(db), health.CheckOptions{
+		Interval: cfg.Health.DBCheckInterval, Timeout: cfg.Health.DBCheckTimeout, Critical: true,
+	})
+	registry.Register(cachingService.WarmupChecker(), health.CheckOptions{
+		Interval: cfg.Health.CacheCheckInterval, Critical: true,
+	})
+
+	// the orders the consumer stores are streamed to the dashboards
+	orderFeed := feed.NewBroadcaster(cfg.HTTPServer.Stream.RingSize, cfg.HTTPServer.Stream.SubscriberBuffer)
+
+	kafkaConsumer, err := consumer.NewKafkaConsumer(cfg.Kafka, cfg.Consumer, cachingService, appLogger, dbHealth, orderFeed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
 	}
+	registry.Register(kafkaConsumer.ConsumerChecker(), health.CheckOptions{
+		Interval: cfg.Health.KafkaCheckInterval, Timeout: cfg.Health.KafkaCheckTimeout,
+	})
+	registry.Register(kafkaConsumer.DLQChecker(), health.CheckOptions{
+		Interval: cfg.Health.DLQCheckInterval, Timeout: cfg.Health.DLQCheckTimeout,
+	})
 
-	server, err := api.NewServer(cachingService, appLogger, cfg.HTTPServer)
+	// the http and grpc servers share the health, the feed, auth and redaction
+	serverOpts := []api.Option{api.WithHealth(registry), api.WithOrderFeed(orderFeed)}
+	grpcOpts := []grpcapi.Option{grpcapi.WithHealth(registry), grpcapi.WithOrderFeed(orderFeed)}
+	if cfg.Auth.Enabled {
+		authenticator, err := newAuthenticator(cfg.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up auth: %w", err)
+		}
+		serverOpts = append(serverOpts, api.WithAuth(authenticator))
+		grpcOpts = append(grpcOpts, grpcapi.WithAuth(authenticator))
+	} else if cfg.Auth.AllowAnonymousAdmin {
+		serverOpts = append(serverOpts, api.WithAnonymousAdmin())
+		grpcOpts = append(grpcOpts, grpcapi.WithAnonymousAdmin())
+		appLogger.Warnw("Auth is disabled and anonymous admins are allowed, every caller can write orders")
+	} else {
+		appLogger.Warnw("Auth is disabled, every caller is an anonymous viewer")
+	}
+
+	if cfg.Redaction.Enabled {
+		redactor, err := newRedactor(cfg.Redaction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up redaction: %w", err)
+		}
+		serverOpts = append(serverOpts, api.WithRedaction(redactor))
+		grpcOpts = append(grpcOpts, grpcapi.WithRedaction(redactor))
+	}
+
+	// the export reads the store directly, the cache only holds the recent orders
+	if cfg.Export.Enabled {
+		serverOpts = append(serverOpts, api.WithExporter(dbStore, cfg.Export))
+	}
+
+	if cfg.Analytics.Enabled {
+		analyticsService := analytics.NewService(store.NewAnalyticsStore(db, appLogger), cfg.Analytics.CacheTTL, cfg.Analytics.CacheMaxEntries)
+		serverOpts = append(serverOpts, api.WithAnalytics(analyticsService))
+	}
+
+	serverOpts = append(serverOpts,
+		api.WithCacheAdmin(cachingService, cfg.Cache.PreloadSize), // admin only, like the writes
+		api.WithConsumerAdmin(kafkaConsumer),
+		api.WithCustomers(dbStore), // the summaries are aggregated by the db, the orders go through the cache
+		api.WithLookup(dbStore),
+	)
+
+	// the dead letters are read back into the db, to be browsed, replayed or discarded
+	var dlqService *dlq.Service
+	var dlqIngester *dlq.Ingester
+	if cfg.DLQ.Enabled {
+		dlqService, err = dlq.NewService(cfg.Kafka, cfg.DLQ, cfg.Consumer.Topic, dbStore, appLogger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dlq service: %w", err)
+		}
+		dlqIngester, err = dlq.NewIngester(cfg.Kafka, cfg.DLQ, cfg.Consumer.DLQ.Topic, dbStore, appLogger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dlq ingester: %w", err)
+		}
+		serverOpts = append(serverOpts, api.WithDLQ(dlqService))
+	}
+
+	server, err := api.NewServer(cachingService, appLogger, cfg.HTTPServer, serverOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create server: %w", err)
 	}
 
-	hc := health.NewDBHealthChecker(db, appLogger, cfg.Health)
-	kafkaConsumer, err := consumer.NewKafkaConsumer(cfg.Kafka, cfg.Consumer, cachingService, appLogger, hc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
+	var grpcServer *grpcapi.Server
+	if cfg.GRPCServer.Enabled {
+		grpcServer = grpcapi.NewServer(cachingService, appLogger, cfg.GRPCServer, grpcOpts...)
 	}
 
 	return &App{
@@ -84,11 +166,58 @@ func New() (*App, error) {
 		logger:   appLogger,
 		db:       db,
 		server:   server,
+		grpc:     grpcServer,
 		consumer: kafkaConsumer,
-		hc:       hc,
+		health:   registry,
+		cache:    cachingService,
+		dlq:      dlqService,
+		ingester: dlqIngester,
 	}, nil
 }
 
+// openDB opens the database pool, the connections are made lazily
+func openDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("pgx", cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxIdlingConnections)
+	db.SetMaxIdleConns(cfg.MaxConnections)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// newAuthenticator maps the auth config onto the authenticator
+func newAuthenticator(cfg config.AuthConfig) (*auth.Authenticator, error) {
+	keys := make([]auth.APIKey, 0, len(cfg.APIKeys))
+	for _, k := range cfg.APIKeys {
+		role, err := auth.ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		keys = append(keys, auth.APIKey{Name: k.Name, Key: k.Key, Role: role})
+	}
+	return auth.New(keys, []byte(cfg.JWTSecret), cfg.JWTIssuer)
+}
+
+// newRedactor maps the redaction config onto the redactor
+func newRedactor(cfg config.RedactionConfig) (*redact.Redactor, error) {
+	if len(cfg.Rules) == 0 {
+		return redact.New(redact.DefaultRules, []byte(cfg.HashKey))
+	}
+
+	rules := make([]redact.Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		role, err := auth.ParseRole(r.Role)
+		if err != nil {
+			return nil, fmt.Errorf("rule for %q: %w", r.Field, err)
+		}
+		rules = append(rules, redact.Rule{Role: role, Field: r.Field, Action: redact.Action(r.Action)})
+	}
+	return redact.New(rules, []byte(cfg.HashKey))
+}
+
 // Run runs the whole logic, the 'command center'
 func (a *App) Run() {
 	defer func() {
@@ -110,6 +239,17 @@ func (a *App) Run() {
 		}
 	}()
 
+	// The gRPC server starts
+	if a.grpc != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.grpc.Start(":" + a.cfg.GRPCServer.Port); err != nil {
+				a.logger.Fatalw("Failed to start the gRPC server", "error", err)
+			}
+		}()
+	}
+
 	// the consumer starts
 	ctx, cancel := context.WithCancel(context.Background())
 	wg.Add(1)
@@ -119,7 +259,25 @@ func (a *App) Run() {
 		a.consumer.Run(ctx)
 		a.logger.Infow("Stopping kafka consumer")
 	}()
-	go a.hc.Start(ctx)
+	a.health.Start(ctx)
+
+	// the dlq ingester starts
+	if a.ingester != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.ingester.Run(ctx)
+		}()
+	}
+
+	// the server is up, but not ready (see /readyz) until the cache is preloaded
+	go func() {
+		preloadCtx, preloadCancel := context.WithTimeout(ctx, 10*time.Second)
+		defer preloadCancel()
+		if err := a.cache.Preload(preloadCtx, a.cfg.Cache.PreloadSize); err != nil {
+			a.logger.Warnw(err.Error())
+		}
+	}()
 
 	// block til signal
 	sigChan := make(chan os.Signal, 1)
@@ -136,7 +294,15 @@ func (a *App) Run() {
 	if err := a.server.Shutdown(shutdownCtx); err != nil {
 		a.logger.Errorw("HTTP server shutdown error: %v", err)
 	}
+	if a.grpc != nil {
+		if err := a.grpc.Shutdown(shutdownCtx); err != nil {
+			a.logger.Errorw("gRPC server shutdown error", "error", err)
+		}
+	}
 
 	wg.Wait()
+	if a.dlq != nil {
+		a.dlq.Close() // no replays once the http server is down
+	}
 	a.logger.Infow("Shutdown complete.")
 }
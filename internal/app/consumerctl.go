@@ -0,0 +1,158 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const consumerCtlUsage = `usage: service consumer [flags] <command>
+
+commands:
+  status                        the workers, what's paused and the lag of every partition
+  pause [partition...]          pause the partitions, all of them if none are given
+  resume [partition...]         resume the partitions, all of them if none are given
+  seek <partition> <offset>     move a partition to an offset, or to the first message
+  seek <partition> <RFC 3339>   at or after a time
+  workers <n>                   set the worker count
+
+flags:`
+
+// ConsumerCtl is the 'consumer' subcommand: it controls the kafka consumer of a running
+// service through the admin API, /api/v1/admin/consumer, and prints what it answers.
+func ConsumerCtl(args []string) error {
+	fs := flag.NewFlagSet("consumer", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), consumerCtlUsage)
+		fs.PrintDefaults()
+	}
+	var (
+		addr    = fs.String("addr", "http://localhost:8080", "Base URL of the service.")
+		apiKey  = fs.String("api-key", os.Getenv("ADMIN_API_KEY"), "API key of an admin, $ADMIN_API_KEY by default. The consumer admin is off without auth.")
+		timeout = fs.Duration("timeout", 10*time.Second, "Timeout of the request.")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no command given")
+	}
+
+	method, path, body, err := consumerCtlRequest(fs.Arg(0), fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(*addr, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if *apiKey != "" {
+		req.Header.Set("X-API-Key", *apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return errors.New(resp.Status)
+	}
+
+	if len(respBody) == 0 {
+		fmt.Println("ok")
+		return nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, respBody, "", "  "); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+	fmt.Println(out.String())
+	return nil
+}
+
+// consumerCtlRequest turns a command and its arguments into the admin API request.
+func consumerCtlRequest(cmd string, args []string) (method, path string, body []byte, err error) {
+	const base = "/api/v1/admin/consumer"
+
+	switch cmd {
+	case "status":
+		if len(args) != 0 {
+			return "", "", nil, errors.New("usage: status")
+		}
+		return http.MethodGet, base, nil, nil
+
+	case "pause", "resume":
+		partitions := make([]int32, 0, len(args))
+		for _, arg := range args {
+			p, err := strconv.ParseInt(arg, 10, 32)
+			if err != nil {
+				return "", "", nil, fmt.Errorf("%s: %q is not a partition", cmd, arg)
+			}
+			partitions = append(partitions, int32(p))
+		}
+		body, err = json.Marshal(map[string][]int32{"partitions": partitions})
+		return http.MethodPost, base + ":" + cmd, body, err
+
+	case "seek":
+		if len(args) != 2 {
+			return "", "", nil, errors.New("usage: seek <partition> <offset|RFC 3339 time>")
+		}
+		p, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("seek: %q is not a partition", args[0])
+		}
+		req := map[string]any{"partition": p}
+		if offset, err := strconv.ParseInt(args[1], 10, 64); err == nil {
+			req["offset"] = offset
+		} else if t, err := time.Parse(time.RFC3339, args[1]); err == nil {
+			req["timestamp"] = t
+		} else {
+			return "", "", nil, fmt.Errorf("seek: %q is neither an offset nor an RFC 3339 time", args[1])
+		}
+		body, err = json.Marshal(req)
+		return http.MethodPost, base + ":seek", body, err
+
+	case "workers":
+		if len(args) != 1 {
+			return "", "", nil, errors.New("usage: workers <n>")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "", "", nil, fmt.Errorf("workers: %q is not a number", args[0])
+		}
+		body, err = json.Marshal(map[string]int{"workers": n})
+		return http.MethodPost, base + ":resize", body, err
+
+	default:
+		return "", "", nil, fmt.Errorf("unknown command %q", cmd)
+	}
+}
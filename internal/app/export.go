@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/export"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// Export is the 'export' subcommand: it writes the orders matching the flags to a file
+// (or stdout), the same export GET /api/v1/exports serves, without going through the server.
+// It only needs the database, the config is loaded the same way the service loads it.
+func Export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	var (
+		format          = fs.String("format", string(export.FormatCSV), "Export format: csv, csv_items, ndjson or parquet.")
+		out             = fs.String("o", "-", "Output file, '-' is stdout.")
+		role            = fs.String("role", auth.RoleViewer.String(), "Role whose redaction applies to the orders: viewer, support or admin (unredacted).")
+		customerID      = fs.String("customer-id", "", "Only the orders of this customer.")
+		deliveryService = fs.String("delivery-service", "", "Only the orders of this delivery service.")
+		locale          = fs.String("locale", "", "Only the orders with this locale.")
+		currency        = fs.String("currency", "", "Only the orders paid in this currency.")
+		provider        = fs.String("provider", "", "Only the orders paid through this provider.")
+		from            = fs.String("from", "", "Only the orders created on or after this date (YYYY-MM-DD).")
+		to              = fs.String("to", "", "Only the orders created on or before this date (YYYY-MM-DD).")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	exportFormat, err := export.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	filter := store.OrderFilter{
+		CustomerID:      *customerID,
+		DeliveryService: *deliveryService,
+		Locale:          *locale,
+		Currency:        *currency,
+		Provider:        *provider,
+	}
+	if *from != "" {
+		if filter.CreatedFrom, err = time.Parse(time.DateOnly, *from); err != nil {
+			return errors.New("-from: expected a YYYY-MM-DD date")
+		}
+	}
+	if *to != "" {
+		if filter.CreatedTo, err = time.Parse(time.DateOnly, *to); err != nil {
+			return errors.New("-to: expected a YYYY-MM-DD date")
+		}
+		filter.CreatedTo = filter.CreatedTo.AddDate(0, 0, 1) // the whole day
+	}
+	exportRole, err := auth.ParseRole(*role)
+	if err != nil {
+		return err
+	}
+
+	// the logs go to stderr, stdout may well be the export
+	exportLogger, err := logger.NewSugarLogger()
+	if err != nil {
+		return fmt.Errorf("failed to create a logger: %w", err)
+	}
+	defer func() {
+		if err := exportLogger.Sync(); err != nil {
+			log.Printf("failed to sync logger: %v\n", err)
+		}
+	}()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	opts := export.Options{ParquetRowGroupSize: cfg.Export.ParquetRowGroupSize}
+	if cfg.Redaction.Enabled {
+		redactor, err := newRedactor(cfg.Redaction)
+		if err != nil {
+			return fmt.Errorf("failed to set up redaction: %w", err)
+		}
+		opts.Transform = func(o *domain.Order) *domain.Order { return redactor.Order(exportRole, o) }
+	}
+
+	db, err := openDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		w    io.Writer = os.Stdout
+		file *os.File
+	)
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			return fmt.Errorf("failed to create the output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	n, err := export.Run(ctx, store.NewDBStore(db, exportLogger), filter, exportFormat, w, opts)
+	if err == nil && file != nil {
+		err = file.Close() // the last of it may only be written now
+	}
+	if err != nil {
+		if file != nil {
+			os.Remove(*out) // an incomplete export is of no use
+		}
+		if errors.Is(err, context.Canceled) {
+			return errors.New("export interrupted")
+		}
+		return fmt.Errorf("export failed after %d orders: %w", n, err)
+	}
+
+	exportLogger.Infow("Order export complete", "format", exportFormat, "exported", n, "output", *out, "took", time.Since(start).String())
+	return nil
+}
@@ -11,11 +11,17 @@ import (
 // Config holds all configuration for the application, loaded with Viper.
 type Config struct {
 	HTTPServer HTTPServerConfig `mapstructure:"http_server"`
+	GRPCServer GRPCServerConfig `mapstructure:"grpc_server"`
 	Database   DatabaseConfig   `mapstructure:"database"`
 	Kafka      KafkaConfig      `mapstructure:"kafka"`
 	Health     HealthConfig     `mapstructure:"health"`
 	Consumer   ConsumerConfig   `mapstructure:"consumer"`
 	Cache      CacheConfig      `mapstructure:"cache"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Redaction  RedactionConfig  `mapstructure:"redaction"`
+	Export     ExportConfig     `mapstructure:"export"`
+	DLQ        DLQConfig        `mapstructure:"dlq"`
+	Analytics  AnalyticsConfig  `mapstructure:"analytics"`
 }
 
 // HTTPServerConfig holds HTTP server-specific settings (port)
@@ -25,6 +31,54 @@ type HTTPServerConfig struct {
 	WriteTimeout    time.Duration `mapstructure:"write_timeout_s"`
 	IdleTimeout     time.Duration `mapstructure:"idle_timeout_s"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	MaxBodyBytes    int64         `mapstructure:"max_body_bytes"`    // cap of a POSTed order
+	IdempotencyTTL  time.Duration `mapstructure:"idempotency_ttl"`   // how long 'Idempotency-Key' responses are kept
+	ImportBatchSize int           `mapstructure:"import_batch_size"` // lines per batch (and transaction) of a bulk import
+
+	IdempotencyMaxKeys int `mapstructure:"idempotency_max_keys"` // the 'Idempotency-Key's remembered at once
+
+	OrderCacheControl string `mapstructure:"order_cache_control"` // 'Cache-Control' of the order responses, none if empty
+
+	Compression CompressionConfig `mapstructure:"compression"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	AccessLog   AccessLogConfig   `mapstructure:"access_log"`
+	Stream      StreamConfig      `mapstructure:"stream"`
+}
+
+// GRPCServerConfig holds the gRPC server settings, it shuts down along with the HTTP server
+type GRPCServerConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Port       string `mapstructure:"port"`
+	Reflection bool   `mapstructure:"reflection"` // lets tools like grpcurl discover the services
+}
+
+// StreamConfig holds the settings of the live order stream (SSE)
+type StreamConfig struct {
+	RingSize         int           `mapstructure:"ring_size"`         // events kept for the clients resuming with Last-Event-ID
+	SubscriberBuffer int           `mapstructure:"subscriber_buffer"` // events a client can lag behind before it's dropped
+	Heartbeat        time.Duration `mapstructure:"heartbeat"`         // keeps idle connections open through proxies
+}
+
+// AccessLogConfig holds the access log settings. Errors (4xx, 5xx) and slow
+// requests are always logged, the rest is sampled.
+type AccessLogConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	SampleRate    float64       `mapstructure:"sample_rate"`    // 0..1, the share of the other requests logged
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"` // 0 means no request is slow
+}
+
+// CompressionConfig holds the response compression settings
+type CompressionConfig struct {
+	Enabled      bool `mapstructure:"enabled"`
+	MinSizeBytes int  `mapstructure:"min_size_bytes"` // shorter responses aren't worth compressing
+}
+
+// RateLimitConfig holds the limits of the HTTP server, a zero turns the limit off
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"` // per client, by the authenticated caller or IP
+	Burst             int     `mapstructure:"burst"`               // defaults to a second worth of requests
+	MaxConcurrent     int     `mapstructure:"max_concurrent"`      // requests in flight, over it they're shed with a 503
+	TrustForwardedFor bool    `mapstructure:"trust_forwarded_for"` // take the client IP from the last X-Forwarded-For entry, only behind a proxy
 }
 
 // KafkaConfig holds Kafka-specific settings
@@ -67,10 +121,15 @@ func (c DatabaseConfig) DSN() string {
 	)
 }
 
-// HealthConfig holds health check settings.
+// HealthConfig holds health check settings, an interval and a timeout per checked component.
 type HealthConfig struct {
-	DBCheckInterval time.Duration `mapstructure:"db_hp_interval"`
-	DBCheckTimeout  time.Duration `mapstructure:"db_hp_timeout"`
+	DBCheckInterval    time.Duration `mapstructure:"db_hp_interval"`
+	DBCheckTimeout     time.Duration `mapstructure:"db_hp_timeout"`
+	KafkaCheckInterval time.Duration `mapstructure:"kafka_interval"`
+	KafkaCheckTimeout  time.Duration `mapstructure:"kafka_timeout"`
+	DLQCheckInterval   time.Duration `mapstructure:"dlq_interval"`
+	DLQCheckTimeout    time.Duration `mapstructure:"dlq_timeout"`
+	CacheCheckInterval time.Duration `mapstructure:"cache_interval"`
 }
 
 // ConsumerConfig holds consumer-specific settings.
@@ -102,6 +161,60 @@ type DLQPublisherConfig struct {
 	EnableIdempotence bool   `mapstructure:"idempotence"`
 }
 
+// AuthConfig holds the credentials the HTTP server accepts
+type AuthConfig struct {
+	Enabled   bool           `mapstructure:"enabled"`
+	APIKeys   []APIKeyConfig `mapstructure:"api_keys"`
+	JWTSecret string         `mapstructure:"jwt_secret"` // HS256, at least 32 bytes; tokens aren't accepted if empty
+	JWTIssuer string         `mapstructure:"jwt_issuer"` // the expected 'iss' claim, any if empty
+
+	AllowAnonymousAdmin bool `mapstructure:"allow_anonymous_admin"` // while auth is off the callers are admins, not viewers
+}
+
+// APIKeyConfig is a static api key, the name identifies the caller in the logs
+type APIKeyConfig struct {
+	Name string `mapstructure:"name"`
+	Key  string `mapstructure:"key"`
+	Role string `mapstructure:"role"` // viewer, support or admin
+}
+
+// RedactionConfig holds the policies hiding personal data from the roles below admin
+type RedactionConfig struct {
+	Enabled bool                  `mapstructure:"enabled"`
+	HashKey string                `mapstructure:"hash_key"` // keys the 'hash' action, at least 32 bytes; keep it secret
+	Rules   []RedactionRuleConfig `mapstructure:"rules"`    // the built-in defaults if empty
+}
+
+// RedactionRuleConfig is the action taken on a single field of the orders a role sees
+type RedactionRuleConfig struct {
+	Role   string `mapstructure:"role"`   // viewer, support or admin
+	Field  string `mapstructure:"field"`  // a json path, like "delivery.phone"
+	Action string `mapstructure:"action"` // mask, hash or drop
+}
+
+// ExportConfig holds the settings of the order exports, both /api/v1/exports and the CLI
+type ExportConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`                // of /api/v1/exports, the CLI works regardless
+	ParquetRowGroupSize int           `mapstructure:"parquet_row_group_size"` // orders buffered per Parquet row group
+	WriteTimeout        time.Duration `mapstructure:"write_timeout"`          // the write deadline is pushed on by this much with every write
+	MaxConcurrent       int           `mapstructure:"max_concurrent"`         // exports at once, each holds a transaction and a db connection
+}
+
+// DLQConfig holds the settings of the dlq browser: the ingester reading the dlq topic
+// (consumer.dlq.topic) back into the database, and the replays to consumer.topic
+type DLQConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	GroupID       string        `mapstructure:"group_id"`       // of the ingester, apart from the order consumer's
+	ReplayTimeout time.Duration `mapstructure:"replay_timeout"` // for the delivery of a replayed message
+}
+
+// AnalyticsConfig holds the settings of /api/v1/analytics/
+type AnalyticsConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	CacheTTL        time.Duration `mapstructure:"cache_ttl"`         // of a computed report, not cached if 0
+	CacheMaxEntries int           `mapstructure:"cache_max_entries"` // reports cached at once, one per filter asked for
+}
+
 // LoadConfig reads configuration from file and environment variables:
 //   - first it loads defaults
 //   - reads a .yaml file if there's one, overwrites the above
@@ -114,6 +227,28 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("http_server.write_timeout", "10s")
 	viper.SetDefault("http_server.idle_timeout", "120s")
 	viper.SetDefault("http_server.shutdown_timeout", "30s")
+	viper.SetDefault("http_server.max_body_bytes", 1_048_576) // <-- 1Mb
+	viper.SetDefault("http_server.idempotency_ttl", "24h")
+	viper.SetDefault("http_server.idempotency_max_keys", 100_000)
+	viper.SetDefault("http_server.import_batch_size", 500)
+	viper.SetDefault("http_server.order_cache_control", "private, max-age=300") // personal data, browsers only
+	viper.SetDefault("http_server.compression.enabled", true)
+	viper.SetDefault("http_server.compression.min_size_bytes", 1024)
+	viper.SetDefault("http_server.rate_limit.requests_per_second", 20)
+	viper.SetDefault("http_server.rate_limit.burst", 40)
+	viper.SetDefault("http_server.rate_limit.max_concurrent", 256)
+	viper.SetDefault("http_server.rate_limit.trust_forwarded_for", false)
+	viper.SetDefault("http_server.access_log.enabled", true)
+	viper.SetDefault("http_server.access_log.sample_rate", 0.05)
+	viper.SetDefault("http_server.access_log.slow_threshold", "500ms")
+	viper.SetDefault("http_server.stream.ring_size", 256)
+	viper.SetDefault("http_server.stream.subscriber_buffer", 64)
+	viper.SetDefault("http_server.stream.heartbeat", "15s")
+
+	// grpc server
+	viper.SetDefault("grpc_server.enabled", true)
+	viper.SetDefault("grpc_server.port", "50051")
+	viper.SetDefault("grpc_server.reflection", true)
 
 	// db
 	viper.SetDefault("database.host", "localhost")
@@ -159,12 +294,43 @@ func LoadConfig() (*Config, error) {
 	// health
 	viper.SetDefault("health.db_hp_interval", "5s")
 	viper.SetDefault("health.db_hp_timeout", "180s")
+	viper.SetDefault("health.kafka_interval", "10s")
+	viper.SetDefault("health.kafka_timeout", "5s")
+	viper.SetDefault("health.dlq_interval", "30s")
+	viper.SetDefault("health.dlq_timeout", "5s")
+	viper.SetDefault("health.cache_interval", "1s")
 
 	// cache
 	viper.SetDefault("cache.entry_size_cap", 1_048_576) // <-- 1Mb
 	viper.SetDefault("cache.entry_amount_cap", 500)
 	viper.SetDefault("cache.preload_size", 250)
 
+	// auth (off by default: with no keys configured nobody could get in)
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.jwt_secret", "")
+	viper.SetDefault("auth.jwt_issuer", "")
+	viper.SetDefault("auth.allow_anonymous_admin", false)
+
+	// redaction
+	viper.SetDefault("redaction.enabled", true)
+	viper.SetDefault("redaction.hash_key", "")
+
+	// export
+	viper.SetDefault("export.enabled", true)
+	viper.SetDefault("export.parquet_row_group_size", 10_000)
+	viper.SetDefault("export.write_timeout", "30s")
+	viper.SetDefault("export.max_concurrent", 2)
+
+	// dlq browser
+	viper.SetDefault("dlq.enabled", true)
+	viper.SetDefault("dlq.group_id", "orders-dlq-browser")
+	viper.SetDefault("dlq.replay_timeout", "10s")
+
+	// analytics
+	viper.SetDefault("analytics.enabled", true)
+	viper.SetDefault("analytics.cache_ttl", "30s")
+	viper.SetDefault("analytics.cache_max_entries", 1000)
+
 	// Configure Viper
 	viper.SetConfigName("config")    // name of config file (without extension)
 	viper.SetConfigType("yaml")      // REQUIRED if the config file does not have the extension in the name
@@ -2,8 +2,9 @@ package consumer
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goinginblind/l0-task/internal/config"
@@ -16,16 +17,38 @@ import (
 
 // KafkaConsumer consumes messages from Kafka and processes them.
 type KafkaConsumer struct {
-	consumer      kafkaConsumer
-	service       service.OrderService
-	logger        logger.Logger
-	healthChecker *health.DBHealthChecker
-	workerCount   int
-	jobBuffer     int
-	maxRetries    int           // passed to workers
-	retryBackoff  time.Duration // passed to workers
-	dlqTopic      string        // passed to workers
-	dlqPublisher  DLQManager    // passed to workers
+	consumer     kafkaConsumer
+	service      service.OrderService
+	logger       logger.Logger
+	dbHealth     DBHealth
+	workerCount  int
+	jobBuffer    int
+	maxRetries   int            // passed to workers
+	retryBackoff time.Duration  // passed to workers
+	dlqTopic     string         // passed to workers
+	dlqPublisher DLQManager     // passed to workers
+	publisher    OrderPublisher // passed to workers
+	topic        string
+	paused       atomic.Bool // mirrors healthPaused for the health check
+
+	// the admin commands (see control.go) are run by the poll loop,
+	// the state below is only ever touched from there
+	commands     chan func()
+	running      atomic.Bool
+	stopped      chan struct{} // closed once the poll loop exits
+	healthPaused bool          // all the partitions, while the db is unhealthy
+	pausedAll    bool          // by an admin, including the partitions assigned later
+	pausedParts  map[int32]bool
+	rewinds      map[int32]kafka.Offset // where the paused partitions were sought back to
+	pool         *workerPool
+}
+
+// DBHealth is the database health as seen by the consumer: the poll loop pauses
+// the partitions while it's unhealthy and the workers report connection failures to it.
+// The health.Component of the database implements it.
+type DBHealth interface {
+	IsHealthy() bool
+	UnhealthyMarker // from workers.go
 }
 
 // kafkaConsumer is a composite interface that includes all the consumer functionalities.
@@ -33,6 +56,8 @@ type KafkaConsumer struct {
 type kafkaConsumer interface {
 	ConsumerController
 	LagQuerier
+	MetadataQuerier
+	Seeker
 	Committer // from workers.go
 }
 
@@ -53,17 +78,29 @@ type LagQuerier interface {
 	Assignment() (partitions []kafka.TopicPartition, err error)
 }
 
+// Seeker defines the methods used to move the position of a partition
+type Seeker interface {
+	Seek(partition kafka.TopicPartition, timeoutMs int) error
+	OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) (offsets []kafka.TopicPartition, err error)
+}
+
+// MetadataQuerier defines the method used to check if the brokers are reachable
+type MetadataQuerier interface {
+	GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error)
+}
+
 // DLQManager is an interface which the concrete kafka.Producer implements.
 // Again, made to ease testing
 type DLQManager interface {
 	Events() chan kafka.Event
 	Close()
 	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	MetadataQuerier
 }
 
 // NewKafkaConsumer creates a new KafkaConsumer.
 func NewKafkaConsumer(kafCfg config.KafkaConfig, consCfg config.ConsumerConfig,
-	service service.OrderService, log logger.Logger, hc *health.DBHealthChecker) (*KafkaConsumer, error) {
+	service service.OrderService, log logger.Logger, dbHealth DBHealth, publisher OrderPublisher) (*KafkaConsumer, error) {
 	consumerConfig := &kafka.ConfigMap{
 		"bootstrap.servers":     kafCfg.BootstrapServers,
 		"group.id":              kafCfg.ConsumerGroupID,
@@ -100,20 +137,26 @@ func NewKafkaConsumer(kafCfg config.KafkaConfig, consCfg config.ConsumerConfig,
 	}
 
 	return &KafkaConsumer{
-		consumer:      consumer,
-		service:       service,
-		logger:        log,
-		healthChecker: hc,
-		workerCount:   consCfg.WorkerCount,
-		jobBuffer:     consCfg.JobBufferSize,
-		maxRetries:    consCfg.MaxRetries,
-		retryBackoff:  consCfg.RetryBackoff,
-		dlqTopic:      consCfg.DLQ.Topic,
-		dlqPublisher:  dlqPublisher,
+		consumer:     consumer,
+		service:      service,
+		logger:       log,
+		dbHealth:     dbHealth,
+		workerCount:  consCfg.WorkerCount,
+		jobBuffer:    consCfg.JobBufferSize,
+		maxRetries:   consCfg.MaxRetries,
+		retryBackoff: consCfg.RetryBackoff,
+		dlqTopic:     consCfg.DLQ.Topic,
+		dlqPublisher: dlqPublisher,
+		publisher:    publisher,
+		topic:        consCfg.Topic,
+		commands:     make(chan func()),
+		stopped:      make(chan struct{}),
+		pausedParts:  make(map[int32]bool),
+		rewinds:      make(map[int32]kafka.Offset),
 	}, nil
 }
 
-// Run starts the consumer loop
+// Run starts the consumer loop, it returns once ctx is done and the workers are finished.
 func (kc *KafkaConsumer) Run(ctx context.Context) {
 	// this goroutine is a background one, it makes sure the dlq send does not block and
 	// that we can still use the fire-and-forget approach for the sendToDLQ function.
@@ -121,75 +164,103 @@ func (kc *KafkaConsumer) Run(ctx context.Context) {
 	go kc.monitorConsumerLag(ctx)
 
 	jobs := make(chan *kafka.Message, kc.jobBuffer)
-	var wg sync.WaitGroup
 
 	// workers
-	wDeps := workerDependencies{
-		service:       kc.service,
-		logger:        kc.logger,
-		consumer:      kc.consumer,
-		ctx:           ctx,
-		healthChecker: kc.healthChecker,
-		dlqTopic:      kc.dlqTopic,
-		dlqPublisher:  kc.dlqPublisher,
+	kc.pool = &workerPool{
+		jobs: jobs,
+		deps: workerDependencies{
+			service:       kc.service,
+			logger:        kc.logger,
+			consumer:      kc.consumer,
+			ctx:           ctx,
+			healthChecker: kc.dbHealth,
+			dlqTopic:      kc.dlqTopic,
+			dlqPublisher:  kc.dlqPublisher,
+			publisher:     kc.publisher,
+		},
+		maxRetries:   kc.maxRetries,
+		retryBackoff: kc.retryBackoff,
 	}
+	kc.pool.resize(kc.workerCount)
 
-	for i := 0; i < kc.workerCount; i++ {
-		wg.Add(1)
-		w := &worker{
-			id:           i,
-			jobs:         jobs,
-			deps:         wDeps,
-			maxRetries:   kc.maxRetries,
-			retryBackoff: kc.retryBackoff,
-		}
-		go w.run(&wg)
-	}
+	// the poll loop: handles shutdown, db long disconnects, the admin commands
+	// and sends messages to workers
+	kc.running.Store(true)
+	kc.poll(ctx, jobs)
+	kc.running.Store(false)
+	close(kc.stopped)
+	close(jobs)
 
-	// the poll goroutine: handles shutdown, db long disconnects and sends messages to workers
-	go func() {
-		defer close(jobs)
-		isPaused := false // Local state to track if we've already paused.
-
-		for {
-			select {
-			case <-ctx.Done():
-				kc.logger.Infow("Shutting down the consumer...")
-				return
-			default:
-				kc.manageConsumerState(kc.consumer, &isPaused)
-
-				ev := kc.consumer.Poll(100) // this poll ensures the consumer doesn't disconnect from kafka
-				if ev == nil {
-					time.Sleep(50 * time.Millisecond) // this is to not burn the CPU to ashes when nothing happens
+	kc.pool.wait()
+	kc.dlqPublisher.Close()
+	kc.consumer.Close()
+}
+
+// poll is the poll loop, it runs until ctx is done.
+func (kc *KafkaConsumer) poll(ctx context.Context, jobs chan<- *kafka.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			kc.logger.Infow("Shutting down the consumer...")
+			return
+		case cmd := <-kc.commands:
+			cmd()
+		default:
+			kc.manageConsumerState(kc.consumer, &kc.healthPaused)
+
+			ev := kc.consumer.Poll(100) // this poll ensures the consumer doesn't disconnect from kafka
+			if ev == nil {
+				time.Sleep(50 * time.Millisecond) // this is to not burn the CPU to ashes when nothing happens
+				continue
+			}
+
+			switch e := ev.(type) {
+			case *kafka.Message:
+				if kc.isHeld(e.TopicPartition.Partition) {
+					kc.rewind(e)
 					continue
 				}
-
-				switch e := ev.(type) {
-				case *kafka.Message:
-					if !isPaused {
-						jobs <- e
-					}
-				case kafka.AssignedPartitions:
-					kc.logger.Infow("Partitions assigned", "partitions", e.Partitions)
-				case kafka.RevokedPartitions:
-					kc.logger.Infow("Partitions revoked", "partitions", e.Partitions)
-				case kafka.Error:
-					kc.logger.Errorw("Kafka error", "error", e, "is_fatal", e.IsFatal())
+				if !kc.healthPaused && !kc.dispatch(ctx, jobs, e) {
+					kc.logger.Infow("Shutting down the consumer...")
+					return
 				}
+			case kafka.AssignedPartitions:
+				kc.logger.Infow("Partitions assigned", "partitions", e.Partitions)
+			case kafka.RevokedPartitions:
+				kc.logger.Infow("Partitions revoked", "partitions", e.Partitions)
+			case kafka.Error:
+				kc.logger.Errorw("Kafka error", "error", e, "is_fatal", e.IsFatal())
 			}
 		}
-	}()
+	}
+}
 
-	wg.Wait()
-	kc.dlqPublisher.Close()
-	kc.consumer.Close()
+// dispatch hands the message to the workers. While they're all busy (the db is slow, say)
+// it keeps running the admin commands, a pause or more workers is what's needed just then;
+// should the message's partition get paused meanwhile, it's rewound instead. It's false
+// if ctx is done first.
+func (kc *KafkaConsumer) dispatch(ctx context.Context, jobs chan<- *kafka.Message, msg *kafka.Message) bool {
+	for {
+		select {
+		case jobs <- msg:
+			return true
+		case cmd := <-kc.commands:
+			cmd()
+			if kc.isHeld(msg.TopicPartition.Partition) {
+				kc.rewind(msg)
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
 }
 
-// manageConsumerState pauses or resumes the consumer based on DB health.
+// manageConsumerState pauses or resumes the consumer based on DB health (as reported by the health registry).
+// The partitions paused by an admin stay paused either way.
 func (kc *KafkaConsumer) manageConsumerState(consumer ConsumerController, isPaused *bool) {
 	// db is NOT healthy and consumer is NOT paused: log, pause
-	if !kc.healthChecker.IsHealthy() && !*isPaused {
+	if !kc.dbHealth.IsHealthy() && !*isPaused {
 		time.Sleep(100 * time.Millisecond) // avoid hot spins when the db is down
 		assignedPartitions, err := consumer.Assignment()
 		if err == nil && len(assignedPartitions) > 0 {
@@ -198,18 +269,54 @@ func (kc *KafkaConsumer) manageConsumerState(consumer ConsumerController, isPaus
 				kc.logger.Errorw("Failed to pause consumer", "error", err)
 			} else {
 				*isPaused = true
+				kc.paused.Store(true)
 			}
 		}
 		// db IS healthy and consumer IS paused: log, unpause
-	} else if kc.healthChecker.IsHealthy() && *isPaused {
+	} else if kc.dbHealth.IsHealthy() && *isPaused {
 		assignedPartitions, err := consumer.Assignment()
 		if err == nil && len(assignedPartitions) > 0 {
-			kc.logger.Infow("DB is healthy again. Resuming consumption on partitions.", "partitions", assignedPartitions)
-			if err := consumer.Resume(assignedPartitions); err != nil {
+			resumed := kc.unheld(assignedPartitions)
+			kc.logger.Infow("DB is healthy again. Resuming consumption on partitions.", "partitions", resumed)
+			if err := consumer.Resume(resumed); err != nil {
 				kc.logger.Errorw("Failed to resume consumer", "error", err)
 			} else {
 				*isPaused = false
+				kc.paused.Store(false)
 			}
 		}
 	}
 }
+
+// ConsumerChecker returns the health check of the consumer: the brokers have to be
+// reachable and the partitions must not be paused.
+func (kc *KafkaConsumer) ConsumerChecker() health.Checker {
+	return health.NewChecker("kafka_consumer", func(ctx context.Context) error {
+		if _, err := kc.consumer.GetMetadata(&kc.topic, false, timeoutMs(ctx)); err != nil {
+			return fmt.Errorf("brokers unreachable: %w", err)
+		}
+		if kc.paused.Load() {
+			return errors.New("partitions are paused, the db is unhealthy")
+		}
+		return nil
+	})
+}
+
+// DLQChecker returns the health check of the DLQ producer: its brokers have to be reachable.
+func (kc *KafkaConsumer) DLQChecker() health.Checker {
+	return health.NewChecker("dlq_producer", func(ctx context.Context) error {
+		if _, err := kc.dlqPublisher.GetMetadata(&kc.dlqTopic, false, timeoutMs(ctx)); err != nil {
+			return fmt.Errorf("brokers unreachable: %w", err)
+		}
+		return nil
+	})
+}
+
+// timeoutMs converts the deadline of ctx into the timeout the kafka client expects.
+func timeoutMs(ctx context.Context) int {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 5000
+	}
+	return max(int(time.Until(deadline).Milliseconds()), 1)
+}
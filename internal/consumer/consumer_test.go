@@ -3,14 +3,19 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/goinginblind/l0-task/internal/dlq"
 	"github.com/goinginblind/l0-task/internal/domain"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
 	"github.com/goinginblind/l0-task/internal/store"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockOrderService simulates the service layer.
@@ -23,6 +28,14 @@ func (m *MockOrderService) ProcessNewOrder(ctx context.Context, order *domain.Or
 	return args.Error(0)
 }
 
+func (m *MockOrderService) ProcessNewOrders(ctx context.Context, orders []*domain.Order) []error {
+	args := m.Called(ctx, orders)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).([]error)
+}
+
 func (m *MockOrderService) GetOrder(ctx context.Context, uid string) (*domain.Order, error) {
 	args := m.Called(ctx, uid)
 	if args.Get(0) == nil {
@@ -31,6 +44,22 @@ func (m *MockOrderService) GetOrder(ctx context.Context, uid string) (*domain.Or
 	return args.Get(0).(*domain.Order), args.Error(1)
 }
 
+func (m *MockOrderService) GetOrders(ctx context.Context, uids []string) ([]*domain.Order, error) {
+	args := m.Called(ctx, uids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Order), args.Error(1)
+}
+
+func (m *MockOrderService) ListOrders(ctx context.Context, q store.ListQuery) (*store.OrderPage, error) {
+	args := m.Called(ctx, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.OrderPage), args.Error(1)
+}
+
 type MockCommitter struct {
 	mock.Mock
 }
@@ -57,6 +86,15 @@ func (m *MockUnhealthyMarker) MarkUnhealthy() {
 	m.Called()
 }
 
+type MockDBHealth struct {
+	MockUnhealthyMarker
+}
+
+func (m *MockDBHealth) IsHealthy() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
 func TestWorker_ProcessMessage(t *testing.T) {
 	// standard valid order and Kafka message to reuse
 	validOrder := domain.Order{OrderUID: "test-uid"}
@@ -189,3 +227,282 @@ func TestWorker_ProcessMessage(t *testing.T) {
 		})
 	}
 }
+
+func Test_correlationID(t *testing.T) {
+	topic := "orders"
+	position := kafka.TopicPartition{Topic: &topic, Partition: 2, Offset: 42}
+
+	assert.Equal(t, "orders-2-42", correlationID(&kafka.Message{TopicPartition: position}))
+	assert.Equal(t, "req-1", correlationID(&kafka.Message{
+		TopicPartition: position,
+		Headers:        []kafka.Header{{Key: "x-request-id", Value: []byte("req-1")}},
+	}))
+}
+
+func TestWorker_ProcessMessage_correlation(t *testing.T) {
+	topic := "orders"
+	orderJSON, _ := json.Marshal(domain.Order{OrderUID: "test-uid"})
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: 7},
+		Key:            []byte("test-uid"),
+		Value:          orderJSON,
+	}
+
+	mockService := new(MockOrderService)
+	mockService.On("ProcessNewOrder", mock.MatchedBy(func(ctx context.Context) bool {
+		return logger.CorrelationID(ctx) == "orders-0-7"
+	}), mock.Anything).Return(domain.ErrInvalidOrder).Once()
+	mockCommitter := new(MockCommitter)
+	mockCommitter.On("CommitMessage", mock.Anything).Return(nil, nil)
+	mockDLQProducer := new(MockDLQProducer)
+	mockDLQProducer.On("Produce", mock.Anything, mock.Anything).Return(nil)
+
+	w := &worker{
+		id: 1,
+		deps: workerDependencies{
+			service:      mockService,
+			logger:       logger.NewMockLogger(),
+			consumer:     mockCommitter,
+			ctx:          context.Background(),
+			dlqTopic:     "test-dlq",
+			dlqPublisher: mockDLQProducer,
+		},
+		maxRetries: 1,
+	}
+	w.processMessage(msg)
+
+	mockService.AssertExpectations(t)
+
+	// the id goes along with the message into the DLQ, the consumed message is left as it was
+	dlqMsg := mockDLQProducer.Calls[0].Arguments.Get(0).(*kafka.Message)
+	assert.Equal(t, "orders-0-7", headerValue(dlqMsg.Headers, correlationHeaders...))
+	assert.Empty(t, msg.Headers)
+
+	// so does its position, for the dlq browser
+	assert.Equal(t, "orders", headerValue(dlqMsg.Headers, dlq.HeaderSourceTopic))
+	assert.Equal(t, "0", headerValue(dlqMsg.Headers, dlq.HeaderSourcePartition))
+	assert.Equal(t, "7", headerValue(dlqMsg.Headers, dlq.HeaderSourceOffset))
+	assert.NotEmpty(t, headerValue(dlqMsg.Headers, dlq.HeaderReason))
+}
+
+// fakeKafka is a kafkaConsumer assigned the partitions 0 to 2 of "orders",
+// it records what's paused and where the partitions were sought to.
+type fakeKafka struct {
+	kafkaConsumer
+	mu     sync.Mutex
+	paused map[int32]bool
+	seeks  map[int32]kafka.Offset
+}
+
+func newFakeKafka() *fakeKafka {
+	return &fakeKafka{paused: map[int32]bool{}, seeks: map[int32]kafka.Offset{}}
+}
+
+func (f *fakeKafka) Assignment() ([]kafka.TopicPartition, error) {
+	topic := "orders"
+	return []kafka.TopicPartition{{Topic: &topic, Partition: 0}, {Topic: &topic, Partition: 1}, {Topic: &topic, Partition: 2}}, nil
+}
+
+func (f *fakeKafka) Pause(tps []kafka.TopicPartition) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tp := range tps {
+		f.paused[tp.Partition] = true
+	}
+	return nil
+}
+
+func (f *fakeKafka) Resume(tps []kafka.TopicPartition) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tp := range tps {
+		delete(f.paused, tp.Partition)
+	}
+	return nil
+}
+
+func (f *fakeKafka) Seek(tp kafka.TopicPartition, timeoutMs int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seeks[tp.Partition] = tp.Offset
+	return nil
+}
+
+func (f *fakeKafka) OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	offsets := slices.Clone(times)
+	offsets[0].Offset = 42
+	return offsets, nil
+}
+
+func (f *fakeKafka) Committed(tps []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	committed := slices.Clone(tps)
+	for i := range committed {
+		committed[i].Offset = 10
+	}
+	return committed, nil
+}
+
+func (f *fakeKafka) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error) {
+	return 0, 15, nil
+}
+
+// newControlledConsumer returns a consumer whose commands are run by a stand-in for the poll loop.
+func newControlledConsumer(t *testing.T, fake *fakeKafka) *KafkaConsumer {
+	kc := &KafkaConsumer{
+		consumer:    fake,
+		logger:      logger.NewMockLogger(),
+		topic:       "orders",
+		commands:    make(chan func()),
+		stopped:     make(chan struct{}),
+		pausedParts: make(map[int32]bool),
+		rewinds:     make(map[int32]kafka.Offset),
+		pool:        &workerPool{},
+	}
+	kc.running.Store(true)
+	go func() {
+		for {
+			select {
+			case cmd := <-kc.commands:
+				cmd()
+			case <-kc.stopped:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { close(kc.stopped) })
+	return kc
+}
+
+func TestKafkaConsumer_PauseResume(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("some partitions", func(t *testing.T) {
+		fake := newFakeKafka()
+		kc := newControlledConsumer(t, fake)
+
+		require.NoError(t, kc.Pause(ctx, []int32{1}))
+		assert.Equal(t, map[int32]bool{1: true}, fake.paused)
+		assert.ErrorIs(t, kc.Pause(ctx, []int32{7}), ErrNotAssigned)
+
+		st, err := kc.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, st.Partitions, 3)
+		assert.Equal(t, []bool{false, true, false}, []bool{st.Partitions[0].Paused, st.Partitions[1].Paused, st.Partitions[2].Paused})
+		assert.EqualValues(t, 5, st.Partitions[1].Lag)
+
+		require.NoError(t, kc.Resume(ctx, []int32{1}))
+		assert.Empty(t, fake.paused)
+	})
+
+	t.Run("a partial resume of all of them", func(t *testing.T) {
+		fake := newFakeKafka()
+		kc := newControlledConsumer(t, fake)
+
+		require.NoError(t, kc.Pause(ctx, nil))
+		assert.Len(t, fake.paused, 3)
+
+		require.NoError(t, kc.Resume(ctx, []int32{0}))
+		assert.Equal(t, map[int32]bool{1: true, 2: true}, fake.paused)
+		assert.False(t, kc.isHeld(0))
+		assert.True(t, kc.isHeld(2))
+	})
+
+	t.Run("the db health doesn't resume the held ones", func(t *testing.T) {
+		fake := newFakeKafka()
+		kc := newControlledConsumer(t, fake)
+		require.NoError(t, kc.Pause(ctx, []int32{2}))
+
+		healthy := new(MockDBHealth)
+		healthy.On("IsHealthy").Return(true)
+		kc.dbHealth = healthy
+		kc.healthPaused = true
+		require.NoError(t, kc.do(ctx, func() error {
+			kc.manageConsumerState(fake, &kc.healthPaused)
+			return nil
+		}))
+		assert.Equal(t, map[int32]bool{2: true}, fake.paused)
+	})
+
+	t.Run("a held message is sought back to", func(t *testing.T) {
+		fake := newFakeKafka()
+		kc := newControlledConsumer(t, fake)
+		require.NoError(t, kc.Pause(ctx, []int32{1}))
+
+		topic := "orders"
+		require.NoError(t, kc.do(ctx, func() error {
+			kc.rewind(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 7}})
+			kc.rewind(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 8}})
+			return nil
+		}))
+		assert.Equal(t, kafka.Offset(7), fake.seeks[1])
+	})
+
+	t.Run("not running", func(t *testing.T) {
+		kc := &KafkaConsumer{}
+		assert.ErrorIs(t, kc.Pause(ctx, nil), ErrNotRunning)
+	})
+}
+
+func TestKafkaConsumer_dispatch(t *testing.T) {
+	fake := newFakeKafka()
+	kc := &KafkaConsumer{
+		consumer:    fake,
+		logger:      logger.NewMockLogger(),
+		commands:    make(chan func()),
+		pausedParts: make(map[int32]bool),
+		rewinds:     make(map[int32]kafka.Offset),
+	}
+	kc.running.Store(true)
+
+	// nobody takes the jobs: the workers are all busy
+	jobs := make(chan *kafka.Message)
+	topic := "orders"
+	msg := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 7}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dispatched := make(chan bool)
+	go func() { dispatched <- kc.dispatch(ctx, jobs, msg) }()
+
+	// the admin commands still get through, a pause of the message's partition rewinds it
+	require.NoError(t, kc.Pause(ctx, []int32{1}))
+	assert.True(t, <-dispatched)
+	assert.Equal(t, kafka.Offset(7), fake.seeks[1])
+
+	go func() { dispatched <- kc.dispatch(ctx, jobs, msg) }()
+	cancel()
+	assert.False(t, <-dispatched)
+}
+
+func TestKafkaConsumer_Seek(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeKafka()
+	kc := newControlledConsumer(t, fake)
+
+	require.NoError(t, kc.Seek(ctx, 2, 100))
+	assert.Equal(t, kafka.Offset(100), fake.seeks[2])
+	assert.ErrorIs(t, kc.Seek(ctx, 2, -1), ErrInvalidOffset)
+	assert.ErrorIs(t, kc.Seek(ctx, 5, 1), ErrNotAssigned)
+
+	offset, err := kc.SeekToTime(ctx, 0, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, offset)
+	assert.Equal(t, kafka.Offset(42), fake.seeks[0])
+}
+
+func TestKafkaConsumer_SetWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	kc := newControlledConsumer(t, newFakeKafka())
+	kc.pool = &workerPool{jobs: make(chan *kafka.Message), deps: workerDependencies{ctx: ctx, logger: logger.NewMockLogger()}}
+
+	require.NoError(t, kc.SetWorkers(ctx, 4))
+	assert.Equal(t, 4, kc.pool.size())
+	require.NoError(t, kc.SetWorkers(ctx, 1))
+	assert.Equal(t, 1, kc.pool.size())
+	assert.ErrorIs(t, kc.SetWorkers(ctx, 0), ErrInvalidWorkerCount)
+	assert.ErrorIs(t, kc.SetWorkers(ctx, MaxWorkers+1), ErrInvalidWorkerCount)
+
+	// the stopped workers have returned, the last one returns with ctx
+	cancel()
+	kc.pool.wait()
+}
@@ -0,0 +1,292 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// MaxWorkers caps the worker count SetWorkers accepts.
+const MaxWorkers = 64
+
+var (
+	ErrNotRunning         = errors.New("the consumer is not running")
+	ErrNotAssigned        = errors.New("the partition is not assigned to this consumer")
+	ErrInvalidOffset      = errors.New("the offset must not be negative")
+	ErrInvalidWorkerCount = fmt.Errorf("the worker count must be between 1 and %d", MaxWorkers)
+)
+
+// PartitionStatus is an assigned partition as the admin sees it.
+type PartitionStatus struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Committed     int64  `json:"committed"` // negative if nothing's been committed yet
+	LowWatermark  int64  `json:"low_watermark"`
+	HighWatermark int64  `json:"high_watermark"`
+	Lag           int64  `json:"lag"`
+	Paused        bool   `json:"paused"` // by an admin
+}
+
+// Status is a snapshot of the consumer.
+type Status struct {
+	Workers        int               `json:"workers"`
+	PausedByHealth bool              `json:"paused_by_health"` // all the partitions, the db is unhealthy
+	PausedAll      bool              `json:"paused_all"`       // by an admin, the partitions assigned later too
+	Partitions     []PartitionStatus `json:"partitions"`
+}
+
+// do runs fn on the poll loop and returns its error. The loop is the only one touching
+// the pause state and the worker pool, so the commands can't race with it nor each other.
+func (kc *KafkaConsumer) do(ctx context.Context, fn func() error) error {
+	if !kc.running.Load() {
+		return ErrNotRunning
+	}
+
+	errc := make(chan error, 1)
+	select {
+	case kc.commands <- func() { errc <- fn() }:
+		return <-errc
+	case <-kc.stopped:
+		return ErrNotRunning
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the worker count, what's paused and the lag of every assigned partition.
+func (kc *KafkaConsumer) Status(ctx context.Context) (Status, error) {
+	var (
+		st   Status
+		held map[int32]bool
+	)
+	err := kc.do(ctx, func() error {
+		st.Workers = kc.pool.size()
+		st.PausedByHealth = kc.healthPaused
+		st.PausedAll = kc.pausedAll
+		held = make(map[int32]bool, len(kc.pausedParts))
+		for p := range kc.pausedParts {
+			held[p] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+
+	// the broker round trips are made here, the poll loop doesn't wait for them
+	lags, err := kc.partitionLags(timeoutMs(ctx))
+	if err != nil {
+		return st, err
+	}
+	for i := range lags {
+		lags[i].Paused = st.PausedAll || held[lags[i].Partition]
+	}
+	slices.SortFunc(lags, func(a, b PartitionStatus) int { return int(a.Partition - b.Partition) })
+	st.Partitions = lags
+	return st, nil
+}
+
+// Pause stops the consumption of the partitions, all of them if none are given, until
+// they are resumed. Unlike the pause of an unhealthy db, it holds when the db is healthy,
+// and a pause of all of them holds for the partitions assigned later on too.
+func (kc *KafkaConsumer) Pause(ctx context.Context, partitions []int32) error {
+	return kc.do(ctx, func() error {
+		tps, err := kc.assigned(partitions)
+		if err != nil {
+			return err
+		}
+
+		// held first: should the pause fail, the poll loop still rewinds their messages
+		if len(partitions) == 0 {
+			kc.pausedAll = true
+		}
+		for _, p := range partitions {
+			kc.pausedParts[p] = true
+		}
+		if len(tps) > 0 {
+			if err := kc.consumer.Pause(tps); err != nil {
+				return fmt.Errorf("pausing the partitions: %w", err)
+			}
+		}
+
+		kc.logger.Infow("Partitions paused by an admin", "partitions", tps, "all", len(partitions) == 0)
+		return nil
+	})
+}
+
+// Resume resumes the partitions paused by Pause, all of them if none are given.
+// While the db is unhealthy they stay paused until it's healthy again.
+func (kc *KafkaConsumer) Resume(ctx context.Context, partitions []int32) error {
+	return kc.do(ctx, func() error {
+		tps, err := kc.assigned(partitions)
+		if err != nil {
+			return err
+		}
+
+		if len(partitions) == 0 {
+			kc.pausedAll = false
+			clear(kc.pausedParts)
+		} else {
+			if kc.pausedAll {
+				// the rest of them stay paused
+				kc.pausedAll = false
+				all, err := kc.assigned(nil)
+				if err != nil {
+					return err
+				}
+				for _, tp := range all {
+					kc.pausedParts[tp.Partition] = true
+				}
+			}
+			for _, p := range partitions {
+				delete(kc.pausedParts, p)
+			}
+		}
+
+		if kc.healthPaused {
+			kc.logger.Infow("Partitions resumed by an admin, consumption restarts once the db is healthy", "partitions", tps)
+			return nil
+		}
+		if len(tps) > 0 {
+			if err := kc.consumer.Resume(tps); err != nil {
+				return fmt.Errorf("resuming the partitions: %w", err)
+			}
+		}
+		for _, tp := range tps {
+			delete(kc.rewinds, tp.Partition)
+		}
+
+		kc.logger.Infow("Partitions resumed by an admin", "partitions", tps)
+		return nil
+	})
+}
+
+// Seek moves the partition to the offset, the next message consumed from it is the one
+// at the offset. The position isn't committed until that message is processed, so a seek
+// is best done on a paused partition: the messages already fetched are processed regardless.
+func (kc *KafkaConsumer) Seek(ctx context.Context, partition int32, offset int64) error {
+	if offset < 0 {
+		return ErrInvalidOffset
+	}
+	return kc.seek(ctx, partition, kafka.Offset(offset))
+}
+
+// SeekToTime moves the partition to its first message produced at or after t, or to its
+// end if there's none, and returns the offset it was moved to. See Seek.
+func (kc *KafkaConsumer) SeekToTime(ctx context.Context, partition int32, t time.Time) (int64, error) {
+	times := []kafka.TopicPartition{{Topic: &kc.topic, Partition: partition, Offset: kafka.Offset(t.UnixMilli())}}
+	offsets, err := kc.consumer.OffsetsForTimes(times, timeoutMs(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("looking up the offset: %w", err)
+	}
+	if len(offsets) != 1 {
+		return 0, fmt.Errorf("looking up the offset: got %d partitions back", len(offsets))
+	}
+	if offsets[0].Error != nil {
+		return 0, fmt.Errorf("looking up the offset: %w", offsets[0].Error)
+	}
+
+	offset := offsets[0].Offset
+	if offset < 0 {
+		// nothing's been produced since, that's the end of the partition
+		_, high, err := kc.consumer.QueryWatermarkOffsets(kc.topic, partition, timeoutMs(ctx))
+		if err != nil {
+			return 0, fmt.Errorf("querying the watermarks: %w", err)
+		}
+		offset = kafka.Offset(high)
+	}
+
+	if err := kc.seek(ctx, partition, offset); err != nil {
+		return 0, err
+	}
+	return int64(offset), nil
+}
+
+func (kc *KafkaConsumer) seek(ctx context.Context, partition int32, offset kafka.Offset) error {
+	return kc.do(ctx, func() error {
+		tps, err := kc.assigned([]int32{partition})
+		if err != nil {
+			return err
+		}
+
+		tp := tps[0]
+		tp.Offset = offset
+		if err := kc.consumer.Seek(tp, timeoutMs(ctx)); err != nil {
+			return fmt.Errorf("seeking: %w", err)
+		}
+		delete(kc.rewinds, partition) // the admin's position wins
+
+		kc.logger.Infow("Partition sought by an admin", "partition", partition, "offset", offset)
+		return nil
+	})
+}
+
+// SetWorkers starts or stops workers until there are n of them. The stopped ones
+// finish the message at hand first.
+func (kc *KafkaConsumer) SetWorkers(ctx context.Context, n int) error {
+	if n < 1 || n > MaxWorkers {
+		return ErrInvalidWorkerCount
+	}
+	return kc.do(ctx, func() error {
+		from := kc.pool.size()
+		kc.pool.resize(n)
+		kc.logger.Infow("Worker pool resized by an admin", "from", from, "to", n)
+		return nil
+	})
+}
+
+// assigned returns the assigned partitions among the given ones, all of them if none
+// are given. It's an ErrNotAssigned if one of those given isn't assigned.
+func (kc *KafkaConsumer) assigned(partitions []int32) ([]kafka.TopicPartition, error) {
+	assignment, err := kc.consumer.Assignment()
+	if err != nil {
+		return nil, fmt.Errorf("getting the assignment: %w", err)
+	}
+	if len(partitions) == 0 {
+		return assignment, nil
+	}
+
+	tps := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, p := range partitions {
+		i := slices.IndexFunc(assignment, func(tp kafka.TopicPartition) bool { return tp.Partition == p })
+		if i < 0 {
+			return nil, fmt.Errorf("partition %d: %w", p, ErrNotAssigned)
+		}
+		tps = append(tps, assignment[i])
+	}
+	return tps, nil
+}
+
+// isHeld reports whether an admin paused the partition.
+func (kc *KafkaConsumer) isHeld(partition int32) bool {
+	return kc.pausedAll || kc.pausedParts[partition]
+}
+
+// unheld filters out the partitions an admin paused.
+func (kc *KafkaConsumer) unheld(tps []kafka.TopicPartition) []kafka.TopicPartition {
+	return slices.DeleteFunc(slices.Clone(tps), func(tp kafka.TopicPartition) bool { return kc.isHeld(tp.Partition) })
+}
+
+// rewind drops a message of a paused partition, fetched before the pause took effect, and
+// seeks the partition back to it, so it's fetched again once the partition is resumed.
+// Dropped without the seek it would be lost: the next message processed commits past it.
+func (kc *KafkaConsumer) rewind(msg *kafka.Message) {
+	tp := msg.TopicPartition
+	if offset, ok := kc.rewinds[tp.Partition]; ok && offset <= tp.Offset {
+		return // sought back to an earlier message already
+	}
+
+	// a partition assigned after a pause of all of them isn't paused yet
+	if err := kc.consumer.Pause([]kafka.TopicPartition{tp}); err != nil {
+		kc.logger.Errorw("Failed to pause the partition of a held message", "error", err, "partition", tp.Partition)
+	}
+	if err := kc.consumer.Seek(tp, 0); err != nil {
+		kc.logger.Errorw("Failed to seek back to a held message", "error", err, "partition", tp.Partition, "offset", tp.Offset)
+		return
+	}
+	kc.rewinds[tp.Partition] = tp.Offset
+}
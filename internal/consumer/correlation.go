@@ -0,0 +1,40 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// correlationHeaders are the headers a producer may put the correlation id into, the
+// first one is what the service itself sets (on the DLQ messages).
+var correlationHeaders = []string{"X-Correlation-ID", "X-Request-ID", "correlation_id"}
+
+// correlationID is the id everything logged for the message is tagged with: the producer's,
+// if it sent one, otherwise the message's position, which is unique and easy to look up.
+func correlationID(msg *kafka.Message) string {
+	if id := headerValue(msg.Headers, correlationHeaders...); id != "" {
+		return id
+	}
+
+	tp := msg.TopicPartition
+	topic := ""
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return fmt.Sprintf("%s-%d-%d", topic, tp.Partition, tp.Offset)
+}
+
+// headerValue returns the value of the first of the keys present, compared
+// case-insensitively, "" if there's none.
+func headerValue(headers []kafka.Header, keys ...string) string {
+	for _, key := range keys {
+		for _, h := range headers {
+			if strings.EqualFold(h.Key, key) && len(h.Value) > 0 {
+				return string(h.Value)
+			}
+		}
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package consumer
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -41,6 +42,7 @@ func drainDLQReports(ctx context.Context, p DLQManager, log logger.Logger) {
 }
 
 // monitorConsumerLag calculates consumer lag (the backpressure from the producer)
+// of every assigned partition every few seconds and exports it as a metric.
 func (kc *KafkaConsumer) monitorConsumerLag(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
@@ -50,42 +52,61 @@ func (kc *KafkaConsumer) monitorConsumerLag(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			assignedPartitions, err := kc.consumer.Assignment()
+			lags, err := kc.partitionLags(5000)
 			if err != nil {
-				kc.logger.Errorw("Failed to get assigned partitions for lag monitoring", "error", err)
+				kc.logger.Errorw("Failed to compute the consumer lag", "error", err)
 				continue
 			}
-
-			if len(assignedPartitions) == 0 {
-				continue
+			for _, p := range lags {
+				metrics.ConsumerLag.WithLabelValues(p.Topic, strconv.Itoa(int(p.Partition))).Set(float64(p.Lag))
 			}
+		}
+	}
+}
 
-			committedPartitions, err := kc.consumer.Committed(assignedPartitions, 5000)
-			if err != nil {
-				kc.logger.Errorw("Failed to get committed offsets for lag monitoring", "error", err)
-				continue
-			}
+// partitionLags computes the lag of every assigned partition: how far its committed offset
+// is behind the high watermark. A partition whose watermarks can't be queried is left out.
+func (kc *KafkaConsumer) partitionLags(timeoutMs int) ([]PartitionStatus, error) {
+	assignedPartitions, err := kc.consumer.Assignment()
+	if err != nil {
+		return nil, fmt.Errorf("getting the assigned partitions: %w", err)
+	}
+	if len(assignedPartitions) == 0 {
+		return nil, nil
+	}
 
-			for _, p := range committedPartitions {
-				low, high, err := kc.consumer.QueryWatermarkOffsets(*p.Topic, p.Partition, 5000)
-				if err != nil {
-					kc.logger.Errorw("Failed to query watermark offsets", "error", err, "topic", *p.Topic, "partition", p.Partition)
-					continue
-				}
+	committedPartitions, err := kc.consumer.Committed(assignedPartitions, timeoutMs)
+	if err != nil {
+		return nil, fmt.Errorf("getting the committed offsets: %w", err)
+	}
 
-				var lag int64
-				if p.Offset < 0 {
-					lag = high - low
-				} else {
-					lag = high - int64(p.Offset)
-				}
+	lags := make([]PartitionStatus, 0, len(committedPartitions))
+	for _, p := range committedPartitions {
+		low, high, err := kc.consumer.QueryWatermarkOffsets(*p.Topic, p.Partition, timeoutMs)
+		if err != nil {
+			kc.logger.Errorw("Failed to query watermark offsets", "error", err, "topic", *p.Topic, "partition", p.Partition)
+			continue
+		}
 
-				if lag < 0 {
-					lag = 0
-				}
+		var lag int64
+		if p.Offset < 0 {
+			lag = high - low
+		} else {
+			lag = high - int64(p.Offset)
+		}
 
-				metrics.ConsumerLag.WithLabelValues(*p.Topic, strconv.Itoa(int(p.Partition))).Set(float64(lag))
-			}
+		if lag < 0 {
+			lag = 0
 		}
+
+		lags = append(lags, PartitionStatus{
+			Topic:         *p.Topic,
+			Partition:     p.Partition,
+			Committed:     int64(p.Offset),
+			LowWatermark:  low,
+			HighWatermark: high,
+			Lag:           lag,
+		})
 	}
+	return lags, nil
 }
@@ -7,10 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/goinginblind/l0-task/internal/dlq"
 	"github.com/goinginblind/l0-task/internal/domain"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
 	"github.com/goinginblind/l0-task/internal/pkg/metrics"
@@ -23,10 +25,56 @@ type worker struct {
 	id           int
 	deps         workerDependencies
 	jobs         <-chan *kafka.Message
+	stop         <-chan struct{} // closed when the pool shrinks, the job at hand is finished first
 	maxRetries   int
 	retryBackoff time.Duration
 }
 
+// workerPool is the set of running workers, it's resized by the poll loop only.
+type workerPool struct {
+	wg           sync.WaitGroup
+	jobs         <-chan *kafka.Message
+	deps         workerDependencies
+	maxRetries   int
+	retryBackoff time.Duration
+	stops        []chan struct{} // one per running worker
+	nextID       int
+}
+
+// resize starts or stops workers until there are n of them.
+func (p *workerPool) resize(n int) {
+	for len(p.stops) < n {
+		stop := make(chan struct{})
+		w := &worker{
+			id:           p.nextID,
+			jobs:         p.jobs,
+			deps:         p.deps,
+			stop:         stop,
+			maxRetries:   p.maxRetries,
+			retryBackoff: p.retryBackoff,
+		}
+		p.nextID++
+		p.stops = append(p.stops, stop)
+		p.wg.Add(1)
+		go w.run(&p.wg)
+	}
+	for len(p.stops) > n {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+}
+
+// size is the number of running workers.
+func (p *workerPool) size() int {
+	return len(p.stops)
+}
+
+// wait blocks until every worker, stopped ones included, has returned.
+func (p *workerPool) wait() {
+	p.wg.Wait()
+}
+
 // workerDependencies contain all dependencies passed down to
 // workers from the kafka consumer. They are shared between all the workers.
 type workerDependencies struct {
@@ -37,6 +85,7 @@ type workerDependencies struct {
 	healthChecker UnhealthyMarker
 	dlqTopic      string
 	dlqPublisher  DLQProducer
+	publisher     OrderPublisher // nil if nobody listens
 }
 
 type Committer interface {
@@ -51,6 +100,11 @@ type UnhealthyMarker interface {
 	MarkUnhealthy()
 }
 
+// OrderPublisher is told about every order the consumer stored, the feed.Broadcaster implements it.
+type OrderPublisher interface {
+	Publish(order *domain.Order)
+}
+
 // run processes the message
 func (w *worker) run(wg *sync.WaitGroup) {
 	defer wg.Done()
@@ -59,6 +113,9 @@ func (w *worker) run(wg *sync.WaitGroup) {
 		case <-w.deps.ctx.Done():
 			w.deps.logger.Infow("Worker shutting down", "worker_id", w.id)
 			return
+		case <-w.stop:
+			w.deps.logger.Infow("Worker stopped, the pool shrank", "worker_id", w.id)
+			return
 		case msg, ok := <-w.jobs:
 			if !ok {
 				return
@@ -69,14 +126,17 @@ func (w *worker) run(wg *sync.WaitGroup) {
 				// Send to DLQ after recovery
 				defer func() {
 					if r := recover(); r != nil {
-						w.deps.logger.Errorw("worker encountered panic",
+						// the message value is an order with all its personal data, it stays out of the logs
+						ctx := logger.WithCorrelationID(w.deps.ctx, correlationID(msg))
+						logger.FromContext(ctx, w.deps.logger).Errorw("worker encountered panic",
 							"worker id", w.id,
-							"message", msg,
+							"message", msg.TopicPartition.String(),
+							"key", string(msg.Key),
 							"panic", r,
 							"stack", string(debug.Stack()),
 						)
 						metrics.DLQMessagesTotal.WithLabelValues("panic").Inc()
-						w.sendToDLQ(msg, fmt.Errorf("worker encountered panic: %v", r))
+						w.sendToDLQ(ctx, msg, fmt.Errorf("worker encountered panic: %v", r))
 					}
 				}()
 				w.processMessage(msg)
@@ -89,27 +149,31 @@ func (w *worker) run(wg *sync.WaitGroup) {
 // and result handling (passing down to the helper functions).
 func (w *worker) processMessage(msg *kafka.Message) {
 	defer metrics.ObserveKafkaMessageLatency(msg, metrics.MessageProcessingLatency)
+
+	// everything logged for the message, down to the store, carries its correlation id
+	ctx := logger.WithCorrelationID(w.deps.ctx, correlationID(msg))
+
 	var order domain.Order
 	dec := json.NewDecoder(bytes.NewReader(msg.Value))
 	dec.DisallowUnknownFields()
 
 	if err := dec.Decode(&order); err != nil {
 		metrics.MessagesProcessedTotal.WithLabelValues("invalid").Inc()
-		w.deps.logger.Errorw("Failed to unmarshal message, discarding", "error", err)
-		w.commit(msg)
+		logger.FromContext(ctx, w.deps.logger).Errorw("Failed to unmarshal message, discarding", "error", err)
+		w.commit(ctx, msg)
 		return
 	}
 
-	processErr := w.processWithRetries(&order)        // process, extract error or retry if possible
-	w.handleProcessingResult(msg, &order, processErr) // error (or nil) goes here, commit or no trough select statement
+	processErr := w.processWithRetries(ctx, &order)        // process, extract error or retry if possible
+	w.handleProcessingResult(ctx, msg, &order, processErr) // error (or nil) goes here, commit or no trough select statement
 }
 
 // processWithRetries passes the message down
 // to the service layer, contains the retry loop for handling transient DB errors.
-func (w *worker) processWithRetries(order *domain.Order) error {
+func (w *worker) processWithRetries(ctx context.Context, order *domain.Order) error {
 	var processErr error
 	for attempt := 0; attempt < w.maxRetries; attempt++ {
-		processErr = w.deps.service.ProcessNewOrder(w.deps.ctx, order)
+		processErr = w.deps.service.ProcessNewOrder(ctx, order)
 		if processErr == nil {
 			return nil // success
 		}
@@ -122,7 +186,7 @@ func (w *worker) processWithRetries(order *domain.Order) error {
 		metrics.DbTransientErrors.Inc()
 
 		// If it was a connection error, log a warning and wait before the next attempt.
-		w.deps.logger.Warnw("Transient DB connection error, will retry.",
+		logger.FromContext(ctx, w.deps.logger).Warnw("Transient DB connection error, will retry.",
 			"order_uid", order.OrderUID,
 			"attempt", attempt,
 			"retry_in", w.retryBackoff,
@@ -135,11 +199,15 @@ func (w *worker) processWithRetries(order *domain.Order) error {
 }
 
 // handleProcessingResult inspects the final error and decides what to do.
-func (w *worker) handleProcessingResult(msg *kafka.Message, order *domain.Order, processErr error) {
+func (w *worker) handleProcessingResult(ctx context.Context, msg *kafka.Message, order *domain.Order, processErr error) {
+	log := logger.FromContext(ctx, w.deps.logger)
 	if processErr == nil {
 		metrics.MessagesProcessedTotal.WithLabelValues("valid").Inc()
-		w.deps.logger.Infow("order successfully processed", "worker_id", w.id, "order_uid", order.OrderUID)
-		w.commit(msg)
+		log.Infow("order successfully processed", "worker_id", w.id, "order_uid", order.OrderUID)
+		w.commit(ctx, msg)
+		if w.deps.publisher != nil {
+			w.deps.publisher.Publish(order)
+		}
 		return
 	}
 
@@ -147,24 +215,24 @@ func (w *worker) handleProcessingResult(msg *kafka.Message, order *domain.Order,
 	case errors.Is(processErr, domain.ErrInvalidOrder):
 		metrics.MessagesProcessedTotal.WithLabelValues("invalid").Inc()
 		metrics.DLQMessagesTotal.WithLabelValues("invalid_order").Inc()
-		w.deps.logger.Warnw("Invalid order received, sending to DLQ",
+		log.Warnw("Invalid order received, sending to DLQ",
 			"order_uid", order.OrderUID,
 			"error", processErr,
 		)
-		w.sendToDLQ(msg, processErr)
+		w.sendToDLQ(ctx, msg, processErr)
 
 	case errors.Is(processErr, store.ErrAlreadyExists):
 		metrics.MessagesProcessedTotal.WithLabelValues("invalid").Inc()
 		metrics.DLQMessagesTotal.WithLabelValues("already_exists").Inc()
-		w.deps.logger.Warnw("Order already exists, sending to DLQ",
+		log.Warnw("Order already exists, sending to DLQ",
 			"order_uid", order.OrderUID,
 			"error", processErr,
 		)
-		w.sendToDLQ(msg, processErr)
+		w.sendToDLQ(ctx, msg, processErr)
 
 	case errors.Is(processErr, store.ErrConnectionFailed):
 		metrics.MessagesProcessedTotal.WithLabelValues("error").Inc()
-		w.deps.logger.Errorw("Worker failed to process order due to DB connection error.",
+		log.Errorw("Worker failed to process order due to DB connection error.",
 			"order_uid", order.OrderUID,
 			"error", processErr,
 		)
@@ -174,38 +242,52 @@ func (w *worker) handleProcessingResult(msg *kafka.Message, order *domain.Order,
 	default:
 		metrics.MessagesProcessedTotal.WithLabelValues("error").Inc()
 		metrics.DLQMessagesTotal.WithLabelValues("unhandled_error").Inc()
-		w.deps.logger.Errorw("Failed to process order with an unhandled error, sending to DLQ",
+		log.Errorw("Failed to process order with an unhandled error, sending to DLQ",
 			"order_uid", order.OrderUID,
 			"error", processErr,
 		)
-		w.sendToDLQ(msg, processErr)
+		w.sendToDLQ(ctx, msg, processErr)
 	}
 
-	w.commit(msg)
+	w.commit(ctx, msg)
 }
 
 // commit commits the message
-func (w *worker) commit(msg *kafka.Message) {
+func (w *worker) commit(ctx context.Context, msg *kafka.Message) {
 	if msg == nil {
 		return
 	}
 
 	_, err := w.deps.consumer.CommitMessage(msg)
 	if err != nil {
-		w.deps.logger.Errorw("Failed to commit message", "error", err)
+		logger.FromContext(ctx, w.deps.logger).Errorw("Failed to commit message", "error", err)
 	}
 }
 
 // sendToDLQ sends the message to the dead-line queue topic
 // specified in the config (default 'orders-dlq').
-func (w *worker) sendToDLQ(msg *kafka.Message, reason error) {
-	dlqHeaders := msg.Headers
+func (w *worker) sendToDLQ(ctx context.Context, msg *kafka.Message, reason error) {
+	// the copy keeps appends from writing into the consumed message's backing array
+	dlqHeaders := append([]kafka.Header(nil), msg.Headers...)
+	if headerValue(msg.Headers, correlationHeaders...) == "" {
+		// a derived id is passed on, so the message can be traced once it's replayed
+		dlqHeaders = append(dlqHeaders, kafka.Header{Key: correlationHeaders[0], Value: []byte(logger.CorrelationID(ctx))})
+	}
 	if reason != nil {
 		dlqHeaders = append(dlqHeaders, kafka.Header{
-			Key:   "DLQ REASON",
+			Key:   dlq.HeaderReason,
 			Value: []byte(reason.Error()),
 		})
 	}
+	// where it came from, the dlq browser shows it
+	tp := msg.TopicPartition
+	if tp.Topic != nil {
+		dlqHeaders = append(dlqHeaders, kafka.Header{Key: dlq.HeaderSourceTopic, Value: []byte(*tp.Topic)})
+	}
+	dlqHeaders = append(dlqHeaders,
+		kafka.Header{Key: dlq.HeaderSourcePartition, Value: []byte(strconv.Itoa(int(tp.Partition)))},
+		kafka.Header{Key: dlq.HeaderSourceOffset, Value: []byte(strconv.FormatInt(int64(tp.Offset), 10))},
+	)
 
 	err := w.deps.dlqPublisher.Produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &w.deps.dlqTopic, Partition: kafka.PartitionAny},
@@ -215,6 +297,6 @@ func (w *worker) sendToDLQ(msg *kafka.Message, reason error) {
 	}, nil)
 
 	if err != nil {
-		w.deps.logger.Errorw("Failed to produce message to DLQ", "error", err, "order_uid", string(msg.Key))
+		logger.FromContext(ctx, w.deps.logger).Errorw("Failed to produce message to DLQ", "error", err, "order_uid", string(msg.Key))
 	}
 }
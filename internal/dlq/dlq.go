@@ -0,0 +1,246 @@
+// Package dlq reads the dead-letter topic back into the database, where the messages can be
+// browsed, and replays them to the orders topic or discards them once someone's looked into them.
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// The headers the consumer puts on a dead-lettered message, next to the ones it came with.
+// They all start with "DLQ ", a replay strips them.
+const (
+	HeaderReason          = "DLQ REASON"
+	HeaderSourceTopic     = "DLQ SOURCE TOPIC"
+	HeaderSourcePartition = "DLQ SOURCE PARTITION"
+	HeaderSourceOffset    = "DLQ SOURCE OFFSET"
+	// HeaderReplayOf is put on a replayed message, it's the id of the dlq message it replays.
+	// Should the replay fail again, its dlq message carries it along.
+	HeaderReplayOf = "DLQ REPLAY OF"
+)
+
+// ErrReplayFailed is returned when a replayed message isn't delivered to the orders topic.
+var ErrReplayFailed = errors.New("the replay was not delivered")
+
+// Store is the storage of the dlq messages, the *store.DBStore implements it.
+type Store interface {
+	SaveDLQMessage(ctx context.Context, m *store.DLQMessage) error
+	GetDLQMessage(ctx context.Context, id int64) (*store.DLQMessage, error)
+	ListDLQMessages(ctx context.Context, q store.DLQQuery) (*store.DLQPage, error)
+	DLQAuditTrail(ctx context.Context, id int64) ([]store.DLQAuditEntry, error)
+	ClaimDLQMessage(ctx context.Context, id int64) (*store.DLQMessage, error)
+	ReleaseDLQMessage(ctx context.Context, id int64) error
+	ResolveDLQMessage(ctx context.Context, id int64, res store.DLQResolution) (*store.DLQMessage, error)
+}
+
+// Producer is what the replays are sent with, the concrete *kafka.Producer implements it.
+type Producer interface {
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	Close()
+}
+
+// Service browses, replays and discards the dlq messages.
+type Service struct {
+	store         Store
+	producer      Producer
+	topic         string // the replays go here
+	replayTimeout time.Duration
+	logger        logger.Logger
+}
+
+// NewService creates a Service replaying to the topic, with a producer of its own.
+func NewService(kafCfg config.KafkaConfig, cfg config.DLQConfig, topic string, st Store, log logger.Logger) (*Service, error) {
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  kafCfg.BootstrapServers,
+		"acks":               "all",
+		"enable.idempotence": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to create replay producer: %v", err)
+	}
+
+	return &Service{
+		store:         st,
+		producer:      producer,
+		topic:         topic,
+		replayTimeout: cfg.ReplayTimeout,
+		logger:        log,
+	}, nil
+}
+
+// Close closes the replay producer.
+func (s *Service) Close() {
+	s.producer.Close()
+}
+
+// List returns a page of the dlq messages, newest first.
+func (s *Service) List(ctx context.Context, q store.DLQQuery) (*store.DLQPage, error) {
+	return s.store.ListDLQMessages(ctx, q)
+}
+
+// Get returns a dlq message together with its audit trail.
+func (s *Service) Get(ctx context.Context, id int64) (*store.DLQMessage, []store.DLQAuditEntry, error) {
+	m, err := s.store.GetDLQMessage(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	trail, err := s.store.DLQAuditTrail(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, trail, nil
+}
+
+// Replay sends a pending dlq message back to the orders topic, it's only marked as replayed
+// once the delivery is confirmed. The message is claimed meanwhile, so it isn't replayed twice,
+// and it goes back to pending if the delivery fails. A replay that timed out may still be
+// delivered later on, which is harmless: the consumer sends a duplicate order back into the dlq.
+func (s *Service) Replay(ctx context.Context, id int64, actor, note string) (*store.DLQMessage, error) {
+	m, err := s.store.ClaimDLQMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// whatever happens to the request, the claim has to be settled
+	settleCtx := context.WithoutCancel(ctx)
+	if err := s.produce(ctx, replayMessage(m, s.topic)); err != nil {
+		if relErr := s.store.ReleaseDLQMessage(settleCtx, id); relErr != nil {
+			s.logger.Errorw("Failed to release DLQ message after a failed replay", "id", id, "error", relErr)
+		}
+		return nil, err
+	}
+
+	res := store.DLQResolution{Status: store.DLQReplayed, Actor: actor, Note: note}
+	m, err = s.store.ResolveDLQMessage(settleCtx, id, res)
+	if err != nil {
+		// it's delivered, it stays claimed rather than pending so it can't be replayed again
+		s.logger.Errorw("DLQ message replayed but not marked as replayed", "id", id, "actor", actor, "error", err)
+		return nil, err
+	}
+
+	metrics.DLQResolvedTotal.WithLabelValues(string(store.DLQReplayed)).Inc()
+	s.logger.Infow("DLQ message replayed", "id", id, "key", m.Key, "actor", actor)
+	return m, nil
+}
+
+// Discard gives up on a pending dlq message, or one left replaying, it stays in the database
+// for the record.
+func (s *Service) Discard(ctx context.Context, id int64, actor, note string) (*store.DLQMessage, error) {
+	res := store.DLQResolution{Status: store.DLQDiscarded, Actor: actor, Note: note}
+	m, err := s.store.ResolveDLQMessage(ctx, id, res)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.DLQResolvedTotal.WithLabelValues(string(store.DLQDiscarded)).Inc()
+	s.logger.Infow("DLQ message discarded", "id", id, "key", m.Key, "actor", actor)
+	return m, nil
+}
+
+// produce sends the message and waits for its delivery report.
+func (s *Service) produce(ctx context.Context, msg *kafka.Message) error {
+	if s.replayTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.replayTimeout)
+		defer cancel()
+	}
+
+	delivery := make(chan kafka.Event, 1)
+	if err := s.producer.Produce(msg, delivery); err != nil {
+		return fmt.Errorf("%w: %v", ErrReplayFailed, err)
+	}
+
+	select {
+	case e := <-delivery:
+		if m, ok := e.(*kafka.Message); ok && m.TopicPartition.Error != nil {
+			return fmt.Errorf("%w: %v", ErrReplayFailed, m.TopicPartition.Error)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrReplayFailed, ctx.Err())
+	}
+}
+
+// replayMessage is the message a replay of m sends: the original key, payload and
+// headers, the dlq ones swapped for HeaderReplayOf.
+func replayMessage(m *store.DLQMessage, topic string) *kafka.Message {
+	headers := make([]kafka.Header, 0, len(m.Headers)+1)
+	for _, h := range m.Headers {
+		if strings.HasPrefix(h.Key, "DLQ ") {
+			continue
+		}
+		headers = append(headers, kafka.Header{Key: h.Key, Value: []byte(h.Value)})
+	}
+	headers = append(headers, kafka.Header{Key: HeaderReplayOf, Value: []byte(strconv.FormatInt(m.ID, 10))})
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          m.Payload,
+		Headers:        headers,
+	}
+	if m.Key != "" {
+		msg.Key = []byte(m.Key)
+	}
+	return msg
+}
+
+// parseMessage maps a message of the dlq topic onto its database record. The key and the
+// headers are kept byte for byte, they're replayed.
+func parseMessage(msg *kafka.Message) *store.DLQMessage {
+	tp := msg.TopicPartition
+	m := &store.DLQMessage{
+		DLQPartition: tp.Partition,
+		DLQOffset:    int64(tp.Offset),
+		Key:          string(msg.Key),
+		Payload:      msg.Value,
+		DeadAt:       msg.Timestamp,
+		Headers:      make([]store.DLQHeader, 0, len(msg.Headers)),
+	}
+	if tp.Topic != nil {
+		m.DLQTopic = *tp.Topic
+	}
+	if m.Payload == nil {
+		m.Payload = []byte{}
+	}
+	if m.DeadAt.IsZero() {
+		m.DeadAt = time.Now()
+	}
+
+	for _, h := range msg.Headers {
+		value := string(h.Value)
+		switch h.Key {
+		case HeaderReason:
+			m.Reason = text(value)
+		case HeaderSourceTopic:
+			topic := text(value)
+			m.SourceTopic = &topic
+		case HeaderSourcePartition:
+			if p, err := strconv.ParseInt(value, 10, 32); err == nil {
+				partition := int32(p)
+				m.SourcePartition = &partition
+			}
+		case HeaderSourceOffset:
+			if offset, err := strconv.ParseInt(value, 10, 64); err == nil {
+				m.SourceOffset = &offset
+			}
+		}
+		m.Headers = append(m.Headers, store.DLQHeader{Key: h.Key, Value: value})
+	}
+	return m
+}
+
+// text makes a header value fit for a text column, which takes neither invalid UTF-8 nor
+// a NUL. Only for what's shown, the headers themselves are stored as they are.
+func text(s string) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(s, "\x00", ""), "\uFFFD")
+}
@@ -0,0 +1,299 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps the dlq messages in memory, saveErrs are returned by the saves first
+// and resolveErr by every resolution.
+type fakeStore struct {
+	Store
+	messages   map[int64]*store.DLQMessage
+	saved      []*store.DLQMessage
+	saveErrs   []error
+	resolveErr error
+	audit      []store.DLQResolution
+}
+
+func (f *fakeStore) SaveDLQMessage(ctx context.Context, m *store.DLQMessage) error {
+	if len(f.saveErrs) > 0 {
+		err := f.saveErrs[0]
+		f.saveErrs = f.saveErrs[1:]
+		return err
+	}
+	f.saved = append(f.saved, m)
+	return nil
+}
+
+func (f *fakeStore) ClaimDLQMessage(ctx context.Context, id int64) (*store.DLQMessage, error) {
+	m, ok := f.messages[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if m.Status != store.DLQPending {
+		return nil, store.ErrDLQResolved
+	}
+	m.Status = store.DLQReplaying
+	return m, nil
+}
+
+func (f *fakeStore) ReleaseDLQMessage(ctx context.Context, id int64) error {
+	if m, ok := f.messages[id]; ok && m.Status == store.DLQReplaying {
+		m.Status = store.DLQPending
+	}
+	return nil
+}
+
+func (f *fakeStore) ResolveDLQMessage(ctx context.Context, id int64, res store.DLQResolution) (*store.DLQMessage, error) {
+	m, ok := f.messages[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if m.Status != store.DLQReplaying && (m.Status != store.DLQPending || res.Status == store.DLQReplayed) {
+		return nil, store.ErrDLQResolved
+	}
+	if f.resolveErr != nil {
+		return nil, f.resolveErr
+	}
+	m.Status = res.Status
+	f.audit = append(f.audit, res)
+	return m, nil
+}
+
+// fakeProducer delivers every message with err, unless it's told to never report.
+type fakeProducer struct {
+	produced []*kafka.Message
+	err      error
+	silent   bool
+}
+
+func (p *fakeProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	p.produced = append(p.produced, msg)
+	if !p.silent {
+		report := *msg
+		report.TopicPartition.Error = p.err
+		deliveryChan <- &report
+	}
+	return nil
+}
+
+func (p *fakeProducer) Close() {}
+
+func testDLQMessage() *store.DLQMessage {
+	return &store.DLQMessage{
+		ID:      7,
+		Key:     "b563feb7b2b84b6test",
+		Payload: []byte(`{"order_uid": "b563feb7b2b84b6test"}`),
+		Headers: []store.DLQHeader{
+			{Key: "X-Correlation-ID", Value: "req-1"},
+			{Key: HeaderReason, Value: "invalid order"},
+			{Key: HeaderSourceOffset, Value: "42"},
+		},
+		Status: store.DLQPending,
+	}
+}
+
+func Test_parseMessage(t *testing.T) {
+	topic := "orders-dlq"
+	deadAt := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	m := parseMessage(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 99},
+		Key:            []byte("uid"),
+		Value:          []byte(`{}`),
+		Timestamp:      deadAt,
+		Headers: []kafka.Header{
+			{Key: HeaderReason, Value: []byte("record already exists")},
+			{Key: HeaderSourceTopic, Value: []byte("orders")},
+			{Key: HeaderSourcePartition, Value: []byte("2")},
+			{Key: HeaderSourceOffset, Value: []byte("not a number")},
+		},
+	})
+
+	assert.Equal(t, "orders-dlq", m.DLQTopic)
+	assert.EqualValues(t, 1, m.DLQPartition)
+	assert.EqualValues(t, 99, m.DLQOffset)
+	assert.Equal(t, "uid", m.Key)
+	assert.Equal(t, "record already exists", m.Reason)
+	assert.Equal(t, deadAt, m.DeadAt)
+	require.NotNil(t, m.SourceTopic)
+	assert.Equal(t, "orders", *m.SourceTopic)
+	require.NotNil(t, m.SourcePartition)
+	assert.EqualValues(t, 2, *m.SourcePartition)
+	assert.Nil(t, m.SourceOffset, "a malformed header is left out")
+	assert.Len(t, m.Headers, 4)
+}
+
+func Test_parseMessage_bytes(t *testing.T) {
+	topic := "orders-dlq"
+	m := parseMessage(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Key:            []byte("uid\x00\xff"),
+		Headers: []kafka.Header{
+			{Key: HeaderReason, Value: []byte("bad\x00\xffbytes")},
+			{Key: "X-Trace", Value: []byte{0xfe, 0x00, 't'}},
+		},
+	})
+
+	// what's shown fits a text column, what's replayed is kept byte for byte
+	assert.Equal(t, "bad\uFFFDbytes", m.Reason)
+	replayed := replayMessage(m, "orders")
+	assert.Equal(t, []byte("uid\x00\xff"), replayed.Key)
+	assert.Equal(t, kafka.Header{Key: "X-Trace", Value: []byte{0xfe, 0x00, 't'}}, replayed.Headers[0])
+}
+
+func TestService_Replay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivered", func(t *testing.T) {
+		st := &fakeStore{messages: map[int64]*store.DLQMessage{7: testDLQMessage()}}
+		producer := &fakeProducer{}
+		s := &Service{store: st, producer: producer, topic: "orders", replayTimeout: time.Second, logger: logger.NewMockLogger()}
+
+		m, err := s.Replay(ctx, 7, "ops", "fixed the validation")
+		require.NoError(t, err)
+		assert.Equal(t, store.DLQReplayed, m.Status)
+		assert.Equal(t, []store.DLQResolution{{Status: store.DLQReplayed, Actor: "ops", Note: "fixed the validation"}}, st.audit)
+
+		require.Len(t, producer.produced, 1)
+		msg := producer.produced[0]
+		assert.Equal(t, "orders", *msg.TopicPartition.Topic)
+		assert.Equal(t, "b563feb7b2b84b6test", string(msg.Key))
+		assert.JSONEq(t, `{"order_uid": "b563feb7b2b84b6test"}`, string(msg.Value))
+		// the dlq headers are swapped for the replay one, the rest stays
+		assert.Equal(t, []kafka.Header{
+			{Key: "X-Correlation-ID", Value: []byte("req-1")},
+			{Key: HeaderReplayOf, Value: []byte("7")},
+		}, msg.Headers)
+
+		_, err = s.Replay(ctx, 7, "ops", "")
+		assert.ErrorIs(t, err, store.ErrDLQResolved)
+		assert.Len(t, producer.produced, 1)
+	})
+
+	t.Run("not delivered", func(t *testing.T) {
+		st := &fakeStore{messages: map[int64]*store.DLQMessage{7: testDLQMessage()}}
+		s := &Service{store: st, producer: &fakeProducer{err: errors.New("broker down")}, topic: "orders", logger: logger.NewMockLogger()}
+
+		_, err := s.Replay(ctx, 7, "ops", "")
+		assert.ErrorIs(t, err, ErrReplayFailed)
+		assert.Equal(t, store.DLQPending, st.messages[7].Status)
+		assert.Empty(t, st.audit)
+	})
+
+	t.Run("no delivery report in time", func(t *testing.T) {
+		st := &fakeStore{messages: map[int64]*store.DLQMessage{7: testDLQMessage()}}
+		s := &Service{store: st, producer: &fakeProducer{silent: true}, topic: "orders", replayTimeout: 10 * time.Millisecond, logger: logger.NewMockLogger()}
+
+		_, err := s.Replay(ctx, 7, "ops", "")
+		assert.ErrorIs(t, err, ErrReplayFailed)
+		assert.Equal(t, store.DLQPending, st.messages[7].Status)
+	})
+
+	t.Run("delivered but not resolved", func(t *testing.T) {
+		st := &fakeStore{messages: map[int64]*store.DLQMessage{7: testDLQMessage()}, resolveErr: store.ErrConnectionFailed}
+		producer := &fakeProducer{}
+		s := &Service{store: st, producer: producer, topic: "orders", replayTimeout: time.Second, logger: logger.NewMockLogger()}
+
+		_, err := s.Replay(ctx, 7, "ops", "")
+		assert.ErrorIs(t, err, store.ErrConnectionFailed)
+		assert.Equal(t, store.DLQReplaying, st.messages[7].Status, "stays claimed")
+
+		_, err = s.Replay(ctx, 7, "ops", "")
+		assert.ErrorIs(t, err, store.ErrDLQResolved)
+		assert.Len(t, producer.produced, 1, "not replayed twice")
+	})
+}
+
+func TestService_Discard(t *testing.T) {
+	st := &fakeStore{messages: map[int64]*store.DLQMessage{7: testDLQMessage()}}
+	producer := &fakeProducer{}
+	s := &Service{store: st, producer: producer, topic: "orders", logger: logger.NewMockLogger()}
+
+	m, err := s.Discard(context.Background(), 7, "ops", "a test order")
+	require.NoError(t, err)
+	assert.Equal(t, store.DLQDiscarded, m.Status)
+	assert.Empty(t, producer.produced)
+
+	_, err = s.Discard(context.Background(), 7, "ops", "")
+	assert.ErrorIs(t, err, store.ErrDLQResolved)
+	_, err = s.Discard(context.Background(), 8, "ops", "")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// a replay that died halfway
+	stuck := testDLQMessage()
+	stuck.Status = store.DLQReplaying
+	st.messages[9] = stuck
+	m, err = s.Discard(context.Background(), 9, "ops", "delivered, checked the topic")
+	require.NoError(t, err)
+	assert.Equal(t, store.DLQDiscarded, m.Status)
+}
+
+// fakeConsumer records the commits.
+type fakeConsumer struct {
+	ingestConsumer
+	committed []*kafka.Message
+}
+
+func (c *fakeConsumer) CommitMessage(msg *kafka.Message) ([]kafka.TopicPartition, error) {
+	c.committed = append(c.committed, msg)
+	return nil, nil
+}
+
+func TestIngester_ingest(t *testing.T) {
+	topic := "orders-dlq"
+	msg := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: 3}, Value: []byte(`{}`)}
+
+	t.Run("retried until stored", func(t *testing.T) {
+		st := &fakeStore{saveErrs: []error{store.ErrConnectionFailed, store.ErrConnectionFailed}}
+		consumer := &fakeConsumer{}
+		in := &Ingester{consumer: consumer, store: st, logger: logger.NewMockLogger(), retryBackoff: time.Millisecond}
+
+		in.ingest(context.Background(), msg)
+		require.Len(t, st.saved, 1)
+		assert.EqualValues(t, 3, st.saved[0].DLQOffset)
+		assert.Len(t, consumer.committed, 1)
+	})
+
+	t.Run("rejected ones are skipped", func(t *testing.T) {
+		st := &fakeStore{saveErrs: []error{errors.New("inserting dlq message: invalid byte sequence")}}
+		consumer := &fakeConsumer{}
+		in := &Ingester{consumer: consumer, store: st, logger: logger.NewMockLogger(), retryBackoff: time.Hour}
+
+		in.ingest(context.Background(), msg)
+		assert.Empty(t, st.saved)
+		assert.Len(t, consumer.committed, 1)
+	})
+
+	t.Run("not skipped when cancelled", func(t *testing.T) {
+		st := &fakeStore{saveErrs: []error{context.Canceled}}
+		consumer := &fakeConsumer{}
+		in := &Ingester{consumer: consumer, store: st, logger: logger.NewMockLogger(), retryBackoff: time.Hour}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		in.ingest(ctx, msg)
+		assert.Empty(t, consumer.committed)
+	})
+
+	t.Run("not committed on shutdown", func(t *testing.T) {
+		st := &fakeStore{saveErrs: []error{store.ErrConnectionFailed}}
+		consumer := &fakeConsumer{}
+		in := &Ingester{consumer: consumer, store: st, logger: logger.NewMockLogger(), retryBackoff: time.Hour}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		in.ingest(ctx, msg)
+		assert.Empty(t, st.saved)
+		assert.Empty(t, consumer.committed)
+	})
+}
@@ -0,0 +1,115 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// ingestRetryBackoff is how long the ingester waits before it retries a message
+// it failed to store because the db is unreachable.
+const ingestRetryBackoff = 2 * time.Second
+
+// ingestConsumer is the part of the concrete *kafka.Consumer the ingester uses.
+type ingestConsumer interface {
+	Poll(timeoutMs int) kafka.Event
+	CommitMessage(msg *kafka.Message) ([]kafka.TopicPartition, error)
+	Close() error
+}
+
+// Ingester reads the dlq topic back into the database. It's a consumer group of its own,
+// the offsets are committed once a message is stored, so none is lost nor stored twice.
+type Ingester struct {
+	consumer     ingestConsumer
+	store        Store
+	logger       logger.Logger
+	retryBackoff time.Duration
+}
+
+// NewIngester creates an Ingester subscribed to the dlq topic.
+func NewIngester(kafCfg config.KafkaConfig, cfg config.DLQConfig, topic string, st Store, log logger.Logger) (*Ingester, error) {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  kafCfg.BootstrapServers,
+		"group.id":           cfg.GroupID,
+		"auto.offset.reset":  "earliest", // every dead letter there is
+		"enable.auto.commit": false,
+		"isolation.level":    kafCfg.IsolationLevel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to create dlq consumer: %v", err)
+	}
+	if err := consumer.Subscribe(topic, nil); err != nil {
+		return nil, fmt.Errorf("fail to subscribe to the dlq: %v", err)
+	}
+
+	return &Ingester{
+		consumer:     consumer,
+		store:        st,
+		logger:       log,
+		retryBackoff: ingestRetryBackoff,
+	}, nil
+}
+
+// Run reads the dlq topic until ctx is done, then closes the consumer.
+func (in *Ingester) Run(ctx context.Context) {
+	defer in.consumer.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		switch e := in.consumer.Poll(100).(type) {
+		case *kafka.Message:
+			in.ingest(ctx, e)
+		case kafka.Error:
+			in.logger.Errorw("DLQ consumer error", "error", e, "is_fatal", e.IsFatal())
+		}
+	}
+}
+
+// ingest stores the message and commits it. While the db is unreachable the store is retried
+// until it works or ctx is done: the messages after it wait, skipping it would lose it once
+// they are committed. A message the db rejects is logged and skipped, it would never get in
+// and would hold up every message after it; it's still in the dlq topic at its offset.
+func (in *Ingester) ingest(ctx context.Context, msg *kafka.Message) {
+	m := parseMessage(msg)
+	for {
+		err := in.store.SaveDLQMessage(ctx, m)
+		if err == nil {
+			metrics.DLQIngestedTotal.Inc()
+			break
+		}
+		if ctx.Err() != nil {
+			return // not committed, it's read again on the next start
+		}
+		if !errors.Is(err, store.ErrConnectionFailed) {
+			metrics.DLQSkippedTotal.Inc()
+			in.logger.Errorw("DLQ message rejected by the store, skipped", "error", err,
+				"partition", m.DLQPartition, "offset", m.DLQOffset, "key", m.Key, "reason", m.Reason)
+			break
+		}
+		in.logger.Errorw("Failed to store DLQ message, retrying", "error", err,
+			"partition", m.DLQPartition, "offset", m.DLQOffset)
+
+		select {
+		case <-ctx.Done():
+			return // not committed, it's read again on the next start
+		case <-time.After(in.retryBackoff):
+		}
+	}
+
+	if _, err := in.consumer.CommitMessage(msg); err != nil {
+		// it's read again, the store ignores the duplicate
+		in.logger.Errorw("Failed to commit DLQ message", "error", err, "partition", m.DLQPartition, "offset", m.DLQOffset)
+	}
+}
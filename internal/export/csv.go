@@ -0,0 +1,115 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+)
+
+// ItemsCSVHeader is the header row of the items CSV, one column per domain.Item field
+// prefixed by the order uid, so files of several orders can be concatenated.
+var ItemsCSVHeader = []string{
+	"order_uid", "chrt_id", "track_number", "price", "rid", "name",
+	"sale", "size", "total_price", "nm_id", "brand", "status",
+}
+
+// ItemCSVRecord flattens the item into a row matching ItemsCSVHeader.
+func ItemCSVRecord(orderUID string, it domain.Item) []string {
+	return []string{
+		orderUID,
+		strconv.Itoa(it.ChrtID),
+		it.TrackNumber,
+		strconv.Itoa(it.Price),
+		it.Rid,
+		it.Name,
+		strconv.Itoa(it.Sale),
+		it.Size,
+		strconv.Itoa(it.TotalPrice),
+		strconv.Itoa(it.NmID),
+		it.Brand,
+		strconv.Itoa(it.Status),
+	}
+}
+
+// ordersCSVHeader is the header row of the orders CSV, the nested objects are prefixed
+// with their name and the items are only counted, they have a CSV of their own.
+var ordersCSVHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+	"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+	"delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"items_count",
+}
+
+// orderCSVRecord flattens the order into a row matching ordersCSVHeader.
+func orderCSVRecord(o *domain.Order) []string {
+	return []string{
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+		o.DeliveryService, o.ShardKey, strconv.Itoa(o.SmID), o.DateCreated.Format(time.RFC3339), o.OofShard,
+		o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City, o.Delivery.Address,
+		o.Delivery.Region, o.Delivery.Email,
+		o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
+		strconv.Itoa(o.Payment.Amount), strconv.FormatInt(o.Payment.PaymentDt, 10), o.Payment.Bank,
+		strconv.Itoa(o.Payment.DeliveryCost), strconv.Itoa(o.Payment.GoodsTotal), strconv.Itoa(o.Payment.CustomFee),
+		strconv.Itoa(len(o.Items)),
+	}
+}
+
+// csvWriter writes the header before the first row, or on Close if there are no rows.
+type csvWriter struct {
+	cw      *csv.Writer
+	header  []string
+	started bool
+	records func(o *domain.Order) [][]string
+}
+
+func newOrdersCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{cw: csv.NewWriter(w), header: ordersCSVHeader, records: func(o *domain.Order) [][]string {
+		return [][]string{orderCSVRecord(o)}
+	}}
+}
+
+func newItemsCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{cw: csv.NewWriter(w), header: ItemsCSVHeader, records: func(o *domain.Order) [][]string {
+		rows := make([][]string, len(o.Items))
+		for i, it := range o.Items {
+			rows[i] = ItemCSVRecord(o.OrderUID, it)
+		}
+		return rows
+	}}
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	return w.cw.Write(w.header)
+}
+
+func (w *csvWriter) Write(o *domain.Order) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	// the csv.Writer buffers, the errors of the underlying writer show up a few rows later
+	for _, rec := range w.records(o) {
+		if err := w.cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.cw.Flush()
+	return w.cw.Error()
+}
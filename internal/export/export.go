@@ -0,0 +1,124 @@
+// Package export streams orders out of the store as CSV, NDJSON or Parquet. The orders are
+// read through a server-side cursor and written as they come, so the memory used is bounded
+// by the fetch size (and a Parquet row group), whatever the size of the result.
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/store"
+)
+
+// Format is the file format of an export.
+type Format string
+
+const (
+	FormatCSV      Format = "csv"       // an order per row, delivery and payment flattened into columns
+	FormatCSVItems Format = "csv_items" // an item per row, prefixed by its order uid
+	FormatNDJSON   Format = "ndjson"    // an order per line, as the JSON API returns it
+	FormatParquet  Format = "parquet"   // an order per row, the items a repeated group
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatCSV, FormatCSVItems, FormatNDJSON, FormatParquet}
+
+// ParseFormat parses the name of a format.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %q, expected one of: csv, csv_items, ndjson, parquet", name)
+}
+
+// ContentType is the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV, FormatCSVItems:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// Extension is the file extension of the format.
+func (f Format) Extension() string {
+	switch f {
+	case FormatCSV, FormatCSVItems:
+		return ".csv"
+	case FormatNDJSON:
+		return ".ndjson"
+	default:
+		return ".parquet"
+	}
+}
+
+// Source streams the orders matching the filter to fn, oldest first. The *store.DBStore implements it.
+type Source interface {
+	ExportOrders(ctx context.Context, filter store.OrderFilter, fn func(*domain.Order) error) error
+}
+
+// Writer writes the orders of an export one at a time. Nothing is complete until Close.
+type Writer interface {
+	Write(*domain.Order) error
+	Close() error
+}
+
+// Options tune an export.
+type Options struct {
+	// Transform is applied to every order before it's written, nil leaves them as they are.
+	// It's where the redaction goes, the orders from the source must not be modified in place.
+	Transform func(*domain.Order) *domain.Order
+	// ParquetRowGroupSize caps the rows buffered before a Parquet row group is written out.
+	ParquetRowGroupSize int
+}
+
+// NewWriter creates the Writer of the format writing to w.
+func NewWriter(format Format, w io.Writer, opts Options) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newOrdersCSVWriter(w), nil
+	case FormatCSVItems:
+		return newItemsCSVWriter(w), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatParquet:
+		return newParquetWriter(w, opts.ParquetRowGroupSize), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// Run exports the orders matching the filter from the source to w, it returns how many
+// orders were written. On an error the output is incomplete and must be discarded.
+func Run(ctx context.Context, src Source, filter store.OrderFilter, format Format, w io.Writer, opts Options) (int, error) {
+	ew, err := NewWriter(format, w, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	err = src.ExportOrders(ctx, filter, func(o *domain.Order) error {
+		if opts.Transform != nil {
+			o = opts.Transform(o)
+		}
+		if err := ew.Write(o); err != nil {
+			return fmt.Errorf("writing order %s: %w", o.OrderUID, err)
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	if err := ew.Close(); err != nil {
+		return n, fmt.Errorf("finishing the export: %w", err)
+	}
+	return n, nil
+}
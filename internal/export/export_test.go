@@ -0,0 +1,182 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource hands out its orders, failing after failAfter of them if it's set.
+type fakeSource struct {
+	orders    []*domain.Order
+	failAfter int
+	filter    store.OrderFilter
+}
+
+func (f *fakeSource) ExportOrders(ctx context.Context, filter store.OrderFilter, fn func(*domain.Order) error) error {
+	f.filter = filter
+	for i, o := range f.orders {
+		if f.failAfter > 0 && i == f.failAfter {
+			return store.ErrConnectionFailed
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testOrder(uid string, items int) *domain.Order {
+	o := &domain.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Delivery:        domain.Delivery{Name: "Test Testov", City: "Kiryat Mozkin", Email: "test@gmail.com"},
+		Payment:         domain.Payment{Transaction: uid, Currency: "USD", Amount: 1817},
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+	for i := range items {
+		o.Items = append(o.Items, domain.Item{ChrtID: 9934930 + i, Name: "Mascaras", Price: 453})
+	}
+	return o
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range Formats {
+		got, err := ParseFormat(string(f))
+		assert.NoError(t, err)
+		assert.Equal(t, f, got)
+	}
+
+	_, err := ParseFormat("xlsx")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	src := &fakeSource{orders: []*domain.Order{testOrder("one", 2), testOrder("two", 1)}}
+	filter := store.OrderFilter{CustomerID: "test"}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := Run(context.Background(), src, filter, FormatCSV, &buf, Options{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, filter, src.filter)
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, ordersCSVHeader, records[0])
+		assert.Equal(t, "one", records[1][0])
+		assert.Equal(t, "Kiryat Mozkin", records[1][14])    // delivery_city
+		assert.Equal(t, "2", records[1][len(records[1])-1]) // items_count
+		assert.Equal(t, "two", records[2][0])
+	})
+
+	t.Run("csv items", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := Run(context.Background(), src, filter, FormatCSVItems, &buf, Options{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		assert.Equal(t, ItemsCSVHeader, records[0])
+		assert.Equal(t, []string{"one", "one", "two"}, []string{records[1][0], records[2][0], records[3][0]})
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := Run(context.Background(), src, filter, FormatNDJSON, &buf, Options{})
+		require.NoError(t, err)
+
+		var uids []string
+		sc := bufio.NewScanner(&buf)
+		for sc.Scan() {
+			var o domain.Order
+			require.NoError(t, json.Unmarshal(sc.Bytes(), &o))
+			uids = append(uids, o.OrderUID)
+		}
+		assert.Equal(t, []string{"one", "two"}, uids)
+	})
+
+	t.Run("an empty csv still has its header", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := Run(context.Background(), &fakeSource{}, filter, FormatCSV, &buf, Options{})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		assert.Equal(t, strings.Join(ordersCSVHeader, ",")+"\n", buf.String())
+	})
+
+	t.Run("transform", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := Run(context.Background(), src, filter, FormatNDJSON, &buf, Options{
+			Transform: func(o *domain.Order) *domain.Order {
+				c := *o
+				c.Delivery.Email = "***"
+				return &c
+			},
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, buf.String(), "test@gmail.com")
+		assert.Equal(t, "test@gmail.com", src.orders[0].Delivery.Email)
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		failing := &fakeSource{orders: src.orders, failAfter: 1}
+		n, err := Run(context.Background(), failing, filter, FormatCSV, new(bytes.Buffer), Options{})
+		assert.ErrorIs(t, err, store.ErrConnectionFailed)
+		assert.Equal(t, 1, n)
+	})
+}
+
+func TestRun_parquet(t *testing.T) {
+	orders := make([]*domain.Order, 25)
+	for i := range orders {
+		orders[i] = testOrder(fmt.Sprintf("order%02d", i), i%3)
+	}
+
+	var buf bytes.Buffer
+	n, err := Run(context.Background(), &fakeSource{orders: orders}, store.OrderFilter{}, FormatParquet, &buf, Options{ParquetRowGroupSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 25, n)
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.EqualValues(t, 25, f.NumRows())
+	assert.Len(t, f.RowGroups(), 3) // capped at 10 rows each
+
+	rows, err := parquet.Read[parquetOrder](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, rows, 25)
+	assert.Equal(t, "order04", rows[4].OrderUID)
+	assert.Len(t, rows[4].Items, 1)
+	assert.Equal(t, "USD", rows[4].Currency)
+	assert.True(t, orders[4].DateCreated.Equal(rows[4].DateCreated))
+}
+
+func TestRun_writeError(t *testing.T) {
+	src := &fakeSource{orders: []*domain.Order{testOrder("one", 1)}}
+	_, err := Run(context.Background(), src, store.OrderFilter{}, FormatNDJSON, failingWriter{}, Options{})
+	assert.Error(t, err)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
@@ -0,0 +1,28 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+)
+
+// ndjsonWriter writes an order per line.
+type ndjsonWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	bw := bufio.NewWriter(w)
+	return &ndjsonWriter{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+func (w *ndjsonWriter) Write(o *domain.Order) error {
+	return w.enc.Encode(o)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.bw.Flush()
+}
@@ -0,0 +1,138 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// defaultParquetRowGroupSize is the fallback for a zero Options.ParquetRowGroupSize
+const defaultParquetRowGroupSize = 10_000
+
+// parquetOrder is the row of the Parquet file: the order with delivery and payment
+// flattened like in the CSV, and the items kept as a repeated group.
+type parquetOrder struct {
+	OrderUID          string        `parquet:"order_uid"`
+	TrackNumber       string        `parquet:"track_number"`
+	Entry             string        `parquet:"entry"`
+	Locale            string        `parquet:"locale"`
+	InternalSignature string        `parquet:"internal_signature"`
+	CustomerID        string        `parquet:"customer_id"`
+	DeliveryService   string        `parquet:"delivery_service"`
+	ShardKey          string        `parquet:"shardkey"`
+	SmID              int64         `parquet:"sm_id"`
+	DateCreated       time.Time     `parquet:"date_created,timestamp(millisecond)"`
+	OofShard          string        `parquet:"oof_shard"`
+	DeliveryName      string        `parquet:"delivery_name"`
+	DeliveryPhone     string        `parquet:"delivery_phone"`
+	DeliveryZip       string        `parquet:"delivery_zip"`
+	DeliveryCity      string        `parquet:"delivery_city"`
+	DeliveryAddress   string        `parquet:"delivery_address"`
+	DeliveryRegion    string        `parquet:"delivery_region"`
+	DeliveryEmail     string        `parquet:"delivery_email"`
+	Transaction       string        `parquet:"payment_transaction"`
+	RequestID         string        `parquet:"payment_request_id"`
+	Currency          string        `parquet:"payment_currency"`
+	Provider          string        `parquet:"payment_provider"`
+	Amount            int64         `parquet:"payment_amount"`
+	PaymentDt         int64         `parquet:"payment_dt"`
+	Bank              string        `parquet:"payment_bank"`
+	DeliveryCost      int64         `parquet:"payment_delivery_cost"`
+	GoodsTotal        int64         `parquet:"payment_goods_total"`
+	CustomFee         int64         `parquet:"payment_custom_fee"`
+	Items             []parquetItem `parquet:"items"`
+}
+
+type parquetItem struct {
+	ChrtID      int64  `parquet:"chrt_id"`
+	TrackNumber string `parquet:"track_number"`
+	Price       int64  `parquet:"price"`
+	Rid         string `parquet:"rid"`
+	Name        string `parquet:"name"`
+	Sale        int64  `parquet:"sale"`
+	Size        string `parquet:"size"`
+	TotalPrice  int64  `parquet:"total_price"`
+	NmID        int64  `parquet:"nm_id"`
+	Brand       string `parquet:"brand"`
+	Status      int64  `parquet:"status"`
+}
+
+func newParquetOrder(o *domain.Order) parquetOrder {
+	items := make([]parquetItem, len(o.Items))
+	for i, it := range o.Items {
+		items[i] = parquetItem{
+			ChrtID:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmID:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		}
+	}
+
+	return parquetOrder{
+		OrderUID:          o.OrderUID,
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		ShardKey:          o.ShardKey,
+		SmID:              int64(o.SmID),
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+		DeliveryName:      o.Delivery.Name,
+		DeliveryPhone:     o.Delivery.Phone,
+		DeliveryZip:       o.Delivery.Zip,
+		DeliveryCity:      o.Delivery.City,
+		DeliveryAddress:   o.Delivery.Address,
+		DeliveryRegion:    o.Delivery.Region,
+		DeliveryEmail:     o.Delivery.Email,
+		Transaction:       o.Payment.Transaction,
+		RequestID:         o.Payment.RequestID,
+		Currency:          o.Payment.Currency,
+		Provider:          o.Payment.Provider,
+		Amount:            int64(o.Payment.Amount),
+		PaymentDt:         o.Payment.PaymentDt,
+		Bank:              o.Payment.Bank,
+		DeliveryCost:      int64(o.Payment.DeliveryCost),
+		GoodsTotal:        int64(o.Payment.GoodsTotal),
+		CustomFee:         int64(o.Payment.CustomFee),
+		Items:             items,
+	}
+}
+
+// parquetWriter buffers up to a row group of rows, the footer is written on Close.
+type parquetWriter struct {
+	pw  *parquet.GenericWriter[parquetOrder]
+	row [1]parquetOrder
+}
+
+func newParquetWriter(w io.Writer, rowGroupSize int) *parquetWriter {
+	if rowGroupSize <= 0 {
+		rowGroupSize = defaultParquetRowGroupSize
+	}
+	return &parquetWriter{pw: parquet.NewGenericWriter[parquetOrder](w,
+		parquet.Compression(&parquet.Zstd),
+		parquet.MaxRowsPerRowGroup(int64(rowGroupSize)),
+	)}
+}
+
+func (w *parquetWriter) Write(o *domain.Order) error {
+	w.row[0] = newParquetOrder(o)
+	_, err := w.pw.Write(w.row[:])
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	return w.pw.Close()
+}
@@ -0,0 +1,140 @@
+// Package feed fans the newly stored orders out to the live subscribers (the SSE stream).
+package feed
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+// Event is a published order and its position in the feed.
+type Event struct {
+	ID    uint64
+	Order *domain.Order // shared by every subscriber, never modify it
+}
+
+// Filter selects the orders a subscriber gets, nil passes all of them.
+type Filter func(o *domain.Order) bool
+
+// Broadcaster publishes orders to its subscribers and keeps the last few events, so a
+// subscriber that reconnects can pick up where it left off. It's safe for concurrent use.
+//
+// Publish never blocks: a subscriber whose buffer is full is dropped, its channel closed.
+// It can reconnect and resume from the ring, if it wasn't gone for too long.
+type Broadcaster struct {
+	mu     sync.Mutex
+	lastID uint64
+	ring   []Event // the last events, oldest first once it's full
+	next   int     // where the next event goes in the ring
+	full   bool
+
+	subs       map[*Subscription]struct{}
+	bufferSize int
+}
+
+// Subscription is a subscriber's end of the feed.
+type Subscription struct {
+	C      <-chan Event // closed once the subscriber is dropped or closes
+	ch     chan Event
+	filter Filter
+	b      *Broadcaster
+	closed bool // guarded by b.mu
+}
+
+// NewBroadcaster creates a Broadcaster remembering ringSize events, every subscriber
+// can lag behind by bufferSize events before it's dropped.
+func NewBroadcaster(ringSize, bufferSize int) *Broadcaster {
+	return &Broadcaster{
+		// ids start at the clock, so they keep growing across restarts and a client
+		// resuming with an id from before one is not mistaken for being ahead
+		lastID:     uint64(time.Now().UnixMicro()),
+		ring:       make([]Event, max(1, ringSize)),
+		subs:       make(map[*Subscription]struct{}),
+		bufferSize: max(1, bufferSize),
+	}
+}
+
+// Publish sends the order to every subscriber whose filter it passes.
+func (b *Broadcaster) Publish(o *domain.Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	ev := Event{ID: b.lastID, Order: o}
+	b.ring[b.next] = ev
+	b.next = (b.next + 1) % len(b.ring)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(o) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			// a slow subscriber must not hold up the consumer, nor the other subscribers
+			b.remove(sub)
+			metrics.OrderStreamDroppedTotal.Inc()
+		}
+	}
+}
+
+// Subscribe adds a subscriber. The returned events are the ones after lastID still in the
+// ring (matching the filter), they are to be sent before anything from the channel: there
+// are no gaps and no duplicates between the two. A lastID of 0 means no replay.
+func (b *Broadcaster) Subscribe(lastID uint64, filter Filter) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if lastID != 0 {
+		for _, ev := range b.events() {
+			if ev.ID > lastID && (filter == nil || filter(ev.Order)) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	ch := make(chan Event, b.bufferSize)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, b: b}
+	b.subs[sub] = struct{}{}
+	metrics.OrderStreamSubscribers.Inc()
+	return sub, replay
+}
+
+// Subscribers returns the number of current subscribers.
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close unsubscribes, it's safe to call more than once and after a drop.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+}
+
+// remove drops the subscriber, holding b.mu.
+func (b *Broadcaster) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subs, sub)
+	close(sub.ch)
+	metrics.OrderStreamSubscribers.Dec()
+}
+
+// events returns the ring's events oldest first, holding b.mu.
+func (b *Broadcaster) events() []Event {
+	if !b.full {
+		return b.ring[:b.next]
+	}
+	return append(append([]Event(nil), b.ring[b.next:]...), b.ring[:b.next]...)
+}
@@ -0,0 +1,105 @@
+package feed
+
+import (
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func order(uid, customer string) *domain.Order {
+	return &domain.Order{OrderUID: uid, CustomerID: customer}
+}
+
+func uids(events []Event) []string {
+	var out []string
+	for _, ev := range events {
+		out = append(out, ev.Order.OrderUID)
+	}
+	return out
+}
+
+func receive(t *testing.T, sub *Subscription, n int) []Event {
+	t.Helper()
+	var out []Event
+	for range n {
+		select {
+		case ev, ok := <-sub.C:
+			require.True(t, ok, "channel closed")
+			out = append(out, ev)
+		default:
+			t.Fatalf("expected %d events, got %d", n, len(out))
+		}
+	}
+	return out
+}
+
+func TestBroadcaster_publish(t *testing.T) {
+	b := NewBroadcaster(8, 8)
+
+	all, replay := b.Subscribe(0, nil)
+	assert.Empty(t, replay)
+	byCustomer, _ := b.Subscribe(0, func(o *domain.Order) bool { return o.CustomerID == "c1" })
+
+	b.Publish(order("a", "c1"))
+	b.Publish(order("b", "c2"))
+	b.Publish(order("c", "c1"))
+
+	got := receive(t, all, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, uids(got))
+	assert.Less(t, got[0].ID, got[1].ID)
+	assert.Equal(t, []string{"a", "c"}, uids(receive(t, byCustomer, 2)))
+
+	all.Close()
+	all.Close()
+	_, ok := <-all.C
+	assert.False(t, ok)
+}
+
+func TestBroadcaster_resume(t *testing.T) {
+	b := NewBroadcaster(3, 8)
+
+	sub, _ := b.Subscribe(0, nil)
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		b.Publish(order(uid, "c1"))
+	}
+	seen := receive(t, sub, 4)
+	sub.Close()
+
+	// resumes after "b": "c" and "d" are still in the ring
+	resumed, replay := b.Subscribe(seen[1].ID, nil)
+	assert.Equal(t, []string{"c", "d"}, uids(replay))
+
+	// no gap between the replay and the channel
+	b.Publish(order("e", "c1"))
+	assert.Equal(t, []string{"e"}, uids(receive(t, resumed, 1)))
+
+	// gone too long: "a" fell out of the ring, the rest is replayed
+	_, replay = b.Subscribe(seen[0].ID-1, nil)
+	assert.Equal(t, []string{"c", "d", "e"}, uids(replay))
+
+	// the filter applies to the replay too
+	_, replay = b.Subscribe(seen[0].ID, func(o *domain.Order) bool { return o.OrderUID == "d" })
+	assert.Equal(t, []string{"d"}, uids(replay))
+}
+
+func TestBroadcaster_dropsSlowSubscribers(t *testing.T) {
+	b := NewBroadcaster(8, 2)
+
+	slow, _ := b.Subscribe(0, nil)
+	fast, _ := b.Subscribe(0, nil)
+
+	b.Publish(order("a", "c1"))
+	b.Publish(order("b", "c1"))
+	receive(t, fast, 2)
+	b.Publish(order("c", "c1")) // slow's buffer is full
+
+	assert.Equal(t, []string{"a", "b"}, uids(receive(t, slow, 2)))
+	_, ok := <-slow.C
+	assert.False(t, ok, "slow subscriber should be dropped")
+
+	assert.Equal(t, []string{"c"}, uids(receive(t, fast, 1)))
+	slow.Close() // after a drop it's a no-op
+}
@@ -0,0 +1,113 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicServices can be called without credentials, the probes and the tools need them.
+var publicServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// correlationMetadata are the metadata keys a caller's correlation id is taken from.
+var correlationMetadata = []string{"x-request-id", "x-correlation-id"}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	ctx, err = s.prepare(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer s.recoverPanic(ctx, info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx, err := s.prepare(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer s.recoverPanic(ctx, info.FullMethod, &err)
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream replaces the context of a stream with the prepared one.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+// prepare puts the correlation id and the caller's identity into the ctx, just like the
+// HTTP middlewares do. The callers without (valid) credentials are turned away.
+func (s *Server) prepare(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := ""
+	for _, key := range correlationMetadata {
+		if v := md.Get(key); len(v) > 0 && transport.ValidCorrelationID(v[0]) {
+			id = v[0]
+			break
+		}
+	}
+	if id == "" {
+		id = logger.NewCorrelationID()
+	}
+	ctx = logger.WithCorrelationID(ctx, id)
+
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, nil
+		}
+	}
+
+	if s.auth == nil {
+		return auth.WithIdentity(ctx, s.anonymous), nil
+	}
+
+	// the authenticator reads the credentials off the headers, the metadata holds the same
+	header := http.Header{}
+	for _, key := range []string{"x-api-key", "authorization"} {
+		for _, v := range md.Get(key) {
+			header.Add(key, v)
+		}
+	}
+	identity, err := s.auth.Authenticate(&http.Request{Header: header})
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			logger.FromContext(ctx, s.logger).Warnw("request denied", "method", method, "reason", err.Error())
+		}
+		return nil, status.Error(codes.Unauthenticated, "valid credentials are required")
+	}
+	// every method reads orders
+	if !identity.Role.Allows(auth.RoleViewer) {
+		return nil, status.Error(codes.PermissionDenied, "the viewer role is required")
+	}
+	return auth.WithIdentity(ctx, identity), nil
+}
+
+// recoverPanic turns a panic of the handler into an INTERNAL error, to be deferred.
+func (s *Server) recoverPanic(ctx context.Context, method string, err *error) {
+	if rec := recover(); rec != nil {
+		logger.FromContext(ctx, s.logger).Errorw("server encountered panic",
+			"panic", rec,
+			"stack", string(debug.Stack()),
+			"method", method,
+		)
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,186 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/feed"
+	"github.com/goinginblind/l0-task/internal/grpcapi/ordersv1"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchGet caps the uids of a single BatchGetOrders.
+const maxBatchGet = 100
+
+// GetOrder implements ordersv1.OrderServiceServer.
+func (s *Server) GetOrder(ctx context.Context, req *ordersv1.GetOrderRequest) (*ordersv1.Order, error) {
+	if !transport.ValidUID(req.GetOrderUid()) {
+		return nil, status.Error(codes.InvalidArgument, "order_uid must be a non-empty alphanumeric string")
+	}
+
+	order, err := s.getOrder(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, status.Error(codes.NotFound, store.ErrNotFound.Error())
+	}
+	return toProto(s.redactor.ForCaller(ctx, order)), nil
+}
+
+// BatchGetOrders implements ordersv1.OrderServiceServer.
+func (s *Server) BatchGetOrders(ctx context.Context, req *ordersv1.BatchGetOrdersRequest) (*ordersv1.BatchGetOrdersResponse, error) {
+	uids := req.GetOrderUids()
+	if len(uids) > maxBatchGet {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d order_uids can be requested at once", maxBatchGet)
+	}
+
+	unique := make([]string, 0, len(uids))
+	seen := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		if !transport.ValidUID(uid) {
+			return nil, status.Errorf(codes.InvalidArgument, "order_uid %q must be a non-empty alphanumeric string", uid)
+		}
+		if !seen[uid] {
+			seen[uid] = true
+			unique = append(unique, uid)
+		}
+	}
+
+	orders, err := s.service.GetOrders(ctx, unique)
+	if err != nil {
+		return nil, s.storeError(ctx, err)
+	}
+
+	resp := &ordersv1.BatchGetOrdersResponse{}
+	for i, order := range orders {
+		if order == nil {
+			resp.MissingOrderUids = append(resp.MissingOrderUids, unique[i])
+			continue
+		}
+		resp.Orders = append(resp.Orders, toProto(s.redactor.ForCaller(ctx, order)))
+	}
+	return resp, nil
+}
+
+// getOrder is service.GetOrder with a not found order reported as a nil one
+// and the other errors mapped onto the status codes.
+func (s *Server) getOrder(ctx context.Context, uid string) (*domain.Order, error) {
+	order, err := s.service.GetOrder(ctx, uid)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, s.storeError(ctx, err)
+	}
+	return order, nil
+}
+
+// ListOrders implements ordersv1.OrderServiceServer.
+func (s *Server) ListOrders(ctx context.Context, req *ordersv1.ListOrdersRequest) (*ordersv1.ListOrdersResponse, error) {
+	if req.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+
+	q := store.ListQuery{
+		OrderFilter: store.OrderFilter{
+			CustomerID:      req.GetCustomerId(),
+			DeliveryService: req.GetDeliveryService(),
+			Locale:          req.GetLocale(),
+			Currency:        req.GetCurrency(),
+			Provider:        req.GetProvider(),
+		},
+		Cursor: req.GetPageToken(),
+		Limit:  int(req.GetPageSize()),
+	}
+	if field := transport.HiddenFilter(ctx, s.redactor, q.OrderFilter); field != "" {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is redacted for your role, the orders can't be filtered by it", field)
+	}
+	if req.GetCreatedFrom() != nil {
+		q.CreatedFrom = req.GetCreatedFrom().AsTime()
+	}
+	if req.GetCreatedTo() != nil {
+		q.CreatedTo = req.GetCreatedTo().AsTime()
+	}
+
+	page, err := s.service.ListOrders(ctx, q)
+	if err != nil {
+		return nil, s.storeError(ctx, err)
+	}
+
+	resp := &ordersv1.ListOrdersResponse{
+		Orders:        make([]*ordersv1.Order, len(page.Orders)),
+		NextPageToken: page.NextCursor,
+	}
+	for i, o := range s.redactor.AllForCaller(ctx, page.Orders) {
+		resp.Orders[i] = toProto(o)
+	}
+	return resp, nil
+}
+
+// WatchOrders implements ordersv1.OrderServiceServer. A caller that falls behind gets
+// RESOURCE_EXHAUSTED, it can resume with the id of the last event it got.
+func (s *Server) WatchOrders(req *ordersv1.WatchOrdersRequest, stream grpc.ServerStreamingServer[ordersv1.OrderEvent]) error {
+	if s.feed == nil {
+		return status.Error(codes.Unimplemented, "the order stream is not enabled")
+	}
+
+	customerID, deliveryService := req.GetCustomerId(), req.GetDeliveryService()
+	if field := transport.HiddenFilter(stream.Context(), s.redactor, store.OrderFilter{CustomerID: customerID, DeliveryService: deliveryService}); field != "" {
+		return status.Errorf(codes.PermissionDenied, "%s is redacted for your role, the orders can't be filtered by it", field)
+	}
+	var filter feed.Filter
+	if customerID != "" || deliveryService != "" {
+		filter = func(o *domain.Order) bool {
+			return (customerID == "" || o.CustomerID == customerID) &&
+				(deliveryService == "" || o.DeliveryService == deliveryService)
+		}
+	}
+
+	sub, replay := s.feed.Subscribe(req.GetAfterEventId(), filter)
+	defer sub.Close()
+
+	ctx := stream.Context()
+	send := func(ev feed.Event) error {
+		return stream.Send(&ordersv1.OrderEvent{EventId: ev.ID, Order: toProto(s.redactor.ForCaller(ctx, ev.Order))})
+	}
+
+	for _, ev := range replay {
+		if err := send(ev); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return status.Error(codes.Unavailable, "the server is shutting down")
+		case ev, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "fell behind the order stream, resume from the last event_id")
+			}
+			if err := send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// storeError maps the store sentinel errors onto the status codes (transport.StoreError),
+// anything unknown is logged and reported as INTERNAL.
+func (s *Server) storeError(ctx context.Context, err error) error {
+	if st, ok := transport.StoreError(err); ok {
+		return status.Error(st.GRPC, st.Message)
+	}
+	logger.FromContext(ctx, s.logger).Errorw("server error", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
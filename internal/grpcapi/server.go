@@ -0,0 +1,174 @@
+// Package grpcapi is the gRPC face of the service: orders.v1.OrderService (see proto/orders/v1),
+// the standard health service and reflection, served on a port of their own.
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/feed"
+	"github.com/goinginblind/l0-task/internal/grpcapi/ordersv1"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/health"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
+	"github.com/goinginblind/l0-task/internal/pkg/transport"
+	"github.com/goinginblind/l0-task/internal/service"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// healthSyncInterval is how often the health service picks up the readiness of the registry.
+const healthSyncInterval = time.Second
+
+// Server is the gRPC server.
+type Server struct {
+	ordersv1.UnimplementedOrderServiceServer
+
+	service    service.OrderService
+	logger     logger.Logger
+	grpcServer *grpc.Server
+	health     *grpchealth.Server
+
+	registry  *health.Registry    // nil if always serving
+	auth      *auth.Authenticator // nil if auth is off
+	anonymous auth.Identity       // of every caller while auth is off
+	redactor  *redact.Redactor    // nil if orders go out unredacted
+	feed      *feed.Broadcaster   // nil if WatchOrders is unimplemented
+	done      chan struct{}       // closed on shutdown, ends the watches and the health sync
+}
+
+// Option configures the optional parts of the Server.
+type Option func(*Server)
+
+// WithHealth sets the registry the health service reports from: NOT_SERVING
+// while any critical component is unhealthy, like /readyz.
+func WithHealth(registry *health.Registry) Option {
+	return func(s *Server) {
+		s.registry = registry
+	}
+}
+
+// WithAuth turns authentication on, the credentials are read from the metadata the
+// same way the HTTP server reads them from the headers. Without it every caller is an anonymous viewer.
+func WithAuth(authenticator *auth.Authenticator) Option {
+	return func(s *Server) {
+		s.auth = authenticator
+	}
+}
+
+// WithAnonymousAdmin makes the anonymous callers admins while auth is off, the orders
+// are sent out unredacted then. It has no effect once auth is on.
+func WithAnonymousAdmin() Option {
+	return func(s *Server) {
+		s.anonymous.Role = auth.RoleAdmin
+	}
+}
+
+// WithRedaction sets the redactor applied to every order the server sends out.
+func WithRedaction(redactor *redact.Redactor) Option {
+	return func(s *Server) {
+		s.redactor = redactor
+	}
+}
+
+// WithOrderFeed sets the broadcaster WatchOrders subscribes to.
+func WithOrderFeed(b *feed.Broadcaster) Option {
+	return func(s *Server) {
+		s.feed = b
+	}
+}
+
+// NewServer creates a new Server.
+func NewServer(service service.OrderService, log logger.Logger, cfg config.GRPCServerConfig, opts ...Option) *Server {
+	s := &Server{
+		service:   service,
+		logger:    log,
+		health:    grpchealth.NewServer(),
+		anonymous: transport.Unauthenticated,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.grpcServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	ordersv1.RegisterOrderServiceServer(s.grpcServer, s)
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+	if cfg.Reflection {
+		reflection.Register(s.grpcServer)
+	}
+
+	return s
+}
+
+// Start listens on the addr and serves until Shutdown.
+func (s *Server) Start(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Infow("gRPC server listening", "addr", addr)
+	return s.Serve(lis)
+}
+
+// Serve serves on the listener until Shutdown.
+func (s *Server) Serve(lis net.Listener) error {
+	go s.syncHealth()
+	if err := s.grpcServer.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the server gracefully: the watches are ended and the unary calls in
+// flight are let to finish. If the ctx is done first, the rest are cut off.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.logger.Infow("Shutting down gRPC server...")
+	s.health.Shutdown()
+	close(s.done)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
+
+// syncHealth keeps the serving status of the health service in line with the registry.
+func (s *Server) syncHealth() {
+	ticker := time.NewTicker(healthSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if s.registry != nil && !s.registry.Report().Ready() {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		// ignored once the health server is shut down, it reports NOT_SERVING from then on
+		s.health.SetServingStatus("", status)
+		s.health.SetServingStatus(ordersv1.OrderService_ServiceDesc.ServiceName, status)
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
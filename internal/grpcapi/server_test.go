@@ -0,0 +1,250 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/config"
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/feed"
+	"github.com/goinginblind/l0-task/internal/grpcapi/ordersv1"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeService serves the orders it holds, the rest of service.OrderService is unused here.
+type fakeService struct {
+	orders map[string]*domain.Order
+	page   *store.OrderPage
+	query  store.ListQuery // of the last ListOrders
+}
+
+func (f *fakeService) ProcessNewOrder(context.Context, *domain.Order) error { return nil }
+func (f *fakeService) ProcessNewOrders(_ context.Context, o []*domain.Order) []error {
+	return make([]error, len(o))
+}
+
+func (f *fakeService) GetOrder(_ context.Context, uid string) (*domain.Order, error) {
+	if o, ok := f.orders[uid]; ok {
+		return o, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (f *fakeService) GetOrders(_ context.Context, uids []string) ([]*domain.Order, error) {
+	orders := make([]*domain.Order, len(uids))
+	for i, uid := range uids {
+		orders[i] = f.orders[uid]
+	}
+	return orders, nil
+}
+
+func (f *fakeService) ListOrders(_ context.Context, q store.ListQuery) (*store.OrderPage, error) {
+	f.query = q
+	if f.page == nil {
+		return nil, store.ErrConnectionFailed
+	}
+	return f.page, nil
+}
+
+func testOrder(uid string) *domain.Order {
+	return &domain.Order{
+		OrderUID:        uid,
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Delivery:        domain.Delivery{Name: "Test Testov", Email: "test@gmail.com"},
+		Payment:         domain.Payment{Transaction: uid, Amount: 1817},
+		Items:           []domain.Item{{ChrtID: 9934930, Name: "Mascaras"}},
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+// startServer serves s over an in-memory listener and returns a client connection to it.
+func startServer(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(func() { s.grpcServer.Stop() })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer_orders(t *testing.T) {
+	svc := &fakeService{
+		orders: map[string]*domain.Order{"one": testOrder("one"), "two": testOrder("two")},
+		page:   &store.OrderPage{Orders: []*domain.Order{testOrder("two"), testOrder("one")}, NextCursor: "next"},
+	}
+	client := ordersv1.NewOrderServiceClient(startServer(t, NewServer(svc, logger.NewMockLogger(), config.GRPCServerConfig{})))
+	ctx := context.Background()
+
+	t.Run("get", func(t *testing.T) {
+		got, err := client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "one"})
+		require.NoError(t, err)
+		assert.Equal(t, "one", got.GetOrderUid())
+		assert.Equal(t, int64(1817), got.GetPayment().GetAmount())
+		assert.Equal(t, int64(9934930), got.GetItems()[0].GetChrtId())
+		assert.True(t, got.GetDateCreated().AsTime().Equal(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)))
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		_, err := client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "nope"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("get malformed uid", func(t *testing.T) {
+		_, err := client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "../etc"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("batch get", func(t *testing.T) {
+		got, err := client.BatchGetOrders(ctx, &ordersv1.BatchGetOrdersRequest{OrderUids: []string{"two", "nope", "one", "two"}})
+		require.NoError(t, err)
+		require.Len(t, got.GetOrders(), 2)
+		assert.Equal(t, "two", got.GetOrders()[0].GetOrderUid())
+		assert.Equal(t, "one", got.GetOrders()[1].GetOrderUid())
+		assert.Equal(t, []string{"nope"}, got.GetMissingOrderUids())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		got, err := client.ListOrders(ctx, &ordersv1.ListOrdersRequest{PageSize: 2, PageToken: "prev", DeliveryService: "meest"})
+		require.NoError(t, err)
+		assert.Len(t, got.GetOrders(), 2)
+		assert.Equal(t, "next", got.GetNextPageToken())
+		assert.Equal(t, store.ListQuery{OrderFilter: store.OrderFilter{DeliveryService: "meest"}, Cursor: "prev", Limit: 2}, svc.query)
+	})
+
+	t.Run("list with the db down", func(t *testing.T) {
+		svc.page = nil
+		_, err := client.ListOrders(ctx, &ordersv1.ListOrdersRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("watch without a feed", func(t *testing.T) {
+		stream, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+}
+
+func TestServer_WatchOrders(t *testing.T) {
+	b := feed.NewBroadcaster(16, 16)
+	s := NewServer(&fakeService{}, logger.NewMockLogger(), config.GRPCServerConfig{}, WithOrderFeed(b))
+	client := ordersv1.NewOrderServiceClient(startServer(t, s))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{DeliveryService: "meest"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return b.Subscribers() == 1 }, time.Second, 5*time.Millisecond)
+
+	skipped := testOrder("skipped")
+	skipped.DeliveryService = "dhl"
+	b.Publish(skipped)
+	b.Publish(testOrder("watched"))
+
+	ev, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "watched", ev.GetOrder().GetOrderUid())
+
+	// a resumed watch gets what it missed first
+	resumed, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{AfterEventId: ev.GetEventId() - 1})
+	require.NoError(t, err)
+	ev, err = resumed.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "watched", ev.GetOrder().GetOrderUid())
+
+	// and the watches end on shutdown
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	require.NoError(t, s.Shutdown(shutdownCtx))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestServer_auth(t *testing.T) {
+	authenticator, err := auth.New([]auth.APIKey{{Name: "dashboard", Key: "viewer-key", Role: auth.RoleViewer}}, nil, "")
+	require.NoError(t, err)
+	redactor, err := redact.New(redact.DefaultRules, []byte("a-test-hash-key-of-32-bytes-long"))
+	require.NoError(t, err)
+
+	svc := &fakeService{orders: map[string]*domain.Order{"one": testOrder("one")}, page: &store.OrderPage{}}
+	conn := startServer(t, NewServer(svc, logger.NewMockLogger(), config.GRPCServerConfig{},
+		WithAuth(authenticator), WithRedaction(redactor), WithOrderFeed(feed.NewBroadcaster(16, 16))))
+	client := ordersv1.NewOrderServiceClient(conn)
+
+	t.Run("without credentials", func(t *testing.T) {
+		_, err := client.GetOrder(context.Background(), &ordersv1.GetOrderRequest{OrderUid: "one"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("with a bad key", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "guess")
+		_, err := client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "one"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("as viewer, redacted", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "viewer-key")
+		got, err := client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "one"})
+		require.NoError(t, err)
+		assert.NotEqual(t, "test@gmail.com", got.GetDelivery().GetEmail())
+		assert.Equal(t, "test@gmail.com", svc.orders["one"].Delivery.Email, "the service's order must stay intact")
+	})
+
+	t.Run("as viewer, no filtering by a redacted field", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "viewer-key")
+		_, err := client.ListOrders(ctx, &ordersv1.ListOrdersRequest{CustomerId: "test"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		_, err = client.ListOrders(ctx, &ordersv1.ListOrdersRequest{DeliveryService: "meest"})
+		assert.NoError(t, err)
+
+		stream, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{CustomerId: "test"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("health is public", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			return err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestServer_authDisabled(t *testing.T) {
+	redactor, err := redact.New(redact.DefaultRules, []byte("a-test-hash-key-of-32-bytes-long"))
+	require.NoError(t, err)
+	svc := &fakeService{orders: map[string]*domain.Order{"one": testOrder("one")}}
+
+	getEmail := func(opts ...Option) string {
+		conn := startServer(t, NewServer(svc, logger.NewMockLogger(), config.GRPCServerConfig{}, append(opts, WithRedaction(redactor))...))
+		got, err := ordersv1.NewOrderServiceClient(conn).GetOrder(context.Background(), &ordersv1.GetOrderRequest{OrderUid: "one"})
+		require.NoError(t, err)
+		return got.GetDelivery().GetEmail()
+	}
+
+	assert.NotEqual(t, "test@gmail.com", getEmail(), "anonymous callers are viewers")
+	assert.Equal(t, "test@gmail.com", getEmail(WithAnonymousAdmin()))
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoCredentials is returned for a request that carries neither an api key nor a token.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrUnknownKey is returned for an api key that isn't configured.
+	ErrUnknownKey = errors.New("unknown api key")
+)
+
+// minSecretLen is the shortest accepted JWT secret, HS256 is only as strong as its key.
+const minSecretLen = 32
+
+// APIKey is a static key and who it belongs to.
+type APIKey struct {
+	Name string // identifies the caller in the logs
+	Key  string
+	Role Role
+}
+
+// Authenticator establishes the identity of a request from its credentials:
+//   - an api key in 'X-API-Key', or as the password of basic auth (that's what a browser can send)
+//   - a HS256 JWT in 'Authorization: Bearer', signed with the local secret
+type Authenticator struct {
+	keys      map[[sha256.Size]byte]Identity // by the hash of the key, lookups don't leak its prefix through timing
+	jwtSecret []byte                         // nil if tokens are not accepted
+	jwtIssuer string                         // the expected 'iss', any if empty
+
+	now func() time.Time
+}
+
+// New creates an Authenticator. It fails on a misconfiguration, like a reused key or a
+// short secret, instead of starting with auth that's weaker than it seems.
+func New(keys []APIKey, jwtSecret []byte, jwtIssuer string) (*Authenticator, error) {
+	a := &Authenticator{
+		keys:      make(map[[sha256.Size]byte]Identity, len(keys)),
+		jwtIssuer: jwtIssuer,
+		now:       time.Now,
+	}
+
+	for i, k := range keys {
+		if k.Name == "" || k.Key == "" {
+			return nil, fmt.Errorf("api key #%d: name and key are required", i)
+		}
+		if k.Role == RoleNone {
+			return nil, fmt.Errorf("api key %q: no role", k.Name)
+		}
+		hash := sha256.Sum256([]byte(k.Key))
+		if _, dup := a.keys[hash]; dup {
+			return nil, fmt.Errorf("api key %q: the key is used twice", k.Name)
+		}
+		a.keys[hash] = Identity{Subject: k.Name, Role: k.Role, Method: MethodAPIKey}
+	}
+
+	if len(jwtSecret) > 0 {
+		if len(jwtSecret) < minSecretLen {
+			return nil, fmt.Errorf("jwt secret must be at least %d bytes", minSecretLen)
+		}
+		a.jwtSecret = jwtSecret
+	}
+
+	if len(a.keys) == 0 && a.jwtSecret == nil {
+		return nil, errors.New("no api keys and no jwt secret, nobody could authenticate")
+	}
+	return a, nil
+}
+
+// Authenticate returns the identity of the request's caller. It's ErrNoCredentials
+// if there are none at all, anything else means the credentials are bad.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.byKey(key)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if token, ok := cutPrefixFold(authorization, "Bearer "); ok {
+		return a.byToken(strings.TrimSpace(token))
+	}
+	if _, key, ok := r.BasicAuth(); ok {
+		return a.byKey(key)
+	}
+	if authorization != "" {
+		return Anonymous, errors.New("unsupported authorization scheme")
+	}
+	return Anonymous, ErrNoCredentials
+}
+
+func (a *Authenticator) byKey(key string) (Identity, error) {
+	id, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Anonymous, ErrUnknownKey
+	}
+	return id, nil
+}
+
+func (a *Authenticator) byToken(token string) (Identity, error) {
+	if a.jwtSecret == nil {
+		return Anonymous, fmt.Errorf("%w: tokens are not accepted", ErrInvalidToken)
+	}
+
+	claims, err := verifyToken(token, a.jwtSecret, a.jwtIssuer, a.now())
+	if err != nil {
+		return Anonymous, err
+	}
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return Anonymous, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return Identity{Subject: claims.Subject, Role: role, Method: MethodJWT}, nil
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
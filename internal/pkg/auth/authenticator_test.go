@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name    string
+		keys    []APIKey
+		secret  []byte
+		wantErr string
+	}{
+		{name: "keys only", keys: []APIKey{{Name: "ops", Key: "k1", Role: RoleAdmin}}},
+		{name: "jwt only", secret: testSecret},
+		{name: "nothing", wantErr: "nobody could authenticate"},
+		{name: "short secret", secret: []byte("short"), wantErr: "at least 32 bytes"},
+		{name: "no role", keys: []APIKey{{Name: "ops", Key: "k1"}}, wantErr: "no role"},
+		{name: "no key", keys: []APIKey{{Name: "ops", Role: RoleAdmin}}, wantErr: "name and key are required"},
+		{
+			name:    "reused key",
+			keys:    []APIKey{{Name: "a", Key: "k1", Role: RoleViewer}, {Name: "b", Key: "k1", Role: RoleAdmin}},
+			wantErr: "used twice",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.keys, tc.secret, "")
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	a, err := New([]APIKey{
+		{Name: "dashboard", Key: "viewer-key", Role: RoleViewer},
+		{Name: "ops", Key: "admin-key", Role: RoleAdmin},
+	}, testSecret, "")
+	require.NoError(t, err)
+
+	token, err := NewToken(Claims{Subject: "billing", Role: "support", ExpiresAt: time.Now().Add(time.Hour).Unix()}, testSecret)
+	require.NoError(t, err)
+	badRole, err := NewToken(Claims{Subject: "billing", Role: "root", ExpiresAt: time.Now().Add(time.Hour).Unix()}, testSecret)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+		basic   [2]string
+		want    Identity
+		wantErr error
+	}{
+		{
+			name:    "api key header",
+			headers: map[string]string{"X-API-Key": "admin-key"},
+			want:    Identity{Subject: "ops", Role: RoleAdmin, Method: MethodAPIKey},
+		},
+		{
+			name:  "api key as the basic auth password",
+			basic: [2]string{"whoever", "viewer-key"},
+			want:  Identity{Subject: "dashboard", Role: RoleViewer, Method: MethodAPIKey},
+		},
+		{
+			name:    "bearer token",
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			want:    Identity{Subject: "billing", Role: RoleSupport, Method: MethodJWT},
+		},
+		{
+			name:    "unknown key",
+			headers: map[string]string{"X-API-Key": "guess"},
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "token with an unknown role",
+			headers: map[string]string{"Authorization": "bearer " + badRole},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "nothing",
+			wantErr: ErrNoCredentials,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			if tc.basic[1] != "" {
+				req.SetBasicAuth(tc.basic[0], tc.basic[1])
+			}
+
+			id, err := a.Authenticate(req)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Equal(t, Anonymous, id)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, id)
+		})
+	}
+
+	t.Run("no tokens without a secret", func(t *testing.T) {
+		keysOnly, err := New([]APIKey{{Name: "ops", Key: "admin-key", Role: RoleAdmin}}, nil, "")
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, err = keysOnly.Authenticate(req)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestRole(t *testing.T) {
+	assert.True(t, RoleAdmin.Allows(RoleSupport))
+	assert.True(t, RoleViewer.Allows(RoleViewer))
+	assert.False(t, RoleViewer.Allows(RoleSupport))
+	assert.True(t, RoleNone.Allows(RoleNone))
+
+	for _, r := range []Role{RoleViewer, RoleSupport, RoleAdmin} {
+		parsed, err := ParseRole(r.String())
+		require.NoError(t, err)
+		assert.Equal(t, r, parsed)
+	}
+	_, err := ParseRole("none")
+	assert.Error(t, err)
+
+	ctx := WithIdentity(context.Background(), Identity{Subject: "ops", Role: RoleAdmin})
+	assert.Equal(t, RoleAdmin, FromContext(ctx).Role)
+	assert.Equal(t, Anonymous, FromContext(context.Background()))
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// Role is the access level of a caller, a higher role can do all a lower one can.
+type Role int
+
+const (
+	RoleNone    Role = iota // unauthenticated, public routes only (static files, probes, metrics)
+	RoleViewer              // reads orders
+	RoleSupport             // ... and the operational endpoints
+	RoleAdmin               // ... and writes, admin endpoints
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleSupport:
+		return "support"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// Allows reports whether the role can reach what requires the min role.
+func (r Role) Allows(min Role) bool {
+	return r >= min
+}
+
+// ParseRole parses the name of a role, "none" is not accepted: an identity always has one.
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case "viewer":
+		return RoleViewer, nil
+	case "support":
+		return RoleSupport, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q, expected one of: viewer, support, admin", name)
+}
+
+// Methods an Identity is established with.
+const (
+	MethodAPIKey    = "api_key"
+	MethodJWT       = "jwt"
+	MethodAnonymous = "anonymous"
+)
+
+// Identity is the authenticated caller.
+type Identity struct {
+	Subject string // the name of the api key or the 'sub' of the token
+	Role    Role
+	Method  string
+}
+
+// Anonymous is the identity of a caller without credentials.
+var Anonymous = Identity{Subject: "anonymous", Role: RoleNone, Method: MethodAnonymous}
+
+func (id Identity) String() string {
+	return fmt.Sprintf("%s:%s(%s)", id.Method, id.Subject, id.Role)
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of the ctx carrying the identity.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity the ctx carries, Anonymous if there's none.
+func FromContext(ctx context.Context) Identity {
+	if id, ok := ctx.Value(identityKey{}).(Identity); ok {
+		return id
+	}
+	return Anonymous
+}
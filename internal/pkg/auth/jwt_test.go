@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestVerifyToken(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid := Claims{Subject: "billing", Role: "support", Issuer: "orders", ExpiresAt: now.Add(time.Hour).Unix()}
+
+	mustSign := func(c Claims, secret []byte) string {
+		token, err := NewToken(c, secret)
+		require.NoError(t, err)
+		return token
+	}
+	withClaims := func(modify func(*Claims)) string {
+		c := valid
+		modify(&c)
+		return mustSign(c, testSecret)
+	}
+
+	testCases := []struct {
+		name    string
+		token   string
+		issuer  string
+		wantErr string
+	}{
+		{name: "valid", token: mustSign(valid, testSecret), issuer: "orders"},
+		{name: "any issuer", token: mustSign(valid, testSecret)},
+		{name: "within the clock skew", token: withClaims(func(c *Claims) { c.ExpiresAt = now.Add(-10 * time.Second).Unix() })},
+		{name: "wrong secret", token: mustSign(valid, []byte("another secret, just as long....")), wantErr: "bad signature"},
+		{name: "expired", token: withClaims(func(c *Claims) { c.ExpiresAt = now.Add(-time.Minute).Unix() }), wantErr: "expired"},
+		{name: "no exp", token: withClaims(func(c *Claims) { c.ExpiresAt = 0 }), wantErr: "no exp"},
+		{name: "not valid yet", token: withClaims(func(c *Claims) { c.NotBefore = now.Add(time.Minute).Unix() }), wantErr: "not valid yet"},
+		{name: "wrong issuer", token: mustSign(valid, testSecret), issuer: "someone-else", wantErr: "unexpected issuer"},
+		{name: "no subject", token: withClaims(func(c *Claims) { c.Subject = "" }), wantErr: "no sub"},
+		{name: "garbage", token: "not.a.jwt", wantErr: "header"},
+		{name: "two segments", token: "abc.def", wantErr: "3 segments"},
+		{
+			// the classic: strip the signature and claim there's none needed
+			name:    "alg none",
+			token:   b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + strings.Split(mustSign(valid, testSecret), ".")[1] + ".",
+			wantErr: "unsupported alg",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := verifyToken(tc.token, testSecret, tc.issuer, now)
+			if tc.wantErr != "" {
+				require.ErrorIs(t, err, ErrInvalidToken)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "billing", claims.Subject)
+			assert.Equal(t, "support", claims.Role)
+		})
+	}
+}
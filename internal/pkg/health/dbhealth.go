@@ -2,11 +2,7 @@ package health
 
 import (
 	"context"
-	"sync/atomic"
-	"time"
 
-	"github.com/goinginblind/l0-task/internal/config"
-	"github.com/goinginblind/l0-task/internal/pkg/logger"
 	"github.com/goinginblind/l0-task/internal/pkg/metrics"
 )
 
@@ -15,82 +11,27 @@ type Pinger interface {
 	PingContext(ctx context.Context) error
 }
 
-// DBHealthChecker monitors the health of the database connection.
-type DBHealthChecker struct {
-	pinger        Pinger
-	logger        logger.Logger
-	isHealthy     atomic.Bool
-	checkInterval time.Duration
-	checkTimeout  time.Duration
+// DBChecker checks the database connection by pinging it.
+type DBChecker struct {
+	pinger Pinger
 }
 
-// NewDBHealthChecker creates a new DBHealthChecker. It does not start the monitoring.
-func NewDBHealthChecker(pinger Pinger, logger logger.Logger, cfg config.HealthConfig) *DBHealthChecker {
-	return &DBHealthChecker{
-		pinger:        pinger,
-		logger:        logger,
-		checkInterval: cfg.DBCheckInterval,
-		checkTimeout:  cfg.DBCheckTimeout,
-	}
+// NewDBChecker creates a new DBChecker, register it in a Registry to run it.
+func NewDBChecker(pinger Pinger) *DBChecker {
+	return &DBChecker{pinger: pinger}
 }
 
-// Start begins the continuous health monitoring in a background goroutine.
-// It performs an initial check synchronously to set the initial state.
-func (hc *DBHealthChecker) Start(ctx context.Context) {
-	hc.logger.Infow("Starting DB health checker...")
-	hc.checkHealth(ctx)
-
-	go func() {
-		ticker := time.NewTicker(hc.checkInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				hc.checkHealth(ctx)
-			case <-ctx.Done():
-				hc.logger.Infow("Stopping DB health checker.")
-				return
-			}
-		}
-	}()
+// Name returns the name of the component.
+func (c *DBChecker) Name() string {
+	return "postgres"
 }
 
-// IsHealthy returns the current health status of the database
-func (hc *DBHealthChecker) IsHealthy() bool {
-	return hc.isHealthy.Load()
-}
-
-// MarkUnhealthy allows an external component (like a worker) to
-// flag the connection as unhealthy without waiting for the next scheduled check.
-func (hc *DBHealthChecker) MarkUnhealthy() {
-	// Use CompareAndSwap to only log the first time it's marked unhealthy.
-	if hc.isHealthy.CompareAndSwap(true, false) {
-		hc.logger.Warnw("DB connection proactively marked as unhealthy by a worker.")
-	}
-}
-
-// checkHealth performs a single health check.
-func (hc *DBHealthChecker) checkHealth(ctx context.Context) {
-	pingCtx, cancel := context.WithTimeout(ctx, hc.checkTimeout)
-	defer cancel()
-
-	err := hc.pinger.PingContext(pingCtx)
-	wasHealthy := hc.isHealthy.Load()
-
-	if err != nil {
-		if wasHealthy {
-			hc.logger.Errorw("Database connection lost", "error", err)
-			hc.isHealthy.Store(false)
-		}
+// Check pings the database.
+func (c *DBChecker) Check(ctx context.Context) error {
+	if err := c.pinger.PingContext(ctx); err != nil {
 		metrics.DBUptime.Set(0)
-		return
+		return err
 	}
-
-	if !wasHealthy {
-		hc.logger.Infow("Database connection online")
-		hc.isHealthy.Store(true)
-	}
-
 	metrics.DBUptime.Set(1)
+	return nil
 }
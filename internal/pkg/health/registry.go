@@ -0,0 +1,260 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+// Checker checks the health of a single component, a nil error means it's healthy.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// NewChecker wraps a check function into a Checker.
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return &funcChecker{name: name, check: check}
+}
+
+type funcChecker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c *funcChecker) Name() string                    { return c.name }
+func (c *funcChecker) Check(ctx context.Context) error { return c.check(ctx) }
+
+// CheckOptions control how a registered Checker is run.
+type CheckOptions struct {
+	Interval time.Duration // time between two checks
+	Timeout  time.Duration // a check that runs longer than that fails
+	Critical bool          // an unhealthy critical component takes the whole service down
+}
+
+// defaultCheckInterval is used when CheckOptions.Interval is not set
+const defaultCheckInterval = 5 * time.Second
+
+// Status is the aggregate health of all the registered components.
+type Status string
+
+const (
+	StatusOK       Status = "ok"       // everything is healthy
+	StatusDegraded Status = "degraded" // only non-critical components are unhealthy
+	StatusDown     Status = "down"     // a critical component is unhealthy
+)
+
+// Registry runs the registered Checkers, each on its own interval, and
+// keeps the latest state of every component.
+type Registry struct {
+	mu         sync.RWMutex
+	logger     logger.Logger
+	components []*Component
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry(logger logger.Logger) *Registry {
+	return &Registry{logger: logger}
+}
+
+// Register adds the checker to the registry and returns its Component. Components start
+// out unhealthy until their first check passes. It should be called before Start.
+func (r *Registry) Register(checker Checker, opts CheckOptions) *Component {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultCheckInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.Interval
+	}
+
+	c := &Component{
+		checker: checker,
+		opts:    opts,
+		logger:  r.logger,
+		state:   NewTracker(false, "not checked yet"),
+	}
+
+	r.mu.Lock()
+	r.components = append(r.components, c)
+	r.mu.Unlock()
+
+	return c
+}
+
+// Component returns the registered component with the name, or nil.
+func (r *Registry) Component(name string) *Component {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.components {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Start runs the checks in the background until ctx is done.
+// Every component is checked right away and then every Interval.
+func (r *Registry) Start(ctx context.Context) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.logger.Infow("Starting health checks...", "components", len(r.components))
+	for _, c := range r.components {
+		go c.run(ctx)
+	}
+}
+
+// ComponentReport is the state of a single component at the time of the Report.
+type ComponentReport struct {
+	Name        string
+	Critical    bool
+	State       State
+	LastChecked time.Time
+}
+
+// Report is the aggregate health of the registered components.
+type Report struct {
+	Status     Status
+	Components []ComponentReport
+}
+
+// Ready is false when a critical component is unhealthy.
+func (r Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+// Report aggregates the latest state of every component, in registration order.
+func (r *Registry) Report() Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Components: make([]ComponentReport, 0, len(r.components))}
+	for _, c := range r.components {
+		cr := ComponentReport{
+			Name:        c.Name(),
+			Critical:    c.opts.Critical,
+			State:       c.State(),
+			LastChecked: c.LastChecked(),
+		}
+		report.Components = append(report.Components, cr)
+
+		switch {
+		case cr.State.Healthy:
+		case cr.Critical:
+			report.Status = StatusDown
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// Component is a registered Checker together with its latest state.
+// Besides the scheduled checks, its state can be flipped by MarkUnhealthy.
+type Component struct {
+	checker     Checker
+	opts        CheckOptions
+	logger      logger.Logger
+	state       *Tracker
+	lastChecked atomicTime
+}
+
+// Name returns the name of the checker.
+func (c *Component) Name() string {
+	return c.checker.Name()
+}
+
+// Critical reports whether the component is critical.
+func (c *Component) Critical() bool {
+	return c.opts.Critical
+}
+
+// IsHealthy returns the current health of the component.
+func (c *Component) IsHealthy() bool {
+	return c.state.State().Healthy
+}
+
+// State returns the current state of the component.
+func (c *Component) State() State {
+	return c.state.State()
+}
+
+// LastChecked returns when the component was last checked, zero if never.
+func (c *Component) LastChecked() time.Time {
+	return c.lastChecked.Load()
+}
+
+// MarkUnhealthy allows an external component (like a worker) to flag the
+// component as unhealthy without waiting for the next scheduled check.
+func (c *Component) MarkUnhealthy() {
+	// Set reports the flip, so only the first time it's marked unhealthy is logged.
+	if c.state.Set(false, "marked unhealthy externally") {
+		c.logger.Warnw("Component proactively marked as unhealthy", "component", c.Name())
+		metrics.ComponentUp.WithLabelValues(c.Name()).Set(0)
+	}
+}
+
+// run checks the component on its interval until ctx is done.
+func (c *Component) run(ctx context.Context) {
+	c.check(ctx)
+
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check performs a single check and records the result.
+func (c *Component) check(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	err := c.checker.Check(checkCtx)
+	c.lastChecked.Store(time.Now())
+	if ctx.Err() != nil {
+		return // shutting down, the result says nothing about the component
+	}
+
+	if err != nil {
+		if c.state.Set(false, err.Error()) {
+			c.logger.Errorw("Component is unhealthy", "component", c.Name(), "critical", c.opts.Critical, "error", err)
+		}
+		metrics.ComponentUp.WithLabelValues(c.Name()).Set(0)
+		return
+	}
+
+	if c.state.Set(true, "ok") {
+		c.logger.Infow("Component is healthy", "component", c.Name())
+	}
+	metrics.ComponentUp.WithLabelValues(c.Name()).Set(1)
+}
+
+// atomicTime is a time.Time that's safe for concurrent use.
+type atomicTime struct {
+	mu sync.RWMutex
+	t  time.Time
+}
+
+func (a *atomicTime) Load() time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.t
+}
+
+func (a *atomicTime) Store(t time.Time) {
+	a.mu.Lock()
+	a.t = t
+	a.mu.Unlock()
+}
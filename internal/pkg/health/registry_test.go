@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// switchChecker fails while its error is set.
+type switchChecker struct {
+	name string
+	err  atomic.Pointer[error]
+}
+
+func (c *switchChecker) Name() string { return c.name }
+
+func (c *switchChecker) Check(context.Context) error {
+	if err := c.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (c *switchChecker) fail(err error) { c.err.Store(&err) }
+
+func (c *switchChecker) recover() { c.err.Store(nil) }
+
+func TestRegistry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, kafka := &switchChecker{name: "db"}, &switchChecker{name: "kafka"}
+	kafka.fail(errors.New("brokers down"))
+
+	registry := NewRegistry(logger.NewMockLogger())
+	dbComponent := registry.Register(db, CheckOptions{Interval: 5 * time.Millisecond, Critical: true})
+	registry.Register(kafka, CheckOptions{Interval: 5 * time.Millisecond})
+
+	t.Run("components start unhealthy", func(t *testing.T) {
+		report := registry.Report()
+		assert.Equal(t, StatusDown, report.Status)
+		assert.False(t, report.Ready())
+		require.Len(t, report.Components, 2)
+		assert.Equal(t, "not checked yet", report.Components[0].State.Detail)
+	})
+
+	registry.Start(ctx)
+
+	t.Run("non-critical failure degrades", func(t *testing.T) {
+		assert.Eventually(t, func() bool { return registry.Report().Status == StatusDegraded }, time.Second, time.Millisecond)
+
+		report := registry.Report()
+		assert.True(t, report.Ready())
+		assert.Equal(t, "brokers down", report.Components[1].State.Detail)
+		assert.False(t, report.Components[1].LastChecked.IsZero())
+	})
+
+	t.Run("marked unhealthy until the next check", func(t *testing.T) {
+		kafka.recover()
+		assert.Eventually(t, func() bool { return registry.Report().Status == StatusOK }, time.Second, time.Millisecond)
+
+		db.fail(errors.New("ping failed"))
+		dbComponent.MarkUnhealthy()
+		assert.False(t, registry.Component("db").IsHealthy())
+		assert.Equal(t, StatusDown, registry.Report().Status)
+
+		db.recover()
+		assert.Eventually(t, dbComponent.IsHealthy, time.Second, time.Millisecond)
+	})
+
+	t.Run("unknown component", func(t *testing.T) {
+		assert.Nil(t, registry.Component("nope"))
+	})
+}
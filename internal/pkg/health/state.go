@@ -0,0 +1,46 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// State is a snapshot of a components health and of when it last changed.
+type State struct {
+	Healthy bool
+	Since   time.Time // when Healthy last flipped
+	Detail  string    // a human readable description of the current state
+}
+
+// Tracker keeps the State of a single component. It's safe for concurrent use.
+type Tracker struct {
+	mu    sync.RWMutex
+	state State
+}
+
+// NewTracker creates a Tracker with the initial state.
+func NewTracker(healthy bool, detail string) *Tracker {
+	return &Tracker{state: State{Healthy: healthy, Since: time.Now(), Detail: detail}}
+}
+
+// Set updates the state and reports whether the health has flipped.
+// The detail is always updated, but Since only moves on a flip.
+func (t *Tracker) Set(healthy bool, detail string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state.Detail = detail
+	if t.state.Healthy == healthy {
+		return false
+	}
+	t.state.Healthy = healthy
+	t.state.Since = time.Now()
+	return true
+}
+
+// State returns the current state.
+func (t *Tracker) State() State {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.state
+}
@@ -0,0 +1,21 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_sinceMovesOnFlipOnly(t *testing.T) {
+	tr := NewTracker(true, "ok")
+	since := tr.State().Since
+
+	time.Sleep(time.Millisecond)
+	assert.False(t, tr.Set(true, "still ok"))
+	assert.Equal(t, since, tr.State().Since)
+	assert.Equal(t, "still ok", tr.State().Detail)
+
+	assert.True(t, tr.Set(false, "broken"))
+	assert.True(t, tr.State().Since.After(since))
+}
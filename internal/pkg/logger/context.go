@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// CorrelationIDKey is the key the correlation id is logged under.
+const CorrelationIDKey = "correlation_id"
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of the ctx carrying the correlation id: the id of the
+// http request or of the kafka message that the work done under the ctx is for.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation id the ctx carries, "" if there's none.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// NewCorrelationID generates a random id, for when the caller didn't bring one.
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
+
+// FromContext returns the base logger with the ctx's correlation id added to every
+// entry, or the base logger itself if the ctx carries none.
+func FromContext(ctx context.Context, base Logger) Logger {
+	if id := CorrelationID(ctx); id != "" {
+		return base.With(CorrelationIDKey, id)
+	}
+	return base
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fieldsLogger remembers the fields it was created With.
+type fieldsLogger struct {
+	MockLogger
+	fields []any
+}
+
+func (l *fieldsLogger) With(keysAndValues ...any) Logger {
+	return &fieldsLogger{fields: append(append([]any{}, l.fields...), keysAndValues...)}
+}
+
+func TestFromContext(t *testing.T) {
+	base := &fieldsLogger{}
+
+	assert.Same(t, base, FromContext(context.Background(), base), "no id, no child logger")
+
+	ctx := WithCorrelationID(context.Background(), "req-1")
+	assert.Equal(t, "req-1", CorrelationID(ctx))
+
+	child := FromContext(ctx, base).(*fieldsLogger)
+	assert.Equal(t, []any{CorrelationIDKey, "req-1"}, child.fields)
+	assert.Empty(t, base.fields)
+}
+
+func TestNewCorrelationID(t *testing.T) {
+	a, b := NewCorrelationID(), NewCorrelationID()
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
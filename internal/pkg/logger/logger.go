@@ -8,5 +8,7 @@ type Logger interface {
 	Errorw(msg string, keysAndValues ...any)
 	Panicw(msg string, keysAndValues ...any)
 	Fatalw(msg string, keysAndValues ...any)
+	// With returns a child logger that adds the key-value pairs to every entry.
+	With(keysAndValues ...any) Logger
 	Sync() error
 }
@@ -17,6 +17,7 @@ func (l *MockLogger) Warnw(msg string, keysAndValues ...any)  {}
 func (l *MockLogger) Errorw(msg string, keysAndValues ...any) {}
 func (l *MockLogger) Panicw(msg string, keysAndValues ...any) {}
 func (l *MockLogger) Fatalw(msg string, keysAndValues ...any) {}
+func (l *MockLogger) With(keysAndValues ...any) Logger        { return l }
 func (l *MockLogger) Sync() error {
 	return nil
 }
@@ -51,6 +51,10 @@ func (l *developmentLogger) Fatalw(msg string, keysAndValues ...any) {
 	l.logger.Fatal(msg, l.toZapFields(keysAndValues...)...)
 }
 
+func (l *developmentLogger) With(keysAndValues ...any) Logger {
+	return &developmentLogger{logger: l.logger.With(l.toZapFields(keysAndValues...)...)}
+}
+
 func (l *developmentLogger) Sync() error {
 	return l.logger.Sync()
 }
@@ -42,6 +42,10 @@ func (l *sugaredLogger) Fatalw(msg string, keysAndValues ...any) {
 	l.logger.Fatalw(msg, keysAndValues...)
 }
 
+func (l *sugaredLogger) With(keysAndValues ...any) Logger {
+	return &sugaredLogger{logger: l.logger.With(keysAndValues...)}
+}
+
 func (l *sugaredLogger) Sync() error {
 	return l.logger.Sync()
 }
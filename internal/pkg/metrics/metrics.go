@@ -19,6 +19,33 @@ var (
 	},
 		[]string{"method", "path"},
 	)
+	OrdersImportedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_orders_imported_total",
+		Help: "Total number of orders seen by the bulk import, by result",
+	},
+		[]string{"status"},
+	)
+	OrdersExportedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_orders_exported_total",
+		Help: "Total number of orders written by the exports, by format",
+	},
+		[]string{"format"},
+	)
+	HTTPRequestsRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_rejected_total",
+		Help: "Total number of requests shed by the rate or concurrency limits",
+	},
+		[]string{"reason"},
+	)
+
+	OrderStreamSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_order_stream_subscribers",
+		Help: "Number of clients subscribed to the order stream",
+	})
+	OrderStreamDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "http_order_stream_dropped_total",
+		Help: "Total number of order stream subscribers dropped for falling behind",
+	})
 
 	/* Consumer metrics */
 	MessagesProcessedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
@@ -38,6 +65,20 @@ var (
 	},
 		[]string{"reason"},
 	)
+	DLQIngestedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dlq_ingested_total",
+		Help: "Total number of Dead Letter Queue messages read back into the database.",
+	})
+	DLQSkippedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dlq_skipped_total",
+		Help: "Total number of Dead Letter Queue messages the database rejected, skipped by the ingester.",
+	})
+	DLQResolvedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dlq_resolved_total",
+		Help: "Total number of Dead Letter Queue messages replayed or discarded.",
+	},
+		[]string{"action"},
+	)
 	ConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "consumer_lag",
 		Help: "Estimated number of messages lagging behind the latest offset.",
@@ -78,4 +119,12 @@ var (
 		Name: "db_transient_err_total",
 		Help: "Total number of recoverable DB hiccups",
 	})
+
+	/* Health metrics */
+	ComponentUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "component_up",
+		Help: "1 if the component passes its health check, 0 if not",
+	},
+		[]string{"component"},
+	)
 )
@@ -0,0 +1,233 @@
+// Package redact hides the personal data of an order from the roles that shouldn't see it.
+package redact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+)
+
+// Action is what's done to a field.
+type Action string
+
+const (
+	ActionMask Action = "mask" // all but the tail is starred out, emails keep the domain
+	ActionHash Action = "hash" // a keyed hash, equal values still compare equal
+	ActionDrop Action = "drop" // the field is emptied
+)
+
+// fields are the redactable fields of an order by their json paths.
+var fields = map[string]func(o *domain.Order) *string{
+	"customer_id":         func(o *domain.Order) *string { return &o.CustomerID },
+	"internal_signature":  func(o *domain.Order) *string { return &o.InternalSignature },
+	"delivery.name":       func(o *domain.Order) *string { return &o.Delivery.Name },
+	"delivery.phone":      func(o *domain.Order) *string { return &o.Delivery.Phone },
+	"delivery.zip":        func(o *domain.Order) *string { return &o.Delivery.Zip },
+	"delivery.city":       func(o *domain.Order) *string { return &o.Delivery.City },
+	"delivery.address":    func(o *domain.Order) *string { return &o.Delivery.Address },
+	"delivery.region":     func(o *domain.Order) *string { return &o.Delivery.Region },
+	"delivery.email":      func(o *domain.Order) *string { return &o.Delivery.Email },
+	"payment.transaction": func(o *domain.Order) *string { return &o.Payment.Transaction },
+	"payment.request_id":  func(o *domain.Order) *string { return &o.Payment.RequestID },
+	"payment.bank":        func(o *domain.Order) *string { return &o.Payment.Bank },
+	"payment.provider":    func(o *domain.Order) *string { return &o.Payment.Provider },
+}
+
+// Rule is a policy for a single field of the orders a role sees.
+type Rule struct {
+	Role   auth.Role
+	Field  string // a json path, like "delivery.phone"
+	Action Action
+}
+
+// DefaultRules are used when none are configured: viewers see barely anything personal,
+// support sees enough to talk to a customer, admins see it all.
+var DefaultRules = []Rule{
+	{auth.RoleViewer, "customer_id", ActionHash},
+	{auth.RoleViewer, "delivery.name", ActionMask},
+	{auth.RoleViewer, "delivery.phone", ActionMask},
+	{auth.RoleViewer, "delivery.email", ActionMask},
+	{auth.RoleViewer, "delivery.address", ActionDrop},
+	{auth.RoleViewer, "delivery.zip", ActionDrop},
+	{auth.RoleViewer, "payment.transaction", ActionHash},
+	{auth.RoleViewer, "payment.request_id", ActionHash},
+	{auth.RoleViewer, "payment.bank", ActionDrop},
+
+	{auth.RoleSupport, "delivery.phone", ActionMask},
+	{auth.RoleSupport, "delivery.email", ActionMask},
+	{auth.RoleSupport, "delivery.address", ActionMask},
+	{auth.RoleSupport, "payment.transaction", ActionHash},
+	{auth.RoleSupport, "payment.bank", ActionDrop},
+}
+
+// Fields lists the paths a Rule can name.
+func Fields() []string {
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+type fieldRule struct {
+	path   string
+	field  func(o *domain.Order) *string
+	action Action
+}
+
+// minHashKeyLen is the shortest accepted hash key, an HMAC is only as strong as its key.
+const minHashKeyLen = 32
+
+// Redactor applies the rules of the caller's role to orders. It's safe for concurrent use.
+type Redactor struct {
+	rules   map[auth.Role][]fieldRule
+	hashKey []byte
+}
+
+// New creates a Redactor. The hash key keeps the hashes of guessable values, like phone
+// numbers, from being reversed by hashing all the candidates; keep it secret. It's required,
+// at least 32 bytes of it, as soon as a rule hashes.
+func New(rules []Rule, hashKey []byte) (*Redactor, error) {
+	r := &Redactor{rules: make(map[auth.Role][]fieldRule), hashKey: hashKey}
+
+	for _, rule := range rules {
+		field, ok := fields[rule.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q, expected one of: %s", rule.Field, strings.Join(Fields(), ", "))
+		}
+		switch rule.Action {
+		case ActionMask, ActionHash, ActionDrop:
+		default:
+			return nil, fmt.Errorf("field %q: unknown action %q, expected one of: mask, hash, drop", rule.Field, rule.Action)
+		}
+		if rule.Action == ActionHash && len(hashKey) < minHashKeyLen {
+			return nil, fmt.Errorf("field %q is hashed: the hash key must be at least %d bytes", rule.Field, minHashKeyLen)
+		}
+		r.rules[rule.Role] = append(r.rules[rule.Role], fieldRule{path: rule.Field, field: field, action: rule.Action})
+	}
+	return r, nil
+}
+
+// Order returns the order as the role may see it. The order itself is never modified, it
+// may well be the one in the cache: if anything is redacted, that's done on a copy.
+func (r *Redactor) Order(role auth.Role, o *domain.Order) *domain.Order {
+	if o == nil {
+		return nil
+	}
+	rules := r.rulesOf(role)
+	if len(rules) == 0 {
+		return o
+	}
+
+	// Delivery and Payment are values, so they're copied along; the items aren't
+	// redacted and are shared with the original
+	redacted := *o
+	for _, rule := range rules {
+		p := rule.field(&redacted)
+		*p = r.apply(rule.action, *p)
+	}
+	return &redacted
+}
+
+// rulesOf returns the rules of the role.
+func (r *Redactor) rulesOf(role auth.Role) []fieldRule {
+	if role < auth.RoleViewer {
+		// nobody below a viewer should get an order at all, but if they do, they get the least
+		role = auth.RoleViewer
+	}
+	return r.rules[role]
+}
+
+// Hides reports whether the field (a json path, like "customer_id") is redacted for the role.
+func (r *Redactor) Hides(role auth.Role, path string) bool {
+	for _, rule := range r.rulesOf(role) {
+		if rule.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// HidesForCaller is Hides for the caller in the ctx. A nil Redactor hides nothing.
+func (r *Redactor) HidesForCaller(ctx context.Context, path string) bool {
+	if r == nil {
+		return false
+	}
+	return r.Hides(auth.FromContext(ctx).Role, path)
+}
+
+// Orders redacts each of the orders, see Order.
+func (r *Redactor) Orders(role auth.Role, orders []*domain.Order) []*domain.Order {
+	redacted := make([]*domain.Order, len(orders))
+	for i, o := range orders {
+		redacted[i] = r.Order(role, o)
+	}
+	return redacted
+}
+
+// ForCaller returns the order as the caller in the ctx may see it, see Order. A nil
+// Redactor (redaction is off) returns it as it is.
+func (r *Redactor) ForCaller(ctx context.Context, o *domain.Order) *domain.Order {
+	if r == nil {
+		return o
+	}
+	return r.Order(auth.FromContext(ctx).Role, o)
+}
+
+// AllForCaller is ForCaller for a slice, the slice itself is a new one unless r is nil.
+func (r *Redactor) AllForCaller(ctx context.Context, orders []*domain.Order) []*domain.Order {
+	if r == nil {
+		return orders
+	}
+	return r.Orders(auth.FromContext(ctx).Role, orders)
+}
+
+func (r *Redactor) apply(action Action, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	switch action {
+	case ActionMask:
+		return mask(value)
+	case ActionHash:
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	default:
+		return ""
+	}
+}
+
+// maskTail is how many runes mask leaves visible.
+const maskTail = 4
+
+// mask stars out all but the last few runes, "+9720000000" is "*******0000". An email
+// keeps its first letter and its domain, "test@gmail.com" is "t***@gmail.com".
+func mask(value string) string {
+	if local, domain, ok := strings.Cut(value, "@"); ok && local != "" {
+		first := []rune(local)[0]
+		return string(first) + strings.Repeat("*", len([]rune(local))-1) + "@" + domain
+	}
+
+	runes := []rune(value)
+	visible := 0
+	if len(runes) > 2*maskTail {
+		// short values would give away too much of themselves
+		visible = maskTail
+	}
+	for i := range runes[:len(runes)-visible] {
+		if runes[i] != ' ' {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
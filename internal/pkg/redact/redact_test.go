@@ -0,0 +1,139 @@
+package redact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("a-test-hash-key-of-32-bytes-long")
+
+func testOrder() *domain.Order {
+	return &domain.Order{
+		OrderUID:   "b563feb7b2b84b6test",
+		CustomerID: "test",
+		Delivery: domain.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: domain.Payment{
+			Transaction: "b563feb7b2b84b6test",
+			Currency:    "USD",
+			Provider:    "wbpay",
+			Amount:      1817,
+			Bank:        "alpha",
+		},
+		Items: []domain.Item{{ChrtID: 9934930, Name: "Mascaras"}},
+	}
+}
+
+func TestRedactor_Order(t *testing.T) {
+	r, err := New(DefaultRules, testKey)
+	require.NoError(t, err)
+
+	original := testOrder()
+
+	support := r.Order(auth.RoleSupport, original)
+	assert.Equal(t, "*******0000", support.Delivery.Phone)
+	assert.Equal(t, "t***@gmail.com", support.Delivery.Email)
+	assert.Equal(t, "******* ***a 15", support.Delivery.Address)
+	assert.Equal(t, "Test Testov", support.Delivery.Name)
+	assert.Regexp(t, `^sha256:[0-9a-f]{16}$`, support.Payment.Transaction)
+	assert.Empty(t, support.Payment.Bank)
+	assert.Equal(t, 1817, support.Payment.Amount)
+
+	viewer := r.Order(auth.RoleViewer, original)
+	assert.Equal(t, "****", viewer.Delivery.Name[:4])
+	assert.Empty(t, viewer.Delivery.Address)
+	assert.NotEqual(t, "test", viewer.CustomerID)
+	// hashed values stay comparable
+	assert.Equal(t, viewer.Payment.Transaction, support.Payment.Transaction)
+
+	assert.Same(t, original, r.Order(auth.RoleAdmin, original), "nothing to redact, nothing to copy")
+	assert.Equal(t, viewer, r.Order(auth.RoleNone, original), "below viewer gets the viewer rules")
+
+	// the original, maybe a cached one, is untouched
+	assert.Equal(t, testOrder(), original)
+}
+
+func TestRedactor_hashKey(t *testing.T) {
+	rules := []Rule{{auth.RoleViewer, "payment.transaction", ActionHash}}
+	a, err := New(rules, testKey)
+	require.NoError(t, err)
+	b, err := New(rules, []byte("another-test-hash-key-of-32bytes"))
+	require.NoError(t, err)
+
+	assert.NotEqual(t,
+		a.Order(auth.RoleViewer, testOrder()).Payment.Transaction,
+		b.Order(auth.RoleViewer, testOrder()).Payment.Transaction,
+	)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New([]Rule{{auth.RoleViewer, "delivery.shoe_size", ActionDrop}}, nil)
+	assert.ErrorContains(t, err, "unknown field")
+
+	_, err = New([]Rule{{auth.RoleViewer, "delivery.phone", "shred"}}, nil)
+	assert.ErrorContains(t, err, "unknown action")
+
+	// an unkeyed or short-keyed hash of a guessable id is reversed by hashing the candidates
+	_, err = New(DefaultRules, nil)
+	assert.ErrorContains(t, err, "at least 32 bytes")
+	_, err = New(DefaultRules, []byte("short"))
+	assert.ErrorContains(t, err, "at least 32 bytes")
+
+	_, err = New([]Rule{{auth.RoleViewer, "delivery.phone", ActionMask}}, nil)
+	assert.NoError(t, err, "no hashing, no key needed")
+}
+
+func Test_mask(t *testing.T) {
+	testCases := map[string]string{
+		"+9720000000":    "*******0000",
+		"short":          "*****",
+		"test@gmail.com": "t***@gmail.com",
+		"@nobody":        "*******",
+		"Тест Тестов":    "**** **стов",
+	}
+	for in, want := range testCases {
+		assert.Equal(t, want, mask(in), in)
+	}
+}
+
+func TestRedactor_ForCaller(t *testing.T) {
+	r, err := New(DefaultRules, testKey)
+	require.NoError(t, err)
+	original := testOrder()
+
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "dashboard", Role: auth.RoleViewer})
+	assert.Empty(t, r.ForCaller(ctx, original).Delivery.Address)
+	assert.Empty(t, r.AllForCaller(ctx, []*domain.Order{original})[0].Delivery.Address)
+	assert.Empty(t, r.ForCaller(context.Background(), original).Delivery.Address, "no caller, the least is shown")
+
+	var off *Redactor
+	assert.Same(t, original, off.ForCaller(ctx, original))
+}
+
+func TestRedactor_Hides(t *testing.T) {
+	r, err := New(DefaultRules, testKey)
+	require.NoError(t, err)
+
+	assert.True(t, r.Hides(auth.RoleViewer, "customer_id"))
+	assert.False(t, r.Hides(auth.RoleSupport, "customer_id"))
+	assert.False(t, r.Hides(auth.RoleViewer, "amount"), "not a redactable field")
+	assert.True(t, r.Hides(auth.RoleNone, "customer_id"), "no role, the least is shown")
+
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "dashboard", Role: auth.RoleViewer})
+	assert.True(t, r.HidesForCaller(ctx, "customer_id"))
+	var off *Redactor
+	assert.False(t, off.HidesForCaller(ctx, "customer_id"))
+}
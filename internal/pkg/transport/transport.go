@@ -0,0 +1,100 @@
+// Package transport is what the HTTP and the gRPC servers share, so the two can't drift
+// apart: which order uids and correlation ids are taken, who the caller is while auth is
+// off, which order filters they may use and what the store errors are answered with.
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/goinginblind/l0-task/internal/pkg/auth"
+	"github.com/goinginblind/l0-task/internal/pkg/redact"
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Unauthenticated is the identity of every caller while auth is off: a viewer, unless
+// a server is told to make them admins.
+var Unauthenticated = auth.Identity{Subject: "anonymous", Role: auth.RoleViewer, Method: auth.MethodAnonymous}
+
+// MaxCorrelationIDLen caps the ids taken from the callers, they end up in every log entry.
+const MaxCorrelationIDLen = 128
+
+// ValidUID mirrors the 'alphanum' rule domain.Order puts on order_uid,
+// so obviously malformed uids never reach the cache or the database.
+func ValidUID(uid string) bool {
+	if uid == "" {
+		return false
+	}
+	for _, c := range uid {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidCorrelationID accepts the usual id alphabets (uuids, hex, base64url, dotted traces).
+func ValidCorrelationID(id string) bool {
+	if id == "" || len(id) > MaxCorrelationIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// HiddenFilter returns the field (its json path) of the first filter the caller sets on a
+// field that's redacted for them, empty if there's none. Which orders match a value gives
+// the value away, so a caller who only sees a hashed customer_id mustn't look a raw one up.
+func HiddenFilter(ctx context.Context, r *redact.Redactor, filter store.OrderFilter) string {
+	for _, f := range []struct{ path, value string }{
+		{"customer_id", filter.CustomerID},
+		{"delivery_service", filter.DeliveryService},
+		{"locale", filter.Locale},
+		{"payment.currency", filter.Currency},
+		{"payment.provider", filter.Provider},
+	} {
+		if f.value != "" && r.HidesForCaller(ctx, f.path) {
+			return f.path
+		}
+	}
+	return ""
+}
+
+// Status is what an error is answered with, by either server.
+type Status struct {
+	HTTP    int
+	GRPC    codes.Code
+	Message string // safe to pass on to the caller
+}
+
+// storeStatuses are the store sentinels the callers are told about.
+var storeStatuses = []struct {
+	err  error
+	http int
+	grpc codes.Code
+}{
+	{store.ErrNotFound, http.StatusNotFound, codes.NotFound},
+	{store.ErrInvalidCursor, http.StatusBadRequest, codes.InvalidArgument},
+	{store.ErrConnectionFailed, http.StatusServiceUnavailable, codes.Unavailable},
+}
+
+// StoreError maps the store sentinel errors onto their statuses. It's false for anything
+// else, which is to be logged and answered as an internal error.
+func StoreError(err error) (Status, bool) {
+	for _, s := range storeStatuses {
+		if errors.Is(err, s.err) {
+			return Status{HTTP: s.http, GRPC: s.grpc, Message: s.err.Error()}, true
+		}
+	}
+	return Status{}, false
+}
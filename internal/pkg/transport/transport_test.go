@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/goinginblind/l0-task/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestValidUID(t *testing.T) {
+	assert.True(t, ValidUID("b563feb7b2b84b6test"))
+	assert.False(t, ValidUID(""))
+	assert.False(t, ValidUID("b563-feb7"))
+	assert.False(t, ValidUID("../etc"))
+}
+
+func TestValidCorrelationID(t *testing.T) {
+	assert.True(t, ValidCorrelationID("0af7651916cd43dd8448eb211c80319c"))
+	assert.True(t, ValidCorrelationID("trace.span:1-a_b"))
+	assert.False(t, ValidCorrelationID(""))
+	assert.False(t, ValidCorrelationID("with space"))
+	assert.False(t, ValidCorrelationID("new\nline"))
+	assert.False(t, ValidCorrelationID(strings.Repeat("a", MaxCorrelationIDLen+1)))
+}
+
+func TestStoreError(t *testing.T) {
+	s, ok := StoreError(fmt.Errorf("get order: %w", store.ErrNotFound))
+	assert.True(t, ok)
+	assert.Equal(t, Status{HTTP: http.StatusNotFound, GRPC: codes.NotFound, Message: store.ErrNotFound.Error()}, s)
+
+	s, ok = StoreError(store.ErrConnectionFailed)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, s.HTTP)
+	assert.Equal(t, codes.Unavailable, s.GRPC)
+
+	_, ok = StoreError(errors.New("pq: syntax error"))
+	assert.False(t, ok, "the internals are not passed on")
+}
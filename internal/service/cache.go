@@ -3,9 +3,11 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/health"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
 	"github.com/goinginblind/l0-task/internal/pkg/metrics"
 	"github.com/goinginblind/l0-task/internal/store"
@@ -17,6 +19,7 @@ type CachingOrderService struct {
 	store  OrderStore // ensure preloads
 	cache  *LRUCache
 	logger logger.Logger
+	warmup *health.Tracker // unhealthy until Preload is done
 }
 
 // NewCachingOrderService creates a caching decorator for OrderService
@@ -26,6 +29,7 @@ func NewCachingOrderService(next OrderService, store OrderStore, logger logger.L
 		store:  store,
 		cache:  NewLRUCache(entryCountCap, entrySizeCap),
 		logger: logger,
+		warmup: health.NewTracker(false, "preload pending"),
 	}
 }
 
@@ -39,12 +43,12 @@ func (s *CachingOrderService) GetOrder(ctx context.Context, uid string) (*domain
 		metrics.CacheResponseTime.WithLabelValues("get_order").Observe(duration)
 		metrics.CacheHits.Inc()
 
-		s.logger.Infow("Cache hit", "order_uid", uid)
+		logger.FromContext(ctx, s.logger).Infow("Cache hit", "order_uid", uid)
 		return order, nil
 	}
 
 	metrics.CacheMisses.Inc()
-	s.logger.Infow("Cache miss", "order_uid", uid)
+	logger.FromContext(ctx, s.logger).Infow("Cache miss", "order_uid", uid)
 	order, err := s.next.GetOrder(ctx, uid)
 	if err != nil {
 		return nil, err
@@ -60,6 +64,60 @@ func (s *CachingOrderService) GetOrder(ctx context.Context, uid string) (*domain
 	return order, nil
 }
 
+// GetOrders is GetOrder for several uids: the hits are taken from the cache and all the
+// misses are fetched from the underlying service at once, the found ones are then cached.
+func (s *CachingOrderService) GetOrders(ctx context.Context, uids []string) ([]*domain.Order, error) {
+	start := time.Now()
+	orders := make([]*domain.Order, len(uids))
+	var (
+		hits   int
+		misses []string
+	)
+	missIdx := make(map[string][]int) // a uid can be asked for more than once
+	for i, uid := range uids {
+		if order, found := s.cache.Get(uid); found {
+			orders[i] = order
+			hits++
+			metrics.CacheHits.Inc()
+			continue
+		}
+		if _, seen := missIdx[uid]; !seen {
+			misses = append(misses, uid)
+			metrics.CacheMisses.Inc()
+		}
+		missIdx[uid] = append(missIdx[uid], i)
+	}
+	duration := float64(time.Since(start).Seconds())
+	metrics.CacheResponseTime.WithLabelValues("get_orders").Observe(duration)
+
+	logger.FromContext(ctx, s.logger).Infow("Cache batch lookup", "hits", hits, "misses", len(misses))
+	if len(misses) == 0 {
+		return orders, nil
+	}
+
+	fetched, err := s.next.GetOrders(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+
+	start = time.Now() // for metrics
+
+	for j, order := range fetched {
+		if order == nil {
+			continue
+		}
+		s.cache.Insert(order)
+		for _, i := range missIdx[misses[j]] {
+			orders[i] = order
+		}
+	}
+
+	duration = float64(time.Since(start).Seconds()) // metrics
+	metrics.CacheResponseTime.WithLabelValues("insert_order").Observe(duration)
+
+	return orders, nil
+}
+
 // ProcessNewOrder calls the underlying service to process the order and, on success,
 // adds the new order to the cache.
 func (s *CachingOrderService) ProcessNewOrder(ctx context.Context, order *domain.Order) error {
@@ -72,21 +130,92 @@ func (s *CachingOrderService) ProcessNewOrder(ctx context.Context, order *domain
 	return err
 }
 
+// ListOrders is passed straight through: pages depend on the filter and the cursor,
+// so they aren't worth caching, and the listed orders are left out of the cache too
+// so a single browse session can't evict the hot entries.
+func (s *CachingOrderService) ListOrders(ctx context.Context, q store.ListQuery) (*store.OrderPage, error) {
+	return s.next.ListOrders(ctx, q)
+}
+
+// ProcessNewOrders calls the underlying service, like ProcessNewOrder it leaves the cache be:
+// a bulk import of historical orders would only push the hot entries out.
+func (s *CachingOrderService) ProcessNewOrders(ctx context.Context, orders []*domain.Order) []error {
+	return s.next.ProcessNewOrders(ctx, orders)
+}
+
 // Preload is used in case there's already something to cache
 func (s *CachingOrderService) Preload(ctx context.Context, limit int) error {
-	s.logger.Infow("Preloading cache...")
-	orders, err := s.store.GetLatestOrders(ctx, limit)
+	log := logger.FromContext(ctx, s.logger)
+	log.Infow("Preloading cache...")
+	s.warmup.Set(false, "preloading")
+	n, err := s.load(ctx, limit)
 	if err != nil {
+		// a cold cache still works, so the warm-up is over either way
 		if errors.Is(err, store.ErrConnectionFailed) {
-			s.logger.Warnw("Fail to preload cache, db is down")
+			log.Warnw("Fail to preload cache, db is down")
+			s.warmup.Set(true, "preload skipped, db is down")
 			return nil
 		}
+		s.warmup.Set(true, "preload failed: "+err.Error())
 		return err
 	}
 
+	log.Infow("Cache preload complete", "count", n)
+	s.warmup.Set(true, fmt.Sprintf("preloaded %d orders", n))
+	return nil
+}
+
+// Warm loads the latest orders into the cache again, like Preload, but leaves the
+// readiness alone: a running service is warm enough to serve. The limit is capped
+// at what the cache can hold, it returns how many orders were loaded.
+func (s *CachingOrderService) Warm(ctx context.Context, limit int) (int, error) {
+	n, err := s.load(ctx, min(limit, s.cache.entryCountCap))
+	if err != nil {
+		return 0, err
+	}
+	logger.FromContext(ctx, s.logger).Infow("Cache warm-up complete", "count", n)
+	return n, nil
+}
+
+// load inserts the latest orders (up to the limit) into the cache.
+func (s *CachingOrderService) load(ctx context.Context, limit int) (int, error) {
+	orders, err := s.store.GetLatestOrders(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
 	for _, order := range orders {
 		s.cache.Insert(order)
 	}
-	s.logger.Infow("Cache preload complete", "count", len(orders))
-	return nil
+	return len(orders), nil
+}
+
+// CacheStats returns a snapshot of the cache.
+func (s *CachingOrderService) CacheStats() CacheStats {
+	return s.cache.Stats()
+}
+
+// IsCached reports whether the order is in the cache, without touching its place in it.
+func (s *CachingOrderService) IsCached(uid string) bool {
+	_, ok := s.cache.Peek(uid)
+	return ok
+}
+
+// Evict removes the order from the cache, reporting whether it was there.
+func (s *CachingOrderService) Evict(uid string) bool {
+	return s.cache.Remove(uid)
+}
+
+// Purge empties the cache and returns how many orders it held.
+func (s *CachingOrderService) Purge() int {
+	return s.cache.Purge()
+}
+
+// WarmupChecker returns the health check of the cache, it fails until the preload is done.
+func (s *CachingOrderService) WarmupChecker() health.Checker {
+	return health.NewChecker("cache", func(context.Context) error {
+		if st := s.warmup.State(); !st.Healthy {
+			return errors.New(st.Detail)
+		}
+		return nil
+	})
 }
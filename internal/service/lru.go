@@ -3,6 +3,7 @@ package service
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 
 	"github.com/goinginblind/l0-task/internal/domain"
 	"github.com/goinginblind/l0-task/internal/pkg/sizeof"
@@ -12,6 +13,7 @@ import (
 type cacheEntry struct {
 	key   string
 	value *domain.Order
+	size  int // sizeof.SizeOf of the value, taken on insert
 }
 
 type LRUCache struct {
@@ -19,8 +21,25 @@ type LRUCache struct {
 	entryCountCap  int // cap max amount of entries
 	entrySizeCap   int // cap of single entry size
 	currEntryCount int
+	currSize       int // estimated bytes of all the entries
 	items          map[string]*list.Element
 	evictList      *list.List
+
+	hits, misses atomic.Uint64 // of Get
+}
+
+// CacheStats is a snapshot of the cache. The oldest key is the least recently used one,
+// the next to be evicted, the newest the most recently used.
+type CacheStats struct {
+	Entries        int     `json:"entries"`
+	EntryCountCap  int     `json:"entry_count_cap"`
+	EntrySizeCap   int     `json:"entry_size_cap"`
+	EstimatedBytes int     `json:"estimated_bytes"`
+	Hits           uint64  `json:"hits"`
+	Misses         uint64  `json:"misses"`
+	HitRatio       float64 `json:"hit_ratio"` // of all the Gets so far, 0 before the first
+	OldestKey      string  `json:"oldest_key,omitempty"`
+	NewestKey      string  `json:"newest_key,omitempty"`
 }
 
 func NewLRUCache(entryCountCap, entrySizeCap int) *LRUCache {
@@ -38,10 +57,24 @@ func (c *LRUCache) Get(key string) (*domain.Order, bool) {
 
 	elem, ok := c.items[key]
 	if ok {
+		c.hits.Add(1)
 		c.evictList.MoveToFront(elem)
 		return elem.Value.(*cacheEntry).value, true
 	}
 
+	c.misses.Add(1)
+	return nil, false
+}
+
+// Peek is Get without its side effects: the entry keeps its place and nothing's counted.
+func (c *LRUCache) Peek(key string) (*domain.Order, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	elem, ok := c.items[key]
+	if ok {
+		return elem.Value.(*cacheEntry).value, true
+	}
 	return nil, false
 }
 
@@ -52,13 +85,15 @@ func (c *LRUCache) Insert(value *domain.Order) {
 	key := value.OrderUID
 	elem, ok := c.items[key]
 	if !ok {
-		if sizeof.SizeOf(value) > c.entrySizeCap {
+		size := sizeof.SizeOf(value)
+		if size > c.entrySizeCap {
 			return
 		}
-		entry := &cacheEntry{key, value}
+		entry := &cacheEntry{key, value, size}
 		elem := c.evictList.PushFront(entry)
 		c.items[key] = elem
 		c.currEntryCount++
+		c.currSize += size
 	} else {
 		// TODO: order irl won't be immutable, but for now we will imagine that they are...
 		// so no accounting for size change, just move it as LRU
@@ -77,8 +112,63 @@ func (c *LRUCache) Insert(value *domain.Order) {
 func (c *LRUCache) removeOldest() {
 	elem := c.evictList.Back()
 	if elem != nil {
-		entry := c.evictList.Remove(elem).(*cacheEntry)
-		delete(c.items, entry.key)
-		c.currEntryCount--
+		c.removeElement(elem)
+	}
+}
+
+// removeElement unlinks the entry from both the list and the map
+func (c *LRUCache) removeElement(elem *list.Element) {
+	entry := c.evictList.Remove(elem).(*cacheEntry)
+	delete(c.items, entry.key)
+	c.currEntryCount--
+	c.currSize -= entry.size
+}
+
+// Remove evicts the key, reporting whether it was cached.
+func (c *LRUCache) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if ok {
+		c.removeElement(elem)
+	}
+	return ok
+}
+
+// Purge evicts every entry and returns how many there were. The hit counters are kept.
+func (c *LRUCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.currEntryCount
+	c.items = make(map[string]*list.Element)
+	c.evictList.Init()
+	c.currEntryCount, c.currSize = 0, 0
+	return n
+}
+
+// Stats returns a snapshot of the cache.
+func (c *LRUCache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	st := CacheStats{
+		Entries:        c.currEntryCount,
+		EntryCountCap:  c.entryCountCap,
+		EntrySizeCap:   c.entrySizeCap,
+		EstimatedBytes: c.currSize,
+		Hits:           c.hits.Load(),
+		Misses:         c.misses.Load(),
+	}
+	if total := st.Hits + st.Misses; total > 0 {
+		st.HitRatio = float64(st.Hits) / float64(total)
+	}
+	if back := c.evictList.Back(); back != nil {
+		st.OldestKey = back.Value.(*cacheEntry).key
+	}
+	if front := c.evictList.Front(); front != nil {
+		st.NewestKey = front.Value.(*cacheEntry).key
 	}
+	return st
 }
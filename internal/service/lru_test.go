@@ -116,3 +116,52 @@ func TestLRUCache_GetNonExistent(t *testing.T) {
 	_, ok := cache.Get("nonexistent")
 	assert.False(t, ok)
 }
+
+func TestLRUCache_PeekRemovePurge(t *testing.T) {
+	cache := NewLRUCache(3, 1024)
+	order1, order2 := &domain.Order{OrderUID: "uid1"}, &domain.Order{OrderUID: "uid2"}
+	cache.Insert(order1)
+	cache.Insert(order2)
+
+	// a peek neither counts nor refreshes the entry
+	val, ok := cache.Peek("uid1")
+	assert.True(t, ok)
+	assert.Equal(t, order1, val)
+	assert.Equal(t, "uid1", cache.Stats().OldestKey)
+	assert.Zero(t, cache.Stats().Hits)
+
+	assert.True(t, cache.Remove("uid1"))
+	assert.False(t, cache.Remove("uid1"))
+	_, ok = cache.Peek("uid1")
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.currEntryCount)
+	assert.Equal(t, sizeof.SizeOf(order2), cache.currSize)
+
+	cache.Insert(order1)
+	assert.Equal(t, 2, cache.Purge())
+	assert.Equal(t, 0, cache.currEntryCount)
+	assert.Equal(t, 0, cache.currSize)
+	_, ok = cache.Get("uid2")
+	assert.False(t, ok)
+}
+
+func TestLRUCache_Stats(t *testing.T) {
+	cache := NewLRUCache(2, 1024)
+	assert.Equal(t, CacheStats{EntryCountCap: 2, EntrySizeCap: 1024}, cache.Stats())
+
+	order1, order2, order3 := &domain.Order{OrderUID: "uid1"}, &domain.Order{OrderUID: "uid2"}, &domain.Order{OrderUID: "uid3"}
+	cache.Insert(order1)
+	cache.Insert(order2)
+	cache.Get("uid1")    // hit, uid1 is the newest now
+	cache.Get("nope")    // miss
+	cache.Insert(order3) // evicts uid2
+
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, sizeof.SizeOf(order1)+sizeof.SizeOf(order3), stats.EstimatedBytes)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 0.5, stats.HitRatio)
+	assert.Equal(t, "uid1", stats.OldestKey)
+	assert.Equal(t, "uid3", stats.NewestKey)
+}
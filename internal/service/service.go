@@ -6,14 +6,18 @@ import (
 
 	"github.com/goinginblind/l0-task/internal/domain"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
 )
 
 // OrderStore defines the interface for storing and retrieving orders.
 // It's implemeneted by the database layer (the store), describes the 'save' and 'load' contract.
 type OrderStore interface {
 	Insert(context.Context, *domain.Order) error
+	InsertBatch(context.Context, []*domain.Order) []error
 	GetOrder(context.Context, string) (*domain.Order, error)
+	GetOrders(context.Context, []string) (map[string]*domain.Order, error)
 	GetLatestOrders(context.Context, int) ([]*domain.Order, error)
+	ListOrders(context.Context, store.ListQuery) (*store.OrderPage, error)
 }
 
 // OrderService defines the interface for handling orders.
@@ -21,7 +25,10 @@ type OrderStore interface {
 // This is the buisness logic contract.
 type OrderService interface {
 	ProcessNewOrder(context.Context, *domain.Order) error
+	ProcessNewOrders(context.Context, []*domain.Order) []error
 	GetOrder(context.Context, string) (*domain.Order, error)
+	GetOrders(context.Context, []string) ([]*domain.Order, error)
+	ListOrders(context.Context, store.ListQuery) (*store.OrderPage, error)
 }
 
 // New creates a new OrderService.
@@ -52,6 +59,36 @@ func (s *orderService) ProcessNewOrder(ctx context.Context, order *domain.Order)
 	return nil
 }
 
+// ProcessNewOrders is the bulk version of ProcessNewOrder: every order is validated and the
+// valid ones are stored in one batch. The returned errors line up with the orders, a nil
+// means the order was stored. The errors wrap the same sentinels ProcessNewOrder does.
+func (s *orderService) ProcessNewOrders(ctx context.Context, orders []*domain.Order) []error {
+	errs := make([]error, len(orders))
+	valid := make([]*domain.Order, 0, len(orders))
+	validIdx := make([]int, 0, len(orders))
+
+	for i, order := range orders {
+		if err := order.Validate(); err != nil {
+			errs[i] = fmt.Errorf("validation failed: %w", err)
+			continue
+		}
+		valid = append(valid, order)
+		validIdx = append(validIdx, i)
+	}
+
+	if len(valid) == 0 {
+		return errs
+	}
+
+	for j, err := range s.store.InsertBatch(ctx, valid) {
+		if err != nil {
+			errs[validIdx[j]] = fmt.Errorf("failed to save order: %w", err)
+		}
+	}
+
+	return errs
+}
+
 // GetOrder retrieves an order by its UID. Currently looks like a wrapper. And it is.
 // Later won't be. Caching, baby. Allows the buisness logic of retrieving an order to be pretty && clean.
 func (s *orderService) GetOrder(ctx context.Context, uid string) (*domain.Order, error) {
@@ -61,3 +98,36 @@ func (s *orderService) GetOrder(ctx context.Context, uid string) (*domain.Order,
 	}
 	return order, nil
 }
+
+// GetOrders retrieves the orders of several uids at once. The returned orders line up with
+// the uids, a nil means there's no such order. A repeated uid is looked up once.
+func (s *orderService) GetOrders(ctx context.Context, uids []string) ([]*domain.Order, error) {
+	unique := make([]string, 0, len(uids))
+	seen := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		if !seen[uid] {
+			seen[uid] = true
+			unique = append(unique, uid)
+		}
+	}
+
+	found, err := s.store.GetOrders(ctx, unique)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %d orders: %w", len(unique), err)
+	}
+
+	orders := make([]*domain.Order, len(uids))
+	for i, uid := range uids {
+		orders[i] = found[uid]
+	}
+	return orders, nil
+}
+
+// ListOrders returns a page of orders matching the query.
+func (s *orderService) ListOrders(ctx context.Context, q store.ListQuery) (*store.OrderPage, error) {
+	page, err := s.store.ListOrders(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return page, nil
+}
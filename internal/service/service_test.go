@@ -8,6 +8,7 @@ import (
 
 	"github.com/goinginblind/l0-task/internal/domain"
 	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/store"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -23,6 +24,14 @@ func (m *MockOrderStore) Insert(ctx context.Context, order *domain.Order) error
 	return args.Error(0)
 }
 
+func (m *MockOrderStore) InsertBatch(ctx context.Context, orders []*domain.Order) []error {
+	args := m.Called(ctx, orders)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).([]error)
+}
+
 func (m *MockOrderStore) GetOrder(ctx context.Context, uid string) (*domain.Order, error) {
 	// Simulate database latency for realistic benchmarks
 	time.Sleep(2 * time.Millisecond)
@@ -33,6 +42,14 @@ func (m *MockOrderStore) GetOrder(ctx context.Context, uid string) (*domain.Orde
 	return args.Get(0).(*domain.Order), args.Error(1)
 }
 
+func (m *MockOrderStore) GetOrders(ctx context.Context, uids []string) (map[string]*domain.Order, error) {
+	args := m.Called(ctx, uids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*domain.Order), args.Error(1)
+}
+
 func (m *MockOrderStore) GetLatestOrders(ctx context.Context, limit int) ([]*domain.Order, error) {
 	args := m.Called(ctx, limit)
 	if args.Get(0) == nil {
@@ -41,6 +58,14 @@ func (m *MockOrderStore) GetLatestOrders(ctx context.Context, limit int) ([]*dom
 	return args.Get(0).([]*domain.Order), args.Error(1)
 }
 
+func (m *MockOrderStore) ListOrders(ctx context.Context, q store.ListQuery) (*store.OrderPage, error) {
+	args := m.Called(ctx, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.OrderPage), args.Error(1)
+}
+
 var (
 	mockOrder = &domain.Order{OrderUID: "benchmark-uid"}
 	ctx       = context.Background()
@@ -164,6 +189,85 @@ func TestOrderService_ProcessNewOrder(t *testing.T) {
 	})
 }
 
+func TestOrderService_ProcessNewOrders(t *testing.T) {
+	mockStore, mockLogger := new(MockOrderStore), logger.NewMockLogger()
+	service := New(mockStore, mockLogger)
+
+	ctx := context.Background()
+	order := &domain.Order{
+		OrderUID:    "testuid",
+		TrackNumber: "testtrack",
+		Entry:       "WBIL",
+		Delivery: domain.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "12345",
+			City:    "Test City",
+			Address: "Test Address",
+			Region:  "Test Region",
+			Email:   "test@example.com",
+		},
+		Payment: domain.Payment{
+			Transaction:  "testuid",
+			Currency:     "USD",
+			Provider:     "testprovider",
+			Amount:       100,
+			PaymentDt:    time.Now().Unix(),
+			Bank:         "testbank",
+			DeliveryCost: 10,
+			GoodsTotal:   90,
+			CustomFee:    0,
+		},
+		Items: []domain.Item{
+			{
+				ChrtID:      1,
+				TrackNumber: "testtrack",
+				Price:       90,
+				Rid:         "testrid",
+				Name:        "Test Item",
+				Sale:        0,
+				Size:        "M",
+				TotalPrice:  90,
+				NmID:        123,
+				Brand:       "Test Brand",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "testcustomer",
+		DeliveryService: "testservice",
+		ShardKey:        "1",
+		SmID:            1,
+		DateCreated:     time.Now(),
+		OofShard:        "1",
+	}
+
+	second := *order
+	second.OrderUID = "testuid2"
+	invalid := &domain.Order{OrderUID: "broken"}
+
+	t.Run("only valid orders reach the store", func(t *testing.T) {
+		mockStore.On("InsertBatch", ctx, []*domain.Order{order, &second}).
+			Return([]error{nil, store.ErrAlreadyExists}).Once()
+
+		errs := service.ProcessNewOrders(ctx, []*domain.Order{order, invalid, &second})
+
+		assert.Len(t, errs, 3)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], domain.ErrInvalidOrder)
+		assert.ErrorIs(t, errs[2], store.ErrAlreadyExists)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("nothing valid", func(t *testing.T) {
+		errs := service.ProcessNewOrders(ctx, []*domain.Order{invalid})
+
+		assert.Len(t, errs, 1)
+		assert.ErrorIs(t, errs[0], domain.ErrInvalidOrder)
+		mockStore.AssertNotCalled(t, "InsertBatch", mock.Anything, []*domain.Order{invalid})
+	})
+}
+
 func TestOrderService_GetOrder(t *testing.T) {
 	mockStore, mockLogger := new(MockOrderStore), logger.NewMockLogger()
 	service := New(mockStore, mockLogger)
@@ -191,3 +295,81 @@ func TestOrderService_GetOrder(t *testing.T) {
 		mockStore.AssertExpectations(t)
 	})
 }
+
+func TestOrderService_GetOrders(t *testing.T) {
+	mockStore := new(MockOrderStore)
+	service := New(mockStore, logger.NewMockLogger())
+	a, b := &domain.Order{OrderUID: "a"}, &domain.Order{OrderUID: "b"}
+
+	t.Run("found and missing", func(t *testing.T) {
+		mockStore.On("GetOrders", ctx, []string{"a", "nope", "b"}).
+			Return(map[string]*domain.Order{"a": a, "b": b}, nil).Once()
+
+		orders, err := service.GetOrders(ctx, []string{"a", "nope", "b", "a"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*domain.Order{a, nil, b, a}, orders)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		mockStore.On("GetOrders", ctx, []string{"a"}).Return(nil, store.ErrConnectionFailed).Once()
+
+		_, err := service.GetOrders(ctx, []string{"a"})
+
+		assert.ErrorIs(t, err, store.ErrConnectionFailed)
+	})
+}
+
+func TestCachingOrderService_GetOrders(t *testing.T) {
+	mockStore, mockLogger := new(MockOrderStore), logger.NewMockLogger()
+	cachingService := NewCachingOrderService(New(mockStore, mockLogger), mockStore, mockLogger, 100, 1024*1024)
+	cached, stored := &domain.Order{OrderUID: "cached"}, &domain.Order{OrderUID: "stored"}
+
+	mockStore.On("GetOrder", ctx, "cached").Return(cached, nil).Once()
+	_, err := cachingService.GetOrder(ctx, "cached")
+	assert.NoError(t, err)
+
+	// only the misses go to the store, all in one call
+	mockStore.On("GetOrders", ctx, []string{"stored", "nope"}).
+		Return(map[string]*domain.Order{"stored": stored}, nil).Once()
+
+	orders, err := cachingService.GetOrders(ctx, []string{"cached", "stored", "nope", "stored"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.Order{cached, stored, nil, stored}, orders)
+
+	// and the found ones are cached now, the missing one is asked for again
+	mockStore.On("GetOrders", ctx, []string{"nope"}).Return(map[string]*domain.Order{}, nil).Once()
+
+	orders, err = cachingService.GetOrders(ctx, []string{"stored", "nope"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.Order{stored, nil}, orders)
+	mockStore.AssertExpectations(t)
+}
+
+func TestCachingOrderService_Warm(t *testing.T) {
+	mockStore, mockLogger := new(MockOrderStore), logger.NewMockLogger()
+	cachingService := NewCachingOrderService(New(mockStore, mockLogger), mockStore, mockLogger, 2, 1024*1024)
+
+	// the limit is capped at what the cache holds
+	mockStore.On("GetLatestOrders", ctx, 2).
+		Return([]*domain.Order{{OrderUID: "new"}, {OrderUID: "old"}}, nil).Once()
+	n, err := cachingService.Warm(ctx, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.True(t, cachingService.IsCached("new"))
+	assert.True(t, cachingService.IsCached("old"))
+
+	// a warm-up doesn't touch the readiness
+	assert.Error(t, cachingService.WarmupChecker().Check(ctx))
+
+	assert.True(t, cachingService.Evict("new"))
+	assert.False(t, cachingService.IsCached("new"))
+	assert.Equal(t, 1, cachingService.Purge())
+	assert.Zero(t, cachingService.CacheStats().Entries)
+
+	mockStore.On("GetLatestOrders", ctx, 1).Return(nil, store.ErrConnectionFailed).Once()
+	_, err = cachingService.Warm(ctx, 1)
+	assert.ErrorIs(t, err, store.ErrConnectionFailed)
+	mockStore.AssertExpectations(t)
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/pkg/logger"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+// AnalyticsStore computes the sales analytics with SQL aggregates over the stored orders.
+// Every report takes an OrderFilter, the orders it doesn't match are left out.
+type AnalyticsStore struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+// NewAnalyticsStore creates a new AnalyticsStore
+func NewAnalyticsStore(db *sql.DB, logger logger.Logger) *AnalyticsStore {
+	return &AnalyticsStore{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// DailyRevenue is the amount paid in a currency on a UTC day, over that many orders.
+type DailyRevenue struct {
+	Day      string `json:"day"` // 2006-01-02
+	Currency string `json:"currency"`
+	Orders   int    `json:"orders"`
+	Amount   int64  `json:"amount"`
+}
+
+// RevenueReport is the revenue (payment.amount), it's never summed across the currencies.
+type RevenueReport struct {
+	ByDay      []DailyRevenue  `json:"by_day"` // oldest first
+	ByCurrency []CurrencyTotal `json:"by_currency"`
+}
+
+// GroupCount is the number of orders in a group.
+type GroupCount struct {
+	Group  string `json:"group"`
+	Orders int    `json:"orders"`
+}
+
+// OrdersReport is the order counts by delivery service and by region, the biggest first.
+type OrdersReport struct {
+	ByDeliveryService []GroupCount `json:"by_delivery_service"`
+	ByRegion          []GroupCount `json:"by_region"`
+}
+
+// BrandSales is how many items of the brand were sold.
+type BrandSales struct {
+	Brand string `json:"brand"`
+	Items int    `json:"items"`
+}
+
+// ItemSales is how many of the item (by nm_id) were sold.
+type ItemSales struct {
+	NmID  int    `json:"nm_id"`
+	Name  string `json:"name"`
+	Brand string `json:"brand"`
+	Items int    `json:"items"`
+}
+
+// ItemsReport is the best selling brands and items, and the average sale of all the items.
+type ItemsReport struct {
+	TopBrands          []BrandSales `json:"top_brands"`
+	TopItems           []ItemSales  `json:"top_items"`
+	Items              int          `json:"items"`
+	AverageSalePercent float64      `json:"average_sale_percent"`
+}
+
+// ProviderShare is the orders paid through the provider and their share (0 to 1) of all of them.
+type ProviderShare struct {
+	Provider string  `json:"provider"`
+	Orders   int     `json:"orders"`
+	Share    float64 `json:"share"`
+}
+
+// PaymentsReport is the payment provider mix, the most used first.
+type PaymentsReport struct {
+	Providers []ProviderShare `json:"providers"`
+}
+
+// Revenue returns the revenue per day and per currency.
+func (s *AnalyticsStore) Revenue(ctx context.Context, f OrderFilter) (*RevenueReport, error) {
+	report := &RevenueReport{ByDay: []DailyRevenue{}, ByCurrency: []CurrencyTotal{}}
+	err := s.query(ctx, "analytics_revenue_by_day", qRevenueByDay, f, 0, func(rows *sql.Rows) error {
+		var r DailyRevenue
+		if err := rows.Scan(&r.Day, &r.Currency, &r.Orders, &r.Amount); err != nil {
+			return err
+		}
+		report.ByDay = append(report.ByDay, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.query(ctx, "analytics_revenue_by_currency", qRevenueByCurrency, f, 0, func(rows *sql.Rows) error {
+		var t CurrencyTotal
+		if err := rows.Scan(&t.Currency, &t.Orders, &t.Amount); err != nil {
+			return err
+		}
+		report.ByCurrency = append(report.ByCurrency, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Orders returns the order counts by delivery service and by region.
+func (s *AnalyticsStore) Orders(ctx context.Context, f OrderFilter) (*OrdersReport, error) {
+	report := &OrdersReport{}
+	for _, group := range []struct {
+		op, query string
+		counts    *[]GroupCount
+	}{
+		{"analytics_orders_by_delivery_service", qOrdersByDeliveryService, &report.ByDeliveryService},
+		{"analytics_orders_by_region", qOrdersByRegion, &report.ByRegion},
+	} {
+		*group.counts = []GroupCount{}
+		err := s.query(ctx, group.op, group.query, f, 0, func(rows *sql.Rows) error {
+			var c GroupCount
+			if err := rows.Scan(&c.Group, &c.Orders); err != nil {
+				return err
+			}
+			*group.counts = append(*group.counts, c)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// Items returns the limit best selling brands and items, and the average sale of all the items.
+func (s *AnalyticsStore) Items(ctx context.Context, f OrderFilter, limit int) (*ItemsReport, error) {
+	report := &ItemsReport{TopBrands: []BrandSales{}, TopItems: []ItemSales{}}
+	err := s.query(ctx, "analytics_top_brands", qTopBrands, f, limit, func(rows *sql.Rows) error {
+		var b BrandSales
+		if err := rows.Scan(&b.Brand, &b.Items); err != nil {
+			return err
+		}
+		report.TopBrands = append(report.TopBrands, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.query(ctx, "analytics_top_items", qTopItems, f, limit, func(rows *sql.Rows) error {
+		var it ItemSales
+		if err := rows.Scan(&it.NmID, &it.Name, &it.Brand, &it.Items); err != nil {
+			return err
+		}
+		report.TopItems = append(report.TopItems, it)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.query(ctx, "analytics_average_sale", qAverageSale, f, 0, func(rows *sql.Rows) error {
+		return rows.Scan(&report.Items, &report.AverageSalePercent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Payments returns the payment provider mix.
+func (s *AnalyticsStore) Payments(ctx context.Context, f OrderFilter) (*PaymentsReport, error) {
+	report := &PaymentsReport{Providers: []ProviderShare{}}
+	err := s.query(ctx, "analytics_provider_mix", qProviderMix, f, 0, func(rows *sql.Rows) error {
+		var p ProviderShare
+		if err := rows.Scan(&p.Provider, &p.Orders, &p.Share); err != nil {
+			return err
+		}
+		report.Providers = append(report.Providers, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// query runs an aggregate with the filter's WHERE clause put in, and the limit too if it's
+// over 0, calling scan for every row. op is its label of the db response time metric.
+func (s *AnalyticsStore) query(ctx context.Context, op, query string, f OrderFilter, limit int, scan func(*sql.Rows) error) error {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues(op).Observe(duration)
+	}()
+
+	where, args := f.whereClause()
+	var clause string
+	if len(where) > 0 {
+		clause = "\n\t\tWHERE\n\t\t\t" + strings.Join(where, "\n\t\t\tAND ")
+	}
+	if limit > 0 {
+		args = append(args, limit)
+		query = fmt.Sprintf(query, clause, len(args))
+	} else {
+		query = fmt.Sprintf(query, clause)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if isConnectionError(err) {
+			return ErrConnectionFailed
+		}
+		return fmt.Errorf("querying for %s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("scanning %s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		if isConnectionError(err) {
+			return ErrConnectionFailed
+		}
+		return fmt.Errorf("iterating over %s: %w", op, err)
+	}
+	return nil
+}
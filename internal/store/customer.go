@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+// CustomerSummary sums up all the orders of a customer.
+type CustomerSummary struct {
+	CustomerID       string                 `json:"customer_id"`
+	Orders           int                    `json:"orders"`
+	FirstOrderAt     time.Time              `json:"first_order_at"`
+	LastOrderAt      time.Time              `json:"last_order_at"`
+	Totals           []CurrencyTotal        `json:"totals"`            // by currency
+	DeliveryServices []DeliveryServiceUsage `json:"delivery_services"` // the most used first
+}
+
+// CurrencyTotal is the amount paid in a currency, over that many orders.
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	Orders   int    `json:"orders"`
+	Amount   int64  `json:"amount"`
+}
+
+// DeliveryServiceUsage is how many orders were delivered by the service.
+type DeliveryServiceUsage struct {
+	DeliveryService string `json:"delivery_service"`
+	Orders          int    `json:"orders"`
+}
+
+// CustomerSummary sums up the orders of the customer, it's ErrNotFound if there are none.
+// The orders themselves are listed with ListOrders, filtered by the customer.
+func (s *DBStore) CustomerSummary(ctx context.Context, customerID string) (*CustomerSummary, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("customer_summary").Observe(duration)
+	}()
+
+	var (
+		summary                = CustomerSummary{CustomerID: customerID}
+		firstOrder, lastOrder  sql.NullTime
+		totalsJSON, usagesJSON []byte
+	)
+	err := s.db.QueryRowContext(ctx, qCustomerSummary, customerID).
+		Scan(&summary.Orders, &firstOrder, &lastOrder, &totalsJSON, &usagesJSON)
+	if err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("querying for customer %s summary: %w", customerID, err)
+	}
+	if summary.Orders == 0 {
+		return nil, ErrNotFound
+	}
+	summary.FirstOrderAt, summary.LastOrderAt = firstOrder.Time, lastOrder.Time
+
+	if err := json.Unmarshal(totalsJSON, &summary.Totals); err != nil {
+		return nil, fmt.Errorf("unmarshaling customer totals json: %w", err)
+	}
+	if err := json.Unmarshal(usagesJSON, &summary.DeliveryServices); err != nil {
+		return nil, fmt.Errorf("unmarshaling customer delivery services json: %w", err)
+	}
+	return &summary, nil
+}
@@ -0,0 +1,455 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+// DLQStatus is where a dead-lettered message stands.
+type DLQStatus string
+
+const (
+	DLQPending   DLQStatus = "pending"   // waiting for someone to look into it
+	DLQReplaying DLQStatus = "replaying" // claimed by a replay that's being delivered
+	DLQReplayed  DLQStatus = "replayed"  // sent back to the orders topic
+	DLQDiscarded DLQStatus = "discarded" // given up on
+)
+
+// ParseDLQStatus parses a status, it's an error if it isn't one of the four.
+func ParseDLQStatus(s string) (DLQStatus, error) {
+	switch st := DLQStatus(s); st {
+	case DLQPending, DLQReplaying, DLQReplayed, DLQDiscarded:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown dlq status %q, expected pending, replaying, replayed or discarded", s)
+}
+
+// DLQHeader is a kafka header of a dead-lettered message. The value holds the header's bytes
+// as they are, they needn't be valid UTF-8.
+type DLQHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// dlqHeader is a DLQHeader as it's stored. A header value is any bytes: as a []byte it goes
+// into the json base64 encoded, a json string would replace the invalid UTF-8 and postgres
+// rejects a NUL in one, either way the replay would not send what was dead-lettered.
+type dlqHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// DLQMessage is a message of the dead-letter topic: where it is in the dlq, where it was
+// consumed from, why it was dead-lettered and what's been done about it.
+type DLQMessage struct {
+	ID           int64  `json:"id"`
+	DLQTopic     string `json:"dlq_topic"`
+	DLQPartition int32  `json:"dlq_partition"`
+	DLQOffset    int64  `json:"dlq_offset"`
+
+	// nil if the dlq message doesn't tell
+	SourceTopic     *string `json:"source_topic"`
+	SourcePartition *int32  `json:"source_partition"`
+	SourceOffset    *int64  `json:"source_offset"`
+
+	Key     string      `json:"key"` // the bytes of the kafka key, like the header values
+	Payload []byte      `json:"-"`   // see MarshalJSON
+	Reason  string      `json:"reason"`
+	Headers []DLQHeader `json:"headers"`
+
+	Status     DLQStatus  `json:"status"`
+	DeadAt     time.Time  `json:"dead_at"`
+	ReceivedAt time.Time  `json:"received_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// MarshalJSON puts the payload in as text: it's an order, or what was meant to be one,
+// base64 would only hide it. Invalid UTF-8 is replaced (in the key and the headers too), the
+// stored bytes are what's replayed.
+func (m *DLQMessage) MarshalJSON() ([]byte, error) {
+	type message DLQMessage // without the method
+	return json.Marshal(struct {
+		*message
+		Payload string `json:"payload"`
+	}{(*message)(m), string(m.Payload)})
+}
+
+// DLQAuditEntry is a record of a dlq message being resolved.
+type DLQAuditEntry struct {
+	ID        int64     `json:"id"`
+	Action    DLQStatus `json:"action"` // replayed or discarded
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DLQFilter narrows down the listed dlq messages. Empty fields are not applied.
+type DLQFilter struct {
+	Status DLQStatus
+	Key    string // exact, the order_uid for the producer's messages
+	Reason string // a case-insensitive substring
+}
+
+// DLQQuery describes a single page of dlq messages, see ListQuery.
+type DLQQuery struct {
+	DLQFilter
+	Cursor string
+	Limit  int
+}
+
+// DLQPage is a single page of dlq messages, newest first. NextCursor is empty on the last page.
+type DLQPage struct {
+	Messages   []*DLQMessage `json:"messages"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// DLQResolution is what's done with a dlq message and by whom.
+type DLQResolution struct {
+	Status DLQStatus // replayed or discarded
+	Actor  string
+	Note   string
+}
+
+// SaveDLQMessage stores a message read from the dead-letter topic. A message that's already
+// stored, going by its dlq position, is left as it is, so a redelivery is harmless.
+func (s *DBStore) SaveDLQMessage(ctx context.Context, m *DLQMessage) error {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("insert_dlq_message").Observe(duration)
+	}()
+
+	stored := make([]dlqHeader, len(m.Headers))
+	for i, h := range m.Headers {
+		stored[i] = dlqHeader{Key: h.Key, Value: []byte(h.Value)}
+	}
+	headers, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("marshaling dlq headers: %w", err)
+	}
+
+	// the key is any bytes too, it's stored as they are
+	_, err = s.db.ExecContext(ctx, qInsertDLQMessage,
+		m.DLQTopic, m.DLQPartition, m.DLQOffset, m.SourceTopic, m.SourcePartition, m.SourceOffset,
+		[]byte(m.Key), m.Payload, m.Reason, headers, m.DeadAt,
+	)
+	if err != nil {
+		if isConnectionError(err) {
+			return ErrConnectionFailed
+		}
+		return fmt.Errorf("inserting dlq message: %w", err)
+	}
+	return nil
+}
+
+// GetDLQMessage retrieves a dlq message by its id, ErrNotFound if there's no such message.
+func (s *DBStore) GetDLQMessage(ctx context.Context, id int64) (*DLQMessage, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("get_dlq_message").Observe(duration)
+	}()
+
+	m, err := scanDLQMessage(s.db.QueryRowContext(ctx, qRetrieveDLQMessage, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("querying for dlq message %d: %w", id, err)
+	}
+	return m, nil
+}
+
+// ListDLQMessages returns a page of dlq messages matching the filter, newest first.
+// Like ListOrders it's keyset paginated, on the id.
+func (s *DBStore) ListDLQMessages(ctx context.Context, q DLQQuery) (*DLQPage, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("list_dlq_messages").Observe(duration)
+	}()
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if q.Status != "" {
+		add("status = $%d", q.Status)
+	}
+	if q.Key != "" {
+		add("message_key = $%d", []byte(q.Key))
+	}
+	if q.Reason != "" {
+		add("reason ILIKE '%%' || $%d || '%%'", escapeLike(q.Reason))
+	}
+	if q.Cursor != "" {
+		id, err := decodeDLQCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		add("id < $%d", id)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(qListDLQMessages)
+	if len(where) > 0 {
+		sb.WriteString("\n\t\tWHERE\n\t\t\t")
+		sb.WriteString(strings.Join(where, "\n\t\t\tAND "))
+	}
+	// one extra row tells whether there's a next page
+	args = append(args, limit+1)
+	fmt.Fprintf(&sb, "\n\t\tORDER BY\n\t\t\tid DESC\n\t\tLIMIT $%d;", len(args))
+
+	rows, err := s.db.QueryContext(ctx, sb.String(), args...)
+	if err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("querying for dlq messages: %w", err)
+	}
+	defer rows.Close()
+
+	page := &DLQPage{Messages: make([]*DLQMessage, 0, limit)}
+	for rows.Next() {
+		if len(page.Messages) == limit {
+			page.NextCursor = encodeDLQCursor(page.Messages[limit-1].ID)
+			break
+		}
+		m, err := scanDLQMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning listed dlq message: %w", err)
+		}
+		page.Messages = append(page.Messages, m)
+	}
+	if err = rows.Err(); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("iterating listed dlq message rows: %w", err)
+	}
+
+	return page, nil
+}
+
+// DLQAuditTrail returns the resolutions of a dlq message, oldest first.
+func (s *DBStore) DLQAuditTrail(ctx context.Context, id int64) ([]DLQAuditEntry, error) {
+	rows, err := s.db.QueryContext(ctx, qRetrieveDLQAudit, id)
+	if err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("querying for the dlq audit trail: %w", err)
+	}
+	defer rows.Close()
+
+	trail := []DLQAuditEntry{}
+	for rows.Next() {
+		var e DLQAuditEntry
+		if err := rows.Scan(&e.ID, &e.Action, &e.Actor, &e.Note, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning dlq audit entry: %w", err)
+		}
+		trail = append(trail, e)
+	}
+	if err = rows.Err(); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("iterating dlq audit rows: %w", err)
+	}
+	return trail, nil
+}
+
+// ClaimDLQMessage marks a pending dlq message as replaying, so no one else replays or claims
+// it while it's being delivered. It's ErrNotFound if there's no such message and
+// ErrDLQResolved if it isn't pending.
+func (s *DBStore) ClaimDLQMessage(ctx context.Context, id int64) (*DLQMessage, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("claim_dlq_message").Observe(duration)
+	}()
+
+	m, err := scanDLQMessage(s.db.QueryRowContext(ctx, qClaimDLQMessage, id))
+	if err == nil {
+		return m, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("claiming dlq message %d: %w", id, err)
+	}
+
+	// either there's no such message or it isn't pending
+	m, err = s.GetDLQMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: %s", ErrDLQResolved, m.Status)
+}
+
+// ReleaseDLQMessage puts a claimed dlq message back to pending, its replay wasn't delivered.
+func (s *DBStore) ReleaseDLQMessage(ctx context.Context, id int64) error {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("release_dlq_message").Observe(duration)
+	}()
+
+	if _, err := s.db.ExecContext(ctx, qReleaseDLQMessage, id); err != nil {
+		if isConnectionError(err) {
+			return ErrConnectionFailed
+		}
+		return fmt.Errorf("releasing dlq message %d: %w", id, err)
+	}
+	return nil
+}
+
+// ResolveDLQMessage marks a dlq message as replayed or discarded and records who did it.
+// Only a claimed message is marked as replayed, a pending or a claimed one is discarded
+// (a claim outlives a replay that died halfway, no one can tell if it was delivered).
+// It's ErrNotFound if there's no such message and ErrDLQResolved if it can't be resolved so.
+func (s *DBStore) ResolveDLQMessage(ctx context.Context, id int64, res DLQResolution) (*DLQMessage, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("resolve_dlq_message").Observe(duration)
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	m, err := scanDLQMessage(tx.QueryRowContext(ctx, qLockDLQMessage, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("locking dlq message %d: %w", id, err)
+	}
+	resolvable := m.Status == DLQReplaying || m.Status == DLQPending && res.Status != DLQReplayed
+	if !resolvable {
+		return nil, fmt.Errorf("%w: %s", ErrDLQResolved, m.Status)
+	}
+
+	if _, err := tx.ExecContext(ctx, qResolveDLQMessage, id, res.Status); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("resolving dlq message %d: %w", id, err)
+	}
+	if _, err := tx.ExecContext(ctx, qInsertDLQAudit, id, res.Status, res.Actor, res.Note); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("recording dlq audit entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("committing dlq resolution: %w", err)
+	}
+
+	now := time.Now()
+	m.Status, m.ResolvedAt = res.Status, &now
+	return m, nil
+}
+
+// scanDLQMessage scans a row of qDLQMessageColumns.
+func scanDLQMessage(row interface{ Scan(...any) error }) (*DLQMessage, error) {
+	var (
+		m               DLQMessage
+		sourceTopic     sql.NullString
+		sourcePartition sql.NullInt32
+		sourceOffset    sql.NullInt64
+		key             []byte
+		headers         []byte
+		resolvedAt      sql.NullTime
+	)
+	err := row.Scan(
+		&m.ID, &m.DLQTopic, &m.DLQPartition, &m.DLQOffset, &sourceTopic, &sourcePartition, &sourceOffset,
+		&key, &m.Payload, &m.Reason, &headers, &m.Status, &m.DeadAt, &m.ReceivedAt, &resolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if sourceTopic.Valid {
+		m.SourceTopic = &sourceTopic.String
+	}
+	if sourcePartition.Valid {
+		m.SourcePartition = &sourcePartition.Int32
+	}
+	if sourceOffset.Valid {
+		m.SourceOffset = &sourceOffset.Int64
+	}
+	if resolvedAt.Valid {
+		m.ResolvedAt = &resolvedAt.Time
+	}
+	m.Key = string(key)
+
+	var stored []dlqHeader
+	if err := json.Unmarshal(headers, &stored); err != nil {
+		return nil, fmt.Errorf("unmarshaling dlq headers: %w", err)
+	}
+	m.Headers = make([]DLQHeader, len(stored))
+	for i, h := range stored {
+		m.Headers[i] = DLQHeader{Key: h.Key, Value: string(h.Value)}
+	}
+	return &m, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so s is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func encodeDLQCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeDLQCursor(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
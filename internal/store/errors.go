@@ -24,6 +24,14 @@ var (
 
 	// ErrConnectionFailed will later be used for retry/backoff logic (I think)
 	ErrConnectionFailed = errors.New("connection to the database failed")
+
+	// ErrInvalidCursor is returned when a pagination cursor can't be decoded,
+	// it's the callers fault, not the datastores.
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+	// ErrDLQResolved is returned when a dlq message that's been replayed or
+	// discarded already is to be resolved again.
+	ErrDLQResolved = errors.New("the dlq message has been resolved already")
 )
 
 // isConnectionError return true if error was:
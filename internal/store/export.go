@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+)
+
+// exportFetchSize is how many orders an export fetches from its cursor at a time,
+// it's what bounds the memory of an export on this side.
+const exportFetchSize = 500
+
+// ExportOrders streams every order matching the filter to fn, oldest first.
+//
+// The orders are read through a server-side cursor in a read-only repeatable read
+// transaction: the export is a consistent snapshot and only exportFetchSize orders are
+// ever held at once, however many match. An error from fn stops the export and is returned.
+func (s *DBStore) ExportOrders(ctx context.Context, filter OrderFilter, fn func(*domain.Order) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		if isConnectionError(err) {
+			return ErrConnectionFailed
+		}
+		return fmt.Errorf("beginning export transaction: %w", err)
+	}
+	defer tx.Rollback() // read-only, there's nothing to commit
+
+	where, args := filter.whereClause()
+	var sb strings.Builder
+	sb.WriteString("DECLARE order_export NO SCROLL CURSOR FOR")
+	sb.WriteString(qExportOrdersAsJSON)
+	if len(where) > 0 {
+		sb.WriteString("\n\t\tWHERE\n\t\t\t")
+		sb.WriteString(strings.Join(where, "\n\t\t\tAND "))
+	}
+	sb.WriteString("\n\t\tORDER BY\n\t\t\to.date_created, o.id;")
+
+	if _, err := tx.ExecContext(ctx, sb.String(), args...); err != nil {
+		if isConnectionError(err) {
+			return ErrConnectionFailed
+		}
+		return fmt.Errorf("declaring export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM order_export;", exportFetchSize)
+	for {
+		n, err := s.fetchExportBatch(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+// fetchExportBatch fetches the next batch off the export cursor and hands its orders to fn,
+// it returns how many there were.
+func (s *DBStore) fetchExportBatch(ctx context.Context, tx *sql.Tx, fetch string, fn func(*domain.Order) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		if isConnectionError(err) {
+			return 0, ErrConnectionFailed
+		}
+		return 0, fmt.Errorf("fetching from export cursor: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var orderJSON []byte
+		if err := rows.Scan(&orderJSON); err != nil {
+			return n, fmt.Errorf("scanning exported order json: %w", err)
+		}
+
+		var order domain.Order
+		if err := json.Unmarshal(orderJSON, &order); err != nil {
+			return n, fmt.Errorf("unmarshaling exported order json: %w", err)
+		}
+		if err := fn(&order); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if err = rows.Err(); err != nil {
+		if isConnectionError(err) {
+			return n, ErrConnectionFailed
+		}
+		return n, fmt.Errorf("iterating exported order rows: %w", err)
+	}
+	return n, nil
+}
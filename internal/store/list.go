@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/domain"
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+const (
+	// DefaultListLimit is the page size used when ListQuery.Limit is not set.
+	DefaultListLimit = 20
+	// MaxListLimit caps the page size, bigger limits are clamped down to it.
+	MaxListLimit = 100
+)
+
+// OrderFilter narrows down the listed orders. Empty (zero) fields are not applied.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Locale          string
+	CreatedFrom     time.Time // inclusive
+	CreatedTo       time.Time // exclusive
+	Currency        string    // payment.currency
+	Provider        string    // payment.provider
+}
+
+// SortField is a column the orders can be listed by.
+type SortField string
+
+const (
+	SortDateCreated     SortField = "date_created"
+	SortAmount          SortField = "amount" // payment.amount, whatever the currency
+	SortCustomerID      SortField = "customer_id"
+	SortDeliveryService SortField = "delivery_service"
+)
+
+// sortColumns are the columns of the sort fields, their types to cast the cursor values to
+// and the order id to break the ties with, of the same table so the (column, id) index is used.
+var sortColumns = map[SortField]struct{ column, cast, id string }{
+	SortDateCreated:     {"o.date_created", "timestamptz", "o.id"},
+	SortAmount:          {"p.amount", "int", "p.order_id"},
+	SortCustomerID:      {"o.customer_id", "text", "o.id"},
+	SortDeliveryService: {"o.delivery_service", "text", "o.id"},
+}
+
+// OrderSort is the order of the listed orders, ties are broken by the order id.
+// The zero value is newest first.
+type OrderSort struct {
+	Field     SortField // SortDateCreated if empty
+	Ascending bool
+}
+
+// ParseSortField returns the field named s.
+func ParseSortField(s string) (SortField, error) {
+	field := SortField(s)
+	if _, ok := sortColumns[field]; !ok {
+		return "", fmt.Errorf("unknown sort field %q, expected one of: date_created, amount, customer_id, delivery_service", s)
+	}
+	return field, nil
+}
+
+func (s OrderSort) field() SortField {
+	if s.Field == "" {
+		return SortDateCreated
+	}
+	return s.Field
+}
+
+// String is the sort as it's put into the cursors, e.g. "amount asc".
+func (s OrderSort) String() string {
+	if s.Ascending {
+		return string(s.field()) + " asc"
+	}
+	return string(s.field()) + " desc"
+}
+
+// isDefault is true for the newest first.
+func (s OrderSort) isDefault() bool {
+	return s.field() == SortDateCreated && !s.Ascending
+}
+
+// ListQuery describes a single page request: the filter, the sort, the cursor returned
+// with the previous page (empty for the first one) and the page size.
+type ListQuery struct {
+	OrderFilter
+	Sort   OrderSort
+	Cursor string
+	Limit  int
+}
+
+// OrderPage is a single page of orders, newest first. NextCursor is empty on the last page.
+type OrderPage struct {
+	Orders     []*domain.Order `json:"orders"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// ListOrders returns a page of orders matching the filter, in the order of the sort
+// (by date_created, newest first, by default).
+//
+// Pagination is keyset based: the cursor holds the (sorted by column, id) of the last order
+// on the previous page, so pages stay stable while new orders keep arriving and, with an index
+// on (column, id) for each sort, deep pages cost the same as the first one. A cursor only goes
+// with the sort it was returned for.
+func (s *DBStore) ListOrders(ctx context.Context, q ListQuery) (*OrderPage, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("list_orders").Observe(duration)
+	}()
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	sortColumn, ok := sortColumns[q.Sort.field()]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %q", q.Sort.Field)
+	}
+	direction, after := "DESC", "<"
+	if q.Sort.Ascending {
+		direction, after = "ASC", ">"
+	}
+
+	where, args := q.OrderFilter.whereClause()
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, c.value, c.id)
+		where = append(where, fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d)",
+			sortColumn.column, sortColumn.id, after, len(args)-1, sortColumn.cast, len(args)))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, qListOrdersAsJSON, sortColumn.column)
+	if len(where) > 0 {
+		sb.WriteString("\n\t\tWHERE\n\t\t\t")
+		sb.WriteString(strings.Join(where, "\n\t\t\tAND "))
+	}
+	// one extra row tells whether there's a next page
+	args = append(args, limit+1)
+	fmt.Fprintf(&sb, "\n\t\tORDER BY\n\t\t\t%s %s, %s %s\n\t\tLIMIT $%d;", sortColumn.column, direction, sortColumn.id, direction, len(args))
+
+	rows, err := s.db.QueryContext(ctx, sb.String(), args...)
+	if err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("querying for orders: %w", err)
+	}
+	defer rows.Close()
+
+	page := &OrderPage{Orders: make([]*domain.Order, 0, limit)}
+	var last cursor
+	for rows.Next() {
+		var (
+			c         = cursor{sort: q.Sort}
+			value     any
+			orderJSON []byte
+		)
+		if err := rows.Scan(&c.id, &value, &orderJSON); err != nil {
+			return nil, fmt.Errorf("scanning listed order json: %w", err)
+		}
+		c.value = cursorValue(value)
+
+		if len(page.Orders) == limit {
+			page.NextCursor = last.encode()
+			break
+		}
+
+		var order domain.Order
+		if err := json.Unmarshal(orderJSON, &order); err != nil {
+			return nil, fmt.Errorf("unmarshaling listed order json: %w", err)
+		}
+		page.Orders = append(page.Orders, &order)
+		last = c
+	}
+
+	if err = rows.Err(); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("iterating listed order rows: %w", err)
+	}
+
+	return page, nil
+}
+
+// whereClause turns the non-empty filter fields into SQL conditions and their
+// positional arguments, the placeholders are numbered starting at $1.
+func (f OrderFilter) whereClause() ([]string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.CustomerID != "" {
+		add("o.customer_id = $%d", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		add("o.delivery_service = $%d", f.DeliveryService)
+	}
+	if f.Locale != "" {
+		add("o.locale = $%d", f.Locale)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("o.date_created >= $%d", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("o.date_created < $%d", f.CreatedTo)
+	}
+	if f.Currency != "" {
+		add("p.currency = $%d", strings.ToUpper(f.Currency))
+	}
+	if f.Provider != "" {
+		add("p.provider = $%d", f.Provider)
+	}
+
+	return where, args
+}
+
+// cursor is the keyset position of the last order of a page: the value of the column
+// it's sorted by (as text, it's cast back in the query) and the id.
+type cursor struct {
+	sort  OrderSort
+	value string
+	id    int64
+}
+
+// cursorValue is the text of a sorted by column's value, the timestamps in RFC 3339.
+func cursorValue(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// encode packs the cursor into an opaque url-safe token. The sort is put in front of
+// the value, unless it's the default one, so a cursor can't be used with another sort.
+func (c cursor) encode() string {
+	raw := c.value + "|" + strconv.FormatInt(c.id, 10)
+	if !c.sort.isDefault() {
+		raw = c.sort.String() + "|" + raw
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor unpacks a token of a page listed in the sort.
+func decodeCursor(token string, sort OrderSort) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	rest := string(raw)
+	if !sort.isDefault() {
+		var ok bool
+		if rest, ok = strings.CutPrefix(rest, sort.String()+"|"); !ok {
+			return cursor{}, ErrInvalidCursor
+		}
+	}
+	// the value may have a '|' of its own, the id can't
+	sep := strings.LastIndexByte(rest, '|')
+	if sep < 0 {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	c := cursor{sort: sort, value: rest[:sep]}
+	if c.id, err = strconv.ParseInt(rest[sep+1:], 10, 64); err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	// checked here, the database would take a malformed one for a server error
+	switch sort.field() {
+	case SortDateCreated:
+		_, err = time.Parse(time.RFC3339Nano, c.value)
+	case SortAmount:
+		_, err = strconv.Atoi(c.value)
+	}
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
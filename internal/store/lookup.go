@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goinginblind/l0-task/internal/pkg/metrics"
+)
+
+// LookupKind is an identifier an order can be looked up by.
+type LookupKind string
+
+const (
+	LookupOrderUID    LookupKind = "order_uid"
+	LookupTrackNumber LookupKind = "track_number" // of the order or of any of its items
+	LookupTransaction LookupKind = "transaction"  // of the payment
+	LookupRID         LookupKind = "rid"          // of any of the items
+)
+
+// LookupKinds are all the kinds, in the order they're tried in.
+var LookupKinds = []LookupKind{LookupOrderUID, LookupTrackNumber, LookupTransaction, LookupRID}
+
+// lookupQueries are the lookups of every kind.
+var lookupQueries = map[LookupKind][]string{
+	LookupOrderUID:    {qLookupByOrderUID},
+	LookupTrackNumber: {qLookupByOrderTrackNumber, qLookupByItemTrackNumber},
+	LookupTransaction: {qLookupByTransaction},
+	LookupRID:         {qLookupByRID},
+}
+
+// ParseLookupKind returns the kind named s.
+func ParseLookupKind(s string) (LookupKind, error) {
+	kind := LookupKind(s)
+	if _, ok := lookupQueries[kind]; !ok {
+		return "", fmt.Errorf("unknown lookup kind %q, expected one of: order_uid, track_number, transaction, rid", s)
+	}
+	return kind, nil
+}
+
+// LookupMatch is an order matching a looked up identifier, and what it was matched by:
+// a transaction is often the order uid itself, a track number both the order's and its items'.
+type LookupMatch struct {
+	OrderUID  string       `json:"order_uid"`
+	MatchedBy []LookupKind `json:"matched_by"`
+}
+
+// LookupOrders resolves the identifier to the uids of the orders it belongs to, newest first,
+// MaxListLimit of them at most. It's tried as every kind of the kinds, as all of them if none
+// are given. No matches is an empty slice, not ErrNotFound: the identifier isn't a record.
+func (s *DBStore) LookupOrders(ctx context.Context, value string, kinds ...LookupKind) ([]LookupMatch, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("lookup_orders").Observe(duration)
+	}()
+
+	if len(kinds) == 0 {
+		kinds = LookupKinds
+	}
+	var lookups []string
+	for _, kind := range kinds {
+		queries, ok := lookupQueries[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown lookup kind %q", kind)
+		}
+		lookups = append(lookups, queries...)
+	}
+	query := fmt.Sprintf(qLookupOrders, strings.Join(lookups, "\n\t\t\tUNION ALL"))
+
+	rows, err := s.db.QueryContext(ctx, query, value, MaxListLimit)
+	if err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("looking up orders: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []LookupMatch{}
+	for rows.Next() {
+		var (
+			m         LookupMatch
+			matchedBy string
+		)
+		if err := rows.Scan(&m.OrderUID, &matchedBy); err != nil {
+			return nil, fmt.Errorf("scanning order lookup: %w", err)
+		}
+		for _, kind := range strings.Split(matchedBy, ",") {
+			m.MatchedBy = append(m.MatchedBy, LookupKind(kind))
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("iterating over order lookups: %w", err)
+	}
+	return matches, nil
+}
@@ -206,3 +206,353 @@ const (
 		LIMIT $1;
 	`
 )
+
+// The fragments below are shared by the queries that are assembled at runtime
+// (filters and cursors differ from call to call), they all select the same JSON
+// shape as qRetrieveJSON. Items are aggregated per order with a LATERAL join, so
+// only the orders on the page are touched.
+const (
+	qOrderJSONObject = `
+		json_build_object(
+			'order_uid', o.order_uid,
+			'track_number', o.track_number,
+			'entry', o.entry,
+			'delivery', json_build_object(
+				'name', d.name,
+				'phone', d.phone,
+				'zip', d.zip,
+				'city', d.city,
+				'address', d.address,
+				'region', d.region,
+				'email', d.email
+			),
+			'payment', json_build_object(
+				'transaction', p.transaction,
+				'request_id', p.request_id,
+				'currency', p.currency,
+				'provider', p.provider,
+				'amount', p.amount,
+				'payment_dt', p.payment_dt,
+				'bank', p.bank,
+				'delivery_cost', p.delivery_cost,
+				'goods_total', p.goods_total,
+				'custom_fee', p.custom_fee
+			),
+			'items', COALESCE(i.items_json, '[]'::json),
+			'locale', o.locale,
+			'internal_signature', o.internal_signature,
+			'customer_id', o.customer_id,
+			'delivery_service', o.delivery_service,
+			'shardkey', o.shard_key,
+			'sm_id', o.sm_id,
+			'date_created', o.date_created,
+			'oof_shard', o.oof_shard
+		)`
+
+	qOrderJSONFrom = `
+		FROM
+			orders o
+		JOIN
+			deliveries d ON o.id = d.order_id
+		JOIN
+			payments p ON o.id = p.order_id
+		LEFT JOIN LATERAL
+			(
+				SELECT
+					json_agg(json_build_object(
+						'chrt_id', it.chrt_id,
+						'track_number', it.track_number,
+						'price', it.price,
+						'rid', it.rid,
+						'name', it.name,
+						'sale', it.sale,
+						'size', it.size,
+						'total_price', it.total_price,
+						'nm_id', it.nm_id,
+						'brand', it.brand,
+						'status', it.status
+					) ORDER BY it.id) AS items_json
+				FROM
+					items it
+				WHERE
+					it.order_id = o.id
+			) i ON TRUE`
+
+	// Retrieves the orders with any of the uids in $1 as JSONs, keyed by their uid.
+	qRetrieveJSONByUIDs = `
+		SELECT
+			o.order_uid,` + qOrderJSONObject + qOrderJSONFrom + `
+		WHERE
+			o.order_uid = ANY($1);
+	`
+
+	// Exports orders as JSONs, the cursor declaration and the WHERE and ORDER BY
+	// clauses are added by ExportOrders.
+	qExportOrdersAsJSON = `
+		SELECT` + qOrderJSONObject + qOrderJSONFrom
+
+	// Lists orders as JSONs together with their pagination key, the id and the sorted by
+	// column (%s), the WHERE, ORDER BY and LIMIT clauses are appended by ListOrders.
+	qListOrdersAsJSON = `
+		SELECT
+			o.id, %s,` + qOrderJSONObject + qOrderJSONFrom
+)
+
+// The lookups of the orders by an identifier in $1, each selects the ids of the orders it matches
+// and what it matched by. LookupOrders puts the ones asked for together into qLookupOrders.
+const (
+	qLookupByOrderUID = `
+			SELECT id AS order_id, 'order_uid' AS matched_by FROM orders WHERE order_uid = $1`
+
+	qLookupByOrderTrackNumber = `
+			SELECT id AS order_id, 'track_number' AS matched_by FROM orders WHERE track_number = $1`
+
+	qLookupByItemTrackNumber = `
+			SELECT order_id, 'track_number' AS matched_by FROM items WHERE track_number = $1`
+
+	qLookupByTransaction = `
+			SELECT order_id, 'transaction' AS matched_by FROM payments WHERE transaction = $1`
+
+	qLookupByRID = `
+			SELECT order_id, 'rid' AS matched_by FROM items WHERE rid = $1`
+
+	// Resolves the matches (%s, the lookups UNION ALL'ed) to at most $2 order uids, newest first,
+	// with every way they were matched by.
+	qLookupOrders = `
+		SELECT
+			o.order_uid,
+			string_agg(DISTINCT m.matched_by, ',' ORDER BY m.matched_by)
+		FROM
+			(%s
+			) m
+		JOIN
+			orders o ON o.id = m.order_id
+		GROUP BY
+			o.id
+		ORDER BY
+			o.date_created DESC, o.id DESC
+		LIMIT $2;
+	`
+)
+
+// The analytics aggregates, the WHERE clause of the OrderFilter is put in for the %s
+// (it refers to orders o and payments p), the %d is the numbered placeholder of the limit.
+const (
+	qAnalyticsFrom = `
+		FROM
+			orders o
+		JOIN
+			payments p ON o.id = p.order_id`
+
+	qAnalyticsItemsFrom = `
+		FROM
+			items it
+		JOIN
+			orders o ON o.id = it.order_id
+		JOIN
+			payments p ON o.id = p.order_id`
+
+	// The revenue per UTC day and currency, oldest first.
+	qRevenueByDay = `
+		SELECT
+			to_char(o.date_created AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, p.currency, COUNT(*), SUM(p.amount)` +
+		qAnalyticsFrom + `%s
+		GROUP BY
+			day, p.currency
+		ORDER BY
+			day, p.currency;
+	`
+
+	// The revenue per currency.
+	qRevenueByCurrency = `
+		SELECT
+			p.currency, COUNT(*), SUM(p.amount)` + qAnalyticsFrom + `%s
+		GROUP BY
+			p.currency
+		ORDER BY
+			p.currency;
+	`
+
+	// The orders per delivery service, the most first.
+	qOrdersByDeliveryService = `
+		SELECT
+			o.delivery_service, COUNT(*)` + qAnalyticsFrom + `%s
+		GROUP BY
+			o.delivery_service
+		ORDER BY
+			COUNT(*) DESC, o.delivery_service;
+	`
+
+	// The orders per delivery region, the most first.
+	qOrdersByRegion = `
+		SELECT
+			d.region, COUNT(*)` + qAnalyticsFrom + `
+		JOIN
+			deliveries d ON o.id = d.order_id%s
+		GROUP BY
+			d.region
+		ORDER BY
+			COUNT(*) DESC, d.region;
+	`
+
+	// The brands of the most items sold.
+	qTopBrands = `
+		SELECT
+			it.brand, COUNT(*)` + qAnalyticsItemsFrom + `%s
+		GROUP BY
+			it.brand
+		ORDER BY
+			COUNT(*) DESC, it.brand
+		LIMIT $%d;
+	`
+
+	// The items (by nm_id) sold the most.
+	qTopItems = `
+		SELECT
+			it.nm_id, MIN(it.name), MIN(it.brand), COUNT(*)` + qAnalyticsItemsFrom + `%s
+		GROUP BY
+			it.nm_id
+		ORDER BY
+			COUNT(*) DESC, it.nm_id
+		LIMIT $%d;
+	`
+
+	// The items sold and their average sale percentage.
+	qAverageSale = `
+		SELECT
+			COUNT(*), COALESCE(AVG(it.sale), 0)::float8` + qAnalyticsItemsFrom + `%s;
+	`
+
+	// The orders per payment provider and their share of all of them, the most first.
+	qProviderMix = `
+		SELECT
+			p.provider, COUNT(*), COUNT(*)::float8 / SUM(COUNT(*)) OVER ()` + qAnalyticsFrom + `%s
+		GROUP BY
+			p.provider
+		ORDER BY
+			COUNT(*) DESC, p.provider;
+	`
+)
+
+// Summarizes the orders of the customer in $1: how many, the first and the last one, the
+// totals per currency and the delivery services used (the most used first), the latter two as JSONs.
+const qCustomerSummary = `
+		WITH customer_orders AS (
+			SELECT
+				o.date_created, o.delivery_service, p.currency, p.amount
+			FROM
+				orders o
+			JOIN
+				payments p ON o.id = p.order_id
+			WHERE
+				o.customer_id = $1
+		)
+		SELECT
+			(SELECT COUNT(*) FROM customer_orders),
+			(SELECT MIN(date_created) FROM customer_orders),
+			(SELECT MAX(date_created) FROM customer_orders),
+			(
+				SELECT
+					json_agg(json_build_object(
+						'currency', t.currency,
+						'orders', t.orders,
+						'amount', t.amount
+					) ORDER BY t.currency)
+				FROM
+					(SELECT currency, COUNT(*) AS orders, SUM(amount) AS amount FROM customer_orders GROUP BY currency) t
+			),
+			(
+				SELECT
+					json_agg(json_build_object(
+						'delivery_service', t.delivery_service,
+						'orders', t.orders
+					) ORDER BY t.orders DESC, t.delivery_service)
+				FROM
+					(SELECT delivery_service, COUNT(*) AS orders FROM customer_orders GROUP BY delivery_service) t
+			);
+	`
+
+// The dead-letter queue. Messages are read back from the dlq topic and kept until they're
+// replayed or discarded, every such resolution leaves a row in dlq_audit.
+const (
+	// Stores a dlq message, unless it's been stored already (a redelivery).
+	qInsertDLQMessage = `
+		INSERT INTO dlq_messages (
+			dlq_topic, dlq_partition, dlq_offset, source_topic, source_partition, source_offset,
+			message_key, payload, reason, headers, dead_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (dlq_topic, dlq_partition, dlq_offset) DO NOTHING;
+	`
+
+	qDLQMessageColumns = `
+		SELECT
+			id, dlq_topic, dlq_partition, dlq_offset, source_topic, source_partition, source_offset,
+			message_key, payload, reason, headers, status, dead_at, received_at, resolved_at
+		FROM
+			dlq_messages`
+
+	// Retrieves a dlq message by its id.
+	qRetrieveDLQMessage = qDLQMessageColumns + `
+		WHERE
+			id = $1;
+	`
+
+	// Locks a dlq message for its resolution.
+	qLockDLQMessage = qDLQMessageColumns + `
+		WHERE
+			id = $1
+		FOR UPDATE;
+	`
+
+	// Lists dlq messages, the WHERE, ORDER BY and LIMIT clauses are appended by ListDLQMessages.
+	qListDLQMessages = qDLQMessageColumns
+
+	// Claims a pending dlq message for a replay, no rows if it isn't pending.
+	qClaimDLQMessage = `
+		UPDATE dlq_messages
+		SET
+			status = 'replaying'
+		WHERE
+			id = $1
+			AND status = 'pending'
+		RETURNING
+			id, dlq_topic, dlq_partition, dlq_offset, source_topic, source_partition, source_offset,
+			message_key, payload, reason, headers, status, dead_at, received_at, resolved_at;
+	`
+
+	qReleaseDLQMessage = `
+		UPDATE dlq_messages
+		SET
+			status = 'pending'
+		WHERE
+			id = $1
+			AND status = 'replaying';
+	`
+
+	qResolveDLQMessage = `
+		UPDATE dlq_messages
+		SET
+			status = $2,
+			resolved_at = NOW()
+		WHERE
+			id = $1;
+	`
+
+	qInsertDLQAudit = `
+		INSERT INTO dlq_audit (message_id, action, actor, note)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	// Retrieves the audit trail of a dlq message, oldest first.
+	qRetrieveDLQAudit = `
+		SELECT
+			id, action, actor, note, created_at
+		FROM
+			dlq_audit
+		WHERE
+			message_id = $1
+		ORDER BY
+			id;
+	`
+)
@@ -47,8 +47,24 @@ func (s *DBStore) Insert(ctx context.Context, o *domain.Order) error {
 	}
 	defer tx.Rollback()
 
+	if err := insertTx(ctx, tx, o); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.FromContext(ctx, s.logger).Errorw("Failed to commit order", "order_uid", o.OrderUID, "error", err)
+		if isConnectionError(err) {
+			return ErrConnectionFailed
+		}
+		return fmt.Errorf("committing order: %w", err)
+	}
+	return nil
+}
+
+// insertTx writes the order and its delivery, payment and items using the given transaction.
+func insertTx(ctx context.Context, tx *sql.Tx, o *domain.Order) error {
 	var orderID int64
-	err = tx.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx, qInsertOrders,
 		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
 		o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard,
@@ -104,7 +120,81 @@ func (s *DBStore) Insert(ctx context.Context, o *domain.Order) error {
 		}
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+// InsertBatch adds the orders in a single transaction, but unlike Insert a bad order doesn't
+// fail the whole batch: each order gets its own savepoint and the returned slice holds an error
+// (or nil) for every order, in the same order. Errors are the same as the Insert ones.
+//
+// If the transaction itself fails (begin, commit, a lost connection) every order gets that error,
+// since none of them are stored.
+func (s *DBStore) InsertBatch(ctx context.Context, orders []*domain.Order) []error {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("insert_batch").Observe(duration)
+	}()
+
+	errs := make([]error, len(orders))
+	failAll := func(err error) []error {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		if isConnectionError(err) {
+			return failAll(ErrConnectionFailed)
+		}
+		return failAll(fmt.Errorf("beginning transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	for i, o := range orders {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_order"); err != nil {
+			if isConnectionError(err) {
+				return failAll(ErrConnectionFailed)
+			}
+			return failAll(fmt.Errorf("creating savepoint: %w", err))
+		}
+
+		if errs[i] = insertTx(ctx, tx, o); errs[i] != nil {
+			if errors.Is(errs[i], ErrConnectionFailed) {
+				return failAll(ErrConnectionFailed)
+			}
+			// a failed statement aborts the transaction, only the order's own writes are undone
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_order"); err != nil {
+				return failAll(fmt.Errorf("rolling back to savepoint: %w", err))
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_order"); err != nil {
+			return failAll(fmt.Errorf("releasing savepoint: %w", err))
+		}
+	}
+
+	log := logger.FromContext(ctx, s.logger)
+	if err := tx.Commit(); err != nil {
+		log.Errorw("Failed to commit order batch", "orders", len(orders), "error", err)
+		if isConnectionError(err) {
+			return failAll(ErrConnectionFailed)
+		}
+		return failAll(fmt.Errorf("committing batch: %w", err))
+	}
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	log.Infow("Order batch inserted", "orders", len(orders), "failed", failed)
+
+	return errs
 }
 
 // Get retrieves a single order from the database by scanning the raw columns.
@@ -124,17 +214,72 @@ func (s *DBStore) GetOrder(ctx context.Context, orderUID string) (*domain.Order,
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
 		return nil, fmt.Errorf("querying for order %s: %w", orderUID, err)
 	}
 
 	var order domain.Order
 	if err := json.Unmarshal(jsonBytes, &order); err != nil {
+		// the db assembles the json itself, so this is a bug in the query rather than bad data
+		logger.FromContext(ctx, s.logger).Errorw("Failed to unmarshal stored order", "order_uid", orderUID, "error", err)
 		return nil, fmt.Errorf("failed to unmarshal order %s: %w", orderUID, err)
 	}
 
 	return &order, nil
 }
 
+// GetOrders retrieves the orders with the given uids in a single query, keyed by their uid.
+// The uids that don't exist are simply missing from the map.
+func (s *DBStore) GetOrders(ctx context.Context, orderUIDs []string) (map[string]*domain.Order, error) {
+	start := time.Now()
+	defer func() {
+		duration := float64(time.Since(start).Seconds())
+		metrics.DBResponseTime.WithLabelValues("get_orders").Observe(duration)
+	}()
+
+	orders := make(map[string]*domain.Order, len(orderUIDs))
+	if len(orderUIDs) == 0 {
+		return orders, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, qRetrieveJSONByUIDs, orderUIDs)
+	if err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("querying for %d orders: %w", len(orderUIDs), err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			uid       string
+			orderJSON []byte
+		)
+		if err := rows.Scan(&uid, &orderJSON); err != nil {
+			return nil, fmt.Errorf("scanning order json: %w", err)
+		}
+
+		var order domain.Order
+		if err := json.Unmarshal(orderJSON, &order); err != nil {
+			logger.FromContext(ctx, s.logger).Errorw("Failed to unmarshal stored order", "order_uid", uid, "error", err)
+			return nil, fmt.Errorf("failed to unmarshal order %s: %w", uid, err)
+		}
+		orders[uid] = &order
+	}
+
+	if err = rows.Err(); err != nil {
+		if isConnectionError(err) {
+			return nil, ErrConnectionFailed
+		}
+		return nil, fmt.Errorf("iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
 // GetLatestOrders returns the last updated 'amount' of orders.
 //
 // As of now, the db is asked to assemble JSONS and the function handles unmarshaling them into structs
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -88,7 +89,7 @@ func TestMain(m *testing.M) {
 
 func TestDBStore_Integration(t *testing.T) {
 	// Truncate tables before test to ensure clean state
-	_, err := testStore.db.Exec("TRUNCATE orders, deliveries, payments, items RESTART IDENTITY CASCADE;")
+	_, err := testStore.db.Exec("TRUNCATE orders, deliveries, payments, items, dlq_messages, dlq_audit RESTART IDENTITY CASCADE;")
 	require.NoError(t, err)
 
 	// Create a sample order
@@ -186,4 +187,245 @@ func TestDBStore_Integration(t *testing.T) {
 		require.Equal(t, order3.OrderUID, latestOrders[0].OrderUID)
 		require.Equal(t, order2.OrderUID, latestOrders[1].OrderUID)
 	})
+	t.Run("ListOrders", func(t *testing.T) {
+		// three orders are stored by now: testuid123, testuid456, testuid789 (newest)
+		page, err := testStore.ListOrders(ctx, ListQuery{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 2)
+		require.Equal(t, "testuid789", page.Orders[0].OrderUID)
+		require.Equal(t, "testuid456", page.Orders[1].OrderUID)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = testStore.ListOrders(ctx, ListQuery{Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 1)
+		require.Equal(t, "testuid123", page.Orders[0].OrderUID)
+		require.Empty(t, page.NextCursor)
+
+		page, err = testStore.ListOrders(ctx, ListQuery{OrderFilter: OrderFilter{Currency: "usd", DeliveryService: "meest"}})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 3)
+
+		page, err = testStore.ListOrders(ctx, ListQuery{OrderFilter: OrderFilter{Provider: "nope"}})
+		require.NoError(t, err)
+		require.Empty(t, page.Orders)
+
+		_, err = testStore.ListOrders(ctx, ListQuery{Cursor: "not-a-cursor"})
+		require.ErrorIs(t, err, ErrInvalidCursor)
+
+		// the amounts are equal, the ties are broken by the id, in the same direction
+		byAmount := OrderSort{Field: SortAmount, Ascending: true}
+		sorted, err := testStore.ListOrders(ctx, ListQuery{Sort: byAmount, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, sorted.Orders, 2)
+		require.Equal(t, "testuid123", sorted.Orders[0].OrderUID)
+		require.Equal(t, "testuid456", sorted.Orders[1].OrderUID)
+
+		sorted, err = testStore.ListOrders(ctx, ListQuery{Sort: byAmount, Limit: 2, Cursor: sorted.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, sorted.Orders, 1)
+		require.Equal(t, "testuid789", sorted.Orders[0].OrderUID)
+
+		// a cursor only pages the sort it came from
+		page, err = testStore.ListOrders(ctx, ListQuery{Limit: 1})
+		require.NoError(t, err)
+		_, err = testStore.ListOrders(ctx, ListQuery{Sort: byAmount, Cursor: page.NextCursor})
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("GetOrders", func(t *testing.T) {
+		orders, err := testStore.GetOrders(ctx, []string{"testuid789", "nosuchuid", "testuid123"})
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		require.Equal(t, "testuid789", orders["testuid789"].OrderUID)
+		require.Equal(t, order.Items[0].ChrtID, orders["testuid123"].Items[0].ChrtID)
+
+		orders, err = testStore.GetOrders(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, orders)
+	})
+
+	t.Run("ExportOrders", func(t *testing.T) {
+		var uids []string
+		err := testStore.ExportOrders(ctx, OrderFilter{Currency: "usd"}, func(o *domain.Order) error {
+			uids = append(uids, o.OrderUID)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"testuid123", "testuid456", "testuid789"}, uids) // oldest first
+
+		stop := errors.New("stop")
+		err = testStore.ExportOrders(ctx, OrderFilter{}, func(o *domain.Order) error { return stop })
+		require.ErrorIs(t, err, stop)
+
+		err = testStore.ExportOrders(ctx, OrderFilter{Provider: "nope"}, func(o *domain.Order) error {
+			t.Fatal("nothing matches the filter")
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("CustomerSummary", func(t *testing.T) {
+		summary, err := testStore.CustomerSummary(ctx, "test")
+		require.NoError(t, err)
+		require.Equal(t, 3, summary.Orders)
+		require.True(t, order.DateCreated.Equal(summary.FirstOrderAt))
+		require.True(t, summary.LastOrderAt.After(summary.FirstOrderAt))
+		require.Equal(t, []CurrencyTotal{{Currency: "USD", Orders: 3, Amount: 3 * 1817}}, summary.Totals)
+		require.Equal(t, []DeliveryServiceUsage{{DeliveryService: "meest", Orders: 3}}, summary.DeliveryServices)
+
+		_, err = testStore.CustomerSummary(ctx, "nobody")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("LookupOrders", func(t *testing.T) {
+		// the three orders are copies: the items' track number, the rid and the transaction (testuid123) are shared
+		matches, err := testStore.LookupOrders(ctx, "trackno1")
+		require.NoError(t, err)
+		require.Len(t, matches, 3) // the items of all of them are on trackno1
+		require.Equal(t, "testuid789", matches[0].OrderUID)
+		require.Equal(t, []LookupKind{LookupTrackNumber}, matches[2].MatchedBy)
+
+		matches, err = testStore.LookupOrders(ctx, "testuid123")
+		require.NoError(t, err)
+		require.Len(t, matches, 3)
+		require.Equal(t, "testuid123", matches[2].OrderUID)
+		require.Equal(t, []LookupKind{LookupOrderUID, LookupTransaction}, matches[2].MatchedBy)
+
+		matches, err = testStore.LookupOrders(ctx, "testuid123", LookupOrderUID)
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+
+		matches, err = testStore.LookupOrders(ctx, "ab4219087a764ae0btest", LookupRID)
+		require.NoError(t, err)
+		require.Len(t, matches, 3)
+
+		matches, err = testStore.LookupOrders(ctx, "nothing")
+		require.NoError(t, err)
+		require.Empty(t, matches)
+	})
+
+	t.Run("Analytics", func(t *testing.T) {
+		analytics := NewAnalyticsStore(testStore.db, testStore.logger)
+
+		revenue, err := analytics.Revenue(ctx, OrderFilter{})
+		require.NoError(t, err)
+		require.Equal(t, []CurrencyTotal{{Currency: "USD", Orders: 3, Amount: 3 * 1817}}, revenue.ByCurrency)
+		var orders int
+		for _, day := range revenue.ByDay {
+			orders += day.Orders
+		}
+		require.Equal(t, 3, orders)
+
+		counts, err := analytics.Orders(ctx, OrderFilter{})
+		require.NoError(t, err)
+		require.Equal(t, []GroupCount{{Group: "meest", Orders: 3}}, counts.ByDeliveryService)
+		require.Equal(t, []GroupCount{{Group: "Kraiot", Orders: 3}}, counts.ByRegion)
+
+		items, err := analytics.Items(ctx, OrderFilter{}, 10)
+		require.NoError(t, err)
+		require.Equal(t, []BrandSales{{Brand: "Vivienne Sabo", Items: 3}}, items.TopBrands)
+		require.Equal(t, []ItemSales{{NmID: 2389222, Name: "Mascaras", Brand: "Vivienne Sabo", Items: 3}}, items.TopItems)
+		require.Equal(t, 3, items.Items)
+		require.InDelta(t, 30, items.AverageSalePercent, 0.001)
+
+		payments, err := analytics.Payments(ctx, OrderFilter{})
+		require.NoError(t, err)
+		require.Equal(t, []ProviderShare{{Provider: "wbpay", Orders: 3, Share: 1}}, payments.Providers)
+
+		revenue, err = analytics.Revenue(ctx, OrderFilter{Currency: "rub"})
+		require.NoError(t, err)
+		require.Empty(t, revenue.ByDay)
+		require.Empty(t, revenue.ByCurrency)
+	})
+
+	t.Run("DLQ", func(t *testing.T) {
+		offset := int64(17)
+		for i, reason := range []string{"invalid order", "record already exists", "invalid order"} {
+			m := &DLQMessage{
+				DLQTopic:     "orders-dlq",
+				DLQPartition: 0,
+				DLQOffset:    int64(i),
+				SourceOffset: &offset,
+				Key:          fmt.Sprintf("dlquid%d", i),
+				Payload:      []byte(`{"order_uid": "x"}`),
+				Reason:       reason,
+				Headers:      []DLQHeader{{Key: "DLQ REASON", Value: reason}},
+				DeadAt:       time.Now().UTC().Truncate(time.Second),
+			}
+			require.NoError(t, testStore.SaveDLQMessage(ctx, m))
+		}
+		// read again after a failed commit, it's ignored
+		dup := &DLQMessage{DLQTopic: "orders-dlq", DLQPartition: 0, DLQOffset: 0, Payload: []byte{}, DeadAt: time.Now()}
+		require.NoError(t, testStore.SaveDLQMessage(ctx, dup))
+
+		page, err := testStore.ListDLQMessages(ctx, DLQQuery{DLQFilter: DLQFilter{Reason: "INVALID"}, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 1)
+		require.Equal(t, "dlquid2", page.Messages[0].Key) // newest first
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = testStore.ListDLQMessages(ctx, DLQQuery{DLQFilter: DLQFilter{Reason: "INVALID"}, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 1)
+		require.Equal(t, "dlquid0", page.Messages[0].Key)
+		require.Empty(t, page.NextCursor)
+
+		m, err := testStore.GetDLQMessage(ctx, page.Messages[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, DLQPending, m.Status)
+		require.Equal(t, []DLQHeader{{Key: "DLQ REASON", Value: "invalid order"}}, m.Headers)
+		require.NotNil(t, m.SourceOffset)
+		require.Equal(t, offset, *m.SourceOffset)
+
+		// only a claimed message is marked as replayed, a released one is pending again
+		_, err = testStore.ResolveDLQMessage(ctx, m.ID, DLQResolution{Status: DLQReplayed, Actor: "ops"})
+		require.ErrorIs(t, err, ErrDLQResolved)
+		claimed, err := testStore.ClaimDLQMessage(ctx, m.ID)
+		require.NoError(t, err)
+		require.Equal(t, DLQReplaying, claimed.Status)
+		_, err = testStore.ClaimDLQMessage(ctx, m.ID)
+		require.ErrorIs(t, err, ErrDLQResolved)
+		require.NoError(t, testStore.ReleaseDLQMessage(ctx, m.ID))
+		_, err = testStore.ClaimDLQMessage(ctx, m.ID)
+		require.NoError(t, err)
+		_, err = testStore.ClaimDLQMessage(ctx, 999)
+		require.ErrorIs(t, err, ErrNotFound)
+
+		resolved, err := testStore.ResolveDLQMessage(ctx, m.ID, DLQResolution{Status: DLQReplayed, Actor: "ops", Note: "fixed"})
+		require.NoError(t, err)
+		require.Equal(t, DLQReplayed, resolved.Status)
+		require.NotNil(t, resolved.ResolvedAt)
+
+		_, err = testStore.ResolveDLQMessage(ctx, m.ID, DLQResolution{Status: DLQDiscarded, Actor: "ops"})
+		require.ErrorIs(t, err, ErrDLQResolved)
+		_, err = testStore.ResolveDLQMessage(ctx, 999, DLQResolution{Status: DLQDiscarded, Actor: "ops"})
+		require.ErrorIs(t, err, ErrNotFound)
+
+		trail, err := testStore.DLQAuditTrail(ctx, m.ID)
+		require.NoError(t, err)
+		require.Len(t, trail, 1)
+		require.Equal(t, DLQReplayed, trail[0].Action)
+		require.Equal(t, "ops", trail[0].Actor)
+		require.Equal(t, "fixed", trail[0].Note)
+
+		page, err = testStore.ListDLQMessages(ctx, DLQQuery{DLQFilter: DLQFilter{Status: DLQPending}})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 2)
+
+		// keys and header values are bytes, not text: they come back as they went in
+		binary := &DLQMessage{
+			DLQTopic: "orders-dlq", DLQPartition: 0, DLQOffset: 3,
+			Key:     "uid\x00\xff",
+			Payload: []byte{0xff},
+			Headers: []DLQHeader{{Key: "X-Trace", Value: "\xfe\x00trace"}},
+			DeadAt:  time.Now(),
+		}
+		require.NoError(t, testStore.SaveDLQMessage(ctx, binary))
+		page, err = testStore.ListDLQMessages(ctx, DLQQuery{DLQFilter: DLQFilter{Key: "uid\x00\xff"}})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 1)
+		require.Equal(t, binary.Key, page.Messages[0].Key)
+		require.Equal(t, binary.Headers, page.Messages[0].Headers)
+	})
 }
@@ -0,0 +1,21 @@
+-- +goose Up
+-- keyset pagination walks orders by (date_created, id), newest first
+CREATE INDEX idx_orders_date_created_id ON orders(date_created DESC, id DESC);
+
+-- equality filters on orders, the pagination key is appended so a filtered
+-- page can still be read straight off the index
+CREATE INDEX idx_orders_customer_id ON orders(customer_id, date_created DESC, id DESC);
+CREATE INDEX idx_orders_delivery_service ON orders(delivery_service, date_created DESC, id DESC);
+CREATE INDEX idx_orders_locale ON orders(locale, date_created DESC, id DESC);
+
+-- payment filters (payments is 1-to-1 with orders, order_id is its PK)
+CREATE INDEX idx_payments_currency ON payments(currency);
+CREATE INDEX idx_payments_provider ON payments(provider);
+
+-- +goose Down
+DROP INDEX idx_payments_provider;
+DROP INDEX idx_payments_currency;
+DROP INDEX idx_orders_locale;
+DROP INDEX idx_orders_delivery_service;
+DROP INDEX idx_orders_customer_id;
+DROP INDEX idx_orders_date_created_id;
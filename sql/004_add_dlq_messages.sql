@@ -0,0 +1,43 @@
+-- +goose Up
+-- the messages of the dead-letter topic, read back by the dlq ingester. A message is
+-- identified by its position in the dlq topic, so a redelivered one is stored once.
+CREATE TABLE dlq_messages (
+    id BIGSERIAL PRIMARY KEY,
+    dlq_topic TEXT NOT NULL,
+    dlq_partition INT NOT NULL,
+    dlq_offset BIGINT NOT NULL,
+    -- where the message was consumed from, NULL for the ones dead-lettered before
+    -- the consumer started recording it
+    source_topic TEXT,
+    source_partition INT,
+    source_offset BIGINT,
+    message_key TEXT NOT NULL DEFAULT '',
+    payload BYTEA NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    headers JSONB NOT NULL DEFAULT '[]',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'replayed', 'discarded')),
+    dead_at TIMESTAMPTZ NOT NULL,           -- the timestamp of the dlq message
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    UNIQUE (dlq_topic, dlq_partition, dlq_offset)
+);
+
+-- the browser lists newest first, mostly the pending ones
+CREATE INDEX idx_dlq_messages_status_id ON dlq_messages(status, id DESC);
+CREATE INDEX idx_dlq_messages_key ON dlq_messages(message_key);
+
+-- who replayed or discarded what, and why
+CREATE TABLE dlq_audit (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES dlq_messages(id) ON DELETE CASCADE,
+    action TEXT NOT NULL CHECK (action IN ('replayed', 'discarded')),
+    actor TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_dlq_audit_message_id ON dlq_audit(message_id, id);
+
+-- +goose Down
+DROP TABLE dlq_audit;
+DROP TABLE dlq_messages;
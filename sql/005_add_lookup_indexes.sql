@@ -0,0 +1,12 @@
+-- +goose Up
+-- support looks orders up by what the customers have at hand, see LookupOrders
+CREATE INDEX idx_orders_track_number ON orders(track_number);
+CREATE INDEX idx_items_track_number ON items(track_number);
+CREATE INDEX idx_items_rid ON items(rid);
+CREATE INDEX idx_payments_transaction ON payments(transaction);
+
+-- +goose Down
+DROP INDEX idx_payments_transaction;
+DROP INDEX idx_items_rid;
+DROP INDEX idx_items_track_number;
+DROP INDEX idx_orders_track_number;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- a replay claims its message before the delivery, instead of keeping it locked meanwhile
+ALTER TABLE dlq_messages DROP CONSTRAINT dlq_messages_status_check;
+ALTER TABLE dlq_messages ADD CONSTRAINT dlq_messages_status_check
+    CHECK (status IN ('pending', 'replaying', 'replayed', 'discarded'));
+
+-- +goose Down
+UPDATE dlq_messages SET status = 'pending' WHERE status = 'replaying';
+ALTER TABLE dlq_messages DROP CONSTRAINT dlq_messages_status_check;
+ALTER TABLE dlq_messages ADD CONSTRAINT dlq_messages_status_check
+    CHECK (status IN ('pending', 'replayed', 'discarded'));
@@ -0,0 +1,12 @@
+-- +goose Up
+-- keyset pagination of the other sorts of the order listing, by (column, id) like
+-- idx_orders_date_created_id; a btree is read backwards for the other direction
+CREATE INDEX idx_orders_customer_id_id ON orders(customer_id, id);
+CREATE INDEX idx_orders_delivery_service_id ON orders(delivery_service, id);
+-- the amount is the payment's, the ties are broken by its order_id (the same as the order's id)
+CREATE INDEX idx_payments_amount_order_id ON payments(amount, order_id);
+
+-- +goose Down
+DROP INDEX idx_payments_amount_order_id;
+DROP INDEX idx_orders_delivery_service_id;
+DROP INDEX idx_orders_customer_id_id;
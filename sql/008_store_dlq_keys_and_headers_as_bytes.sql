@@ -0,0 +1,28 @@
+-- +goose Up
+-- a kafka key or header value is any bytes, neither invalid UTF-8 nor a NUL survives a TEXT
+-- column or a json string: the key is stored as it is, the header values base64 encoded
+ALTER TABLE dlq_messages ALTER COLUMN message_key DROP DEFAULT;
+ALTER TABLE dlq_messages ALTER COLUMN message_key TYPE BYTEA USING convert_to(message_key, 'UTF8');
+ALTER TABLE dlq_messages ALTER COLUMN message_key SET DEFAULT ''::BYTEA;
+
+UPDATE dlq_messages SET headers = COALESCE((
+    SELECT jsonb_agg(jsonb_build_object(
+        'key', h->>'key',
+        'value', translate(encode(convert_to(h->>'value', 'UTF8'), 'base64'), E'\n', '')
+    ) ORDER BY n)
+    FROM jsonb_array_elements(headers) WITH ORDINALITY AS t(h, n)
+), '[]');
+
+-- +goose Down
+-- the bytes that aren't valid UTF-8 are escaped on the way back
+UPDATE dlq_messages SET headers = COALESCE((
+    SELECT jsonb_agg(jsonb_build_object(
+        'key', h->>'key',
+        'value', encode(decode(h->>'value', 'base64'), 'escape')
+    ) ORDER BY n)
+    FROM jsonb_array_elements(headers) WITH ORDINALITY AS t(h, n)
+), '[]');
+
+ALTER TABLE dlq_messages ALTER COLUMN message_key DROP DEFAULT;
+ALTER TABLE dlq_messages ALTER COLUMN message_key TYPE TEXT USING encode(message_key, 'escape');
+ALTER TABLE dlq_messages ALTER COLUMN message_key SET DEFAULT '';